@@ -62,6 +62,27 @@ func TestLoadConfig_TrimsTrailingSlash(t *testing.T) {
 	}
 }
 
+func TestLoadConfig_Units(t *testing.T) {
+	cfg, err := loadConfig([]string{"-url", "http://localhost", "-token", "tok", "-unit", "nginx.service,sshd.service", "-unit", "cron.service"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := []string{"nginx.service", "sshd.service", "cron.service"}
+	if len(cfg.LogUnits) != len(expect) {
+		t.Fatalf("expected units %v, got %v", expect, cfg.LogUnits)
+	}
+	for i := range expect {
+		if cfg.LogUnits[i] != expect[i] {
+			t.Errorf("unit %d = %q, expected %q", i, cfg.LogUnits[i], expect[i])
+		}
+	}
+
+	args := buildRunArgs(cfg)
+	if args[len(args)-2] != "--unit" || args[len(args)-1] != "nginx.service,sshd.service,cron.service" {
+		t.Errorf("expected units passed to service args, got %v", args)
+	}
+}
+
 // --- firstNonEmpty Tests ---
 
 func TestFirstNonEmpty(t *testing.T) {
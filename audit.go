@@ -0,0 +1,551 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Audit sources; variables so tests can point them at fixtures.
+var (
+	auditLogPath = "/var/log/audit/audit.log"
+	passwdPath   = "/etc/passwd"
+	groupPath    = "/etc/group"
+)
+
+// auditGroupTimeout is how long a multi-record event without its EOE record
+// is held back waiting for the remaining records.
+const auditGroupTimeout = 5 * time.Second
+
+// maxPendingAuditEvents bounds events kept for retry while the backend is down.
+const maxPendingAuditEvents = 5000
+
+// AuditRecord is one line of an audit event with decoded field values.
+type AuditRecord struct {
+	Type   string            `json:"type"`
+	Fields map[string]string `json:"fields"`
+}
+
+// AuditEvent is the set of records sharing one audit serial number.
+type AuditEvent struct {
+	Timestamp string        `json:"timestamp"`
+	Serial    uint64        `json:"serial"`
+	Type      string        `json:"type"` // SYSCALL when present, else the first record type
+	Key       string        `json:"key,omitempty"`
+	Syscall   string        `json:"syscall,omitempty"`
+	Success   string        `json:"success,omitempty"`
+	PID       int32         `json:"pid,omitempty"`
+	PPID      int32         `json:"ppid,omitempty"`
+	User      string        `json:"user,omitempty"`       // uid resolved to a name
+	AuditUser string        `json:"audit_user,omitempty"` // auid (login user) resolved to a name
+	Exe       string        `json:"exe,omitempty"`
+	Command   string        `json:"command,omitempty"` // EXECVE argv, or PROCTITLE
+	Cwd       string        `json:"cwd,omitempty"`
+	Paths     []string      `json:"paths,omitempty"`
+	Records   []AuditRecord `json:"records"`
+}
+
+// AuditEventPayload is sent to POST /api/ingest/server-audit-events
+type AuditEventPayload struct {
+	Events []AuditEvent `json:"events"`
+}
+
+// auditHeaderRe matches "type=SYSCALL msg=audit(1709280000.123:4567): ".
+var auditHeaderRe = regexp.MustCompile(`^(?:node=\S+ )?type=(\S+) msg=audit\((\d+)\.(\d+):(\d+)\):\s*`)
+
+// auditEncodedFields hold untrusted strings that auditd writes either quoted
+// or hex-encoded.
+var auditEncodedFields = map[string]bool{
+	"proctitle": true, "cmd": true, "comm": true, "exe": true, "cwd": true, "name": true,
+	"path": true, "acct": true, "data": true, "key": true, "old": true, "new": true,
+}
+
+// auditExecveArgRe matches EXECVE argument fields a0, a1, ...
+var auditExecveArgRe = regexp.MustCompile(`^a\d+$`)
+
+// auditUserInputFields are the encoded fields that carry what a user typed:
+// process titles, sudo command lines (USER_CMD) and keystrokes recorded by
+// pam_tty_audit (TTY, USER_TTY).
+var auditUserInputFields = map[string]bool{"proctitle": true, "cmd": true, "data": true}
+
+// auditIDFields are resolved to user or group names.
+var (
+	auditUIDFields = []string{"uid", "auid", "euid", "suid", "fsuid", "ouid", "inode_uid"}
+	auditGIDFields = []string{"gid", "egid", "sgid", "fsgid", "ogid", "inode_gid"}
+)
+
+// auditLine is a parsed audit.log line.
+type auditLine struct {
+	recType string
+	ts      time.Time
+	serial  uint64
+	fields  map[string]string
+}
+
+// parseAuditLine splits an audit.log line into its header and key=value
+// fields. Values of msg='...' (user-space records) are flattened in.
+func parseAuditLine(line string) (auditLine, bool) {
+	m := auditHeaderRe.FindStringSubmatch(line)
+	if m == nil {
+		return auditLine{}, false
+	}
+	sec, _ := strconv.ParseInt(m[2], 10, 64)
+	msec, _ := strconv.ParseInt(m[3], 10, 64)
+	serial, _ := strconv.ParseUint(m[4], 10, 64)
+
+	al := auditLine{
+		recType: m[1],
+		ts:      time.Unix(sec, msec*int64(time.Millisecond)),
+		serial:  serial,
+		fields:  make(map[string]string),
+	}
+	parseAuditFields(line[len(m[0]):], al.recType, al.fields)
+	return al, true
+}
+
+func parseAuditFields(s, recType string, fields map[string]string) {
+	// Everything after the record's \x1d separator is the enriched section
+	// (UID="root"); the raw values are resolved locally instead.
+	s, _, _ = strings.Cut(s, "\x1d")
+
+	for len(s) > 0 {
+		s = strings.TrimLeft(s, " ")
+		eq := strings.IndexByte(s, '=')
+		if eq <= 0 {
+			return
+		}
+		key := s[:eq]
+		s = s[eq+1:]
+
+		var value string
+		quoted := false
+		switch {
+		case strings.HasPrefix(s, `"`):
+			end := strings.IndexByte(s[1:], '"')
+			if end < 0 {
+				end = len(s) - 1
+			}
+			value, s = s[1:end+1], s[min(end+2, len(s)):]
+			quoted = true
+		case strings.HasPrefix(s, `'`):
+			end := strings.IndexByte(s[1:], '\'')
+			if end < 0 {
+				end = len(s) - 1
+			}
+			inner := s[1 : end+1]
+			s = s[min(end+2, len(s)):]
+			if key == "msg" {
+				parseAuditFields(inner, recType, fields)
+				continue
+			}
+			value, quoted = inner, true
+		default:
+			end := strings.IndexByte(s, ' ')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = s[:end], s[end:]
+		}
+
+		if !quoted && (auditEncodedFields[key] || (recType == "EXECVE" && auditExecveArgRe.MatchString(key))) {
+			value = decodeAuditHex(value)
+		}
+		fields[key] = value
+	}
+}
+
+// decodeAuditHex decodes a hex-encoded audit value. NUL separators (proctitle
+// argv, multiple keys) become spaces. Values that are not hex are returned as is.
+func decodeAuditHex(v string) string {
+	if v == "(null)" || len(v)%2 != 0 {
+		return v
+	}
+	b, err := hex.DecodeString(v)
+	if err != nil {
+		return v
+	}
+	b = bytes.TrimRight(b, "\x00")
+	b = bytes.ReplaceAll(b, []byte{0}, []byte{' '})
+	b = bytes.ReplaceAll(b, []byte{1}, []byte{','}) // multiple rule keys
+	return string(b)
+}
+
+// auditSyscalls maps syscall numbers per audit arch to names for the calls
+// audit rules usually watch. Unknown numbers are reported as is.
+var auditSyscalls = map[string]map[string]string{
+	"c000003e": { // x86_64
+		"0": "read", "1": "write", "2": "open", "3": "close", "41": "socket", "42": "connect",
+		"43": "accept", "44": "sendto", "49": "bind", "59": "execve", "62": "kill", "76": "truncate",
+		"77": "ftruncate", "80": "chdir", "82": "rename", "83": "mkdir", "84": "rmdir", "85": "creat",
+		"86": "link", "87": "unlink", "88": "symlink", "90": "chmod", "91": "fchmod", "92": "chown",
+		"101": "ptrace", "105": "setuid", "106": "setgid", "159": "adjtimex", "164": "settimeofday",
+		"165": "mount", "166": "umount2", "169": "reboot", "170": "sethostname", "175": "init_module",
+		"176": "delete_module", "227": "clock_settime", "257": "openat", "258": "mkdirat",
+		"260": "fchownat", "263": "unlinkat", "264": "renameat", "268": "fchmodat",
+		"304": "open_by_handle_at", "313": "finit_module", "316": "renameat2", "319": "memfd_create",
+		"321": "bpf", "322": "execveat",
+	},
+	"c00000b7": { // aarch64
+		"34": "mkdirat", "35": "unlinkat", "38": "renameat", "39": "umount2", "40": "mount",
+		"45": "truncate", "46": "ftruncate", "49": "chdir", "52": "fchmod", "53": "fchmodat",
+		"54": "fchownat", "56": "openat", "57": "close", "63": "read", "64": "write",
+		"105": "init_module", "106": "delete_module", "112": "clock_settime", "117": "ptrace",
+		"129": "kill", "142": "reboot", "144": "setgid", "146": "setuid", "161": "sethostname",
+		"170": "settimeofday", "171": "adjtimex", "198": "socket", "200": "bind", "202": "accept",
+		"203": "connect", "206": "sendto", "221": "execve", "265": "open_by_handle_at",
+		"273": "finit_module", "276": "renameat2", "279": "memfd_create", "280": "bpf", "281": "execveat",
+	},
+}
+
+func auditSyscallName(arch, nr string) string {
+	if name, ok := auditSyscalls[arch][nr]; ok {
+		return name
+	}
+	return nr
+}
+
+// idNames resolves numeric user and group IDs from /etc/passwd and /etc/group,
+// reloading when the files change.
+type idNames struct {
+	mu      sync.Mutex
+	users   map[string]string
+	groups  map[string]string
+	modTime time.Time
+}
+
+var auditIDs = &idNames{}
+
+func (n *idNames) load() {
+	var latest time.Time
+	for _, p := range []string{passwdPath, groupPath} {
+		if info, err := os.Stat(p); err == nil && info.ModTime().After(latest) {
+			latest = info.ModTime()
+		}
+	}
+	if n.users != nil && !latest.After(n.modTime) {
+		return
+	}
+	n.users, n.groups, n.modTime = readIDFile(passwdPath), readIDFile(groupPath), latest
+}
+
+// readIDFile maps the third field (uid/gid) to the first (name) of a
+// passwd-style file.
+func readIDFile(path string) map[string]string {
+	out := make(map[string]string)
+	f, err := os.Open(path)
+	if err != nil {
+		return out
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		parts := strings.Split(scanner.Text(), ":")
+		if len(parts) >= 3 {
+			if _, exists := out[parts[2]]; !exists {
+				out[parts[2]] = parts[0]
+			}
+		}
+	}
+	return out
+}
+
+// resolve adds "<field>_name" for every uid/gid field in fields.
+func (n *idNames) resolve(fields map[string]string) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.load()
+
+	lookup := func(keys []string, names map[string]string) {
+		for _, k := range keys {
+			v, ok := fields[k]
+			if !ok {
+				continue
+			}
+			switch {
+			case v == "4294967295" || v == "-1":
+				fields[k+"_name"] = "unset"
+			case names[v] != "":
+				fields[k+"_name"] = names[v]
+			}
+		}
+	}
+	lookup(auditUIDFields, n.users)
+	lookup(auditGIDFields, n.groups)
+}
+
+// auditGroup collects records for one serial until the event is complete.
+type auditGroup struct {
+	ts      time.Time
+	serial  uint64
+	records []AuditRecord
+	done    bool
+	firstAt time.Time
+}
+
+// buildAuditEvent summarises a complete record group.
+func buildAuditEvent(g *auditGroup) AuditEvent {
+	ev := AuditEvent{
+		Timestamp: g.ts.UTC().Format(time.RFC3339Nano),
+		Serial:    g.serial,
+		Records:   g.records,
+	}
+	if len(g.records) > 0 {
+		ev.Type = g.records[0].Type
+	}
+
+	var argv []string
+	argc := 0
+	for _, rec := range g.records {
+		f := rec.Fields
+		switch rec.Type {
+		case "SYSCALL":
+			ev.Type = "SYSCALL"
+			ev.Syscall = auditSyscallName(f["arch"], f["syscall"])
+			f["syscall_name"] = ev.Syscall
+			ev.Success = f["success"]
+			ev.Exe = f["exe"]
+			if pid, err := strconv.Atoi(f["pid"]); err == nil {
+				ev.PID = int32(pid)
+			}
+			if ppid, err := strconv.Atoi(f["ppid"]); err == nil {
+				ev.PPID = int32(ppid)
+			}
+		case "EXECVE":
+			argc, _ = strconv.Atoi(f["argc"])
+			for i := 0; i < argc; i++ {
+				argv = append(argv, f["a"+strconv.Itoa(i)])
+			}
+		case "CWD":
+			ev.Cwd = f["cwd"]
+		case "PATH":
+			if name := f["name"]; name != "" && name != "(null)" {
+				ev.Paths = append(ev.Paths, name)
+			}
+		case "PROCTITLE":
+			if ev.Command == "" {
+				ev.Command = f["proctitle"]
+			}
+		}
+		if ev.Key == "" && f["key"] != "" && f["key"] != "(null)" {
+			ev.Key = f["key"]
+		}
+		if ev.User == "" {
+			ev.User = firstNonEmpty(f["uid_name"], f["uid"])
+		}
+		if ev.AuditUser == "" {
+			ev.AuditUser = firstNonEmpty(f["auid_name"], f["auid"])
+		}
+		if ev.Exe == "" {
+			ev.Exe = f["exe"]
+		}
+	}
+	if len(argv) > 0 {
+		ev.Command = strings.Join(argv, " ")
+	}
+	return ev
+}
+
+// auditFilter selects events by rule key and record type.
+type auditFilter struct {
+	keys         []string
+	types        []string
+	excludeTypes []string
+}
+
+func (f auditFilter) match(ev AuditEvent) bool {
+	if len(f.keys) > 0 && !slices.ContainsFunc(strings.Split(ev.Key, ","), func(k string) bool {
+		return slices.Contains(f.keys, k)
+	}) {
+		return false
+	}
+	hasType := func(types []string) bool {
+		for _, rec := range ev.Records {
+			if slices.Contains(types, rec.Type) {
+				return true
+			}
+		}
+		return false
+	}
+	if len(f.types) > 0 && !hasType(f.types) {
+		return false
+	}
+	if len(f.excludeTypes) > 0 && slices.Contains(f.excludeTypes, ev.Type) {
+		return false
+	}
+	return true
+}
+
+// auditMonitor tails audit.log, reassembles events and queues them for sending.
+type auditMonitor struct {
+	filter auditFilter
+	tails  *fileTailer
+	redact func(fields ...*string)
+
+	mu      sync.Mutex
+	groups  map[uint64]*auditGroup
+	pending []AuditEvent
+}
+
+// newAuditMonitor builds the collector from the audit section of the rules file.
+func newAuditMonitor(cfg AuditConfig) *auditMonitor {
+	return &auditMonitor{
+		filter: auditFilter{keys: cfg.Keys, types: cfg.Types, excludeTypes: cfg.ExcludeTypes},
+		tails:  &fileTailer{cursors: make(map[string]*fileCursor)},
+		redact: func(...*string) {},
+		groups: make(map[uint64]*auditGroup),
+	}
+}
+
+// observe groups lines by serial and queues every event that is complete:
+// ended by EOE, made of a single user-space record, or older than auditGroupTimeout.
+func (a *auditMonitor) observe(lines []string, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	for _, line := range lines {
+		al, ok := parseAuditLine(line)
+		if !ok {
+			continue
+		}
+		g := a.groups[al.serial]
+		if g == nil {
+			g = &auditGroup{ts: al.ts, serial: al.serial, firstAt: now}
+			a.groups[al.serial] = g
+		}
+		if al.recType == "EOE" {
+			g.done = true
+			continue
+		}
+		auditIDs.resolve(al.fields)
+		g.records = append(g.records, AuditRecord{Type: al.recType, Fields: al.fields})
+	}
+
+	var ready []*auditGroup
+	for serial, g := range a.groups {
+		// Kernel syscall events end with EOE; user-space events are one record
+		multi := slices.ContainsFunc(g.records, func(r AuditRecord) bool { return r.Type == "SYSCALL" })
+		if g.done || !multi || now.Sub(g.firstAt) >= auditGroupTimeout {
+			ready = append(ready, g)
+			delete(a.groups, serial)
+		}
+	}
+	slices.SortFunc(ready, func(x, y *auditGroup) int {
+		if x.serial < y.serial {
+			return -1
+		}
+		if x.serial > y.serial {
+			return 1
+		}
+		return 0
+	})
+
+	for _, g := range ready {
+		if len(g.records) == 0 {
+			continue
+		}
+		ev := buildAuditEvent(g)
+		if !a.filter.match(ev) {
+			continue
+		}
+		a.redactCommand(&ev)
+		a.pending = append(a.pending, ev)
+	}
+	if len(a.pending) > maxPendingAuditEvents {
+		a.pending = a.pending[len(a.pending)-maxPendingAuditEvents:]
+	}
+}
+
+// redactCommand applies log redaction to command lines and recorded terminal
+// input, which often carry passwords and tokens.
+func (a *auditMonitor) redactCommand(ev *AuditEvent) {
+	a.redact(&ev.Command)
+	for _, rec := range ev.Records {
+		for k, v := range rec.Fields {
+			if (rec.Type == "EXECVE" && auditExecveArgRe.MatchString(k)) || auditUserInputFields[k] {
+				a.redact(&v)
+				rec.Fields[k] = v
+			}
+		}
+	}
+}
+
+func (a *auditMonitor) take() []AuditEvent {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	events := a.pending
+	a.pending = nil
+	return events
+}
+
+func (a *auditMonitor) restore(events []AuditEvent) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+	a.pending = append(events, a.pending...)
+	if len(a.pending) > maxPendingAuditEvents {
+		a.pending = a.pending[len(a.pending)-maxPendingAuditEvents:]
+	}
+}
+
+// sendAuditEventsToBackend reads new audit records and ships complete events.
+func sendAuditEventsToBackend(client *http.Client, cfg Config, logger *log.Logger) {
+	a := cfg.Audit
+	lines, err := a.tails.readNewLines(auditLogPath, 0)
+	if err != nil {
+		logger.Printf("audit collect error: %v", err)
+	}
+	a.observe(lines, time.Now())
+
+	events := a.take()
+	if len(events) == 0 {
+		return
+	}
+	// Large bursts (e.g. a recursive chmod) are split to keep requests small
+	for len(events) > 0 {
+		n := min(len(events), maxLogScanEntries)
+		if err := sendAuditEvents(client, cfg, AuditEventPayload{Events: events[:n]}); err != nil {
+			a.restore(events)
+			logger.Printf("audit events ingest failed: %v", err)
+			return
+		}
+		logger.Printf("audit events sent: %d", n)
+		events = events[n:]
+	}
+}
+
+// sendAuditEvents sends audit events to backend
+func sendAuditEvents(client *http.Client, cfg Config, payload AuditEventPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := cfg.BaseURL + "/api/ingest/server-audit-events"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return nil
+}
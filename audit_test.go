@@ -0,0 +1,171 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useAuditIDFiles(t *testing.T) {
+	t.Helper()
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "passwd"), []byte("root:x:0:0:root:/root:/bin/bash\nalice:x:1000:1000::/home/alice:/bin/bash\n"), 0o644)
+	os.WriteFile(filepath.Join(dir, "group"), []byte("root:x:0:\nalice:x:1000:\n"), 0o644)
+	oldPasswd, oldGroup, oldIDs := passwdPath, groupPath, auditIDs
+	passwdPath, groupPath, auditIDs = filepath.Join(dir, "passwd"), filepath.Join(dir, "group"), &idNames{}
+	t.Cleanup(func() { passwdPath, groupPath, auditIDs = oldPasswd, oldGroup, oldIDs })
+}
+
+var auditExecLines = []string{
+	`type=SYSCALL msg=audit(1709280000.123:4567): arch=c000003e syscall=59 success=yes exit=0 a0=55d0 a1=55d1 a2=55d2 a3=0 items=2 ppid=900 pid=901 auid=1000 uid=0 gid=0 euid=0 suid=0 fsuid=0 egid=0 sgid=0 fsgid=0 tty=pts0 ses=3 comm="cat" exe="/usr/bin/cat" subj=unconfined key="exec"`,
+	`type=EXECVE msg=audit(1709280000.123:4567): argc=2 a0="cat" a1=2F6574632F736861646F77`,
+	`type=CWD msg=audit(1709280000.123:4567): cwd="/root"`,
+	`type=PATH msg=audit(1709280000.123:4567): item=0 name="/usr/bin/cat" inode=1 dev=08:01 mode=0100755 ouid=0 ogid=0 rdev=00:00 nametype=NORMAL`,
+	`type=PROCTITLE msg=audit(1709280000.123:4567): proctitle=636174002F6574632F736861646F77`,
+	`type=EOE msg=audit(1709280000.123:4567): `,
+}
+
+// --- parser Tests ---
+
+func TestParseAuditLine_UserRecord(t *testing.T) {
+	al, ok := parseAuditLine(`type=USER_LOGIN msg=audit(1709280001.500:4600): pid=1200 uid=0 auid=1000 ses=4 msg='op=login acct="alice" exe="/usr/sbin/sshd" hostname=? addr=203.0.113.9 terminal=ssh res=success'` + "\x1d" + `UID="root" AUID="alice"`)
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if al.recType != "USER_LOGIN" || al.serial != 4600 || al.ts.UnixMilli() != 1709280001500 {
+		t.Errorf("unexpected header: %+v", al)
+	}
+	if al.fields["acct"] != "alice" || al.fields["addr"] != "203.0.113.9" || al.fields["res"] != "success" {
+		t.Errorf("expected nested msg fields flattened, got %v", al.fields)
+	}
+	if _, ok := al.fields["UID"]; ok {
+		t.Error("expected enriched section to be ignored")
+	}
+}
+
+func TestDecodeAuditHex(t *testing.T) {
+	tests := map[string]string{
+		"2F6574632F736861646F77":         "/etc/shadow",
+		"636174002F6574632F736861646F77": "cat /etc/shadow",
+		"(null)":                         "(null)",
+		"abc":                            "abc",
+	}
+	for in, want := range tests {
+		if got := decodeAuditHex(in); got != want {
+			t.Errorf("decodeAuditHex(%q) = %q, want %q", in, got, want)
+		}
+	}
+}
+
+// --- reassembly Tests ---
+
+func TestAuditMonitor_ReassemblesBySerial(t *testing.T) {
+	useAuditIDFiles(t)
+	a := newAuditMonitor(AuditConfig{})
+	now := time.Now()
+
+	// Records split across two polls are held until EOE
+	a.observe(auditExecLines[:3], now)
+	if events := a.take(); len(events) != 0 {
+		t.Fatalf("expected incomplete event to be held, got %d", len(events))
+	}
+	a.observe(auditExecLines[3:], now)
+
+	events := a.take()
+	if len(events) != 1 {
+		t.Fatalf("expected 1 event, got %d", len(events))
+	}
+	ev := events[0]
+	if ev.Serial != 4567 || ev.Type != "SYSCALL" || ev.Syscall != "execve" || ev.Key != "exec" {
+		t.Errorf("unexpected event header: %+v", ev)
+	}
+	if ev.Command != "cat /etc/shadow" || ev.Cwd != "/root" || ev.Exe != "/usr/bin/cat" {
+		t.Errorf("unexpected command details: %+v", ev)
+	}
+	if ev.User != "root" || ev.AuditUser != "alice" || ev.PID != 901 || ev.PPID != 900 {
+		t.Errorf("unexpected identity: %+v", ev)
+	}
+	if len(ev.Paths) != 1 || ev.Paths[0] != "/usr/bin/cat" || len(ev.Records) != 5 {
+		t.Errorf("unexpected records: %+v", ev)
+	}
+}
+
+func TestAuditMonitor_TimesOutWithoutEOE(t *testing.T) {
+	useAuditIDFiles(t)
+	a := newAuditMonitor(AuditConfig{})
+	now := time.Now()
+
+	a.observe(auditExecLines[:1], now)
+	a.observe(nil, now.Add(auditGroupTimeout))
+	if events := a.take(); len(events) != 1 {
+		t.Errorf("expected event flushed after timeout, got %d", len(events))
+	}
+}
+
+func TestAuditMonitor_Filter(t *testing.T) {
+	useAuditIDFiles(t)
+	login := `type=USER_LOGIN msg=audit(1709280001.500:4600): pid=1 uid=0 auid=1000 ses=4 msg='op=login acct="alice" res=success'`
+	refresh := `type=CRED_REFR msg=audit(1709280001.600:4601): pid=1 uid=0 auid=1000 ses=4 msg='op=PAM:setcred acct="alice" res=success'`
+
+	tests := []struct {
+		name string
+		cfg  AuditConfig
+		want []uint64
+	}{
+		{"all", AuditConfig{}, []uint64{4567, 4600, 4601}},
+		{"by key", AuditConfig{Keys: []string{"exec"}}, []uint64{4567}},
+		{"by type", AuditConfig{Types: []string{"EXECVE", "USER_LOGIN"}}, []uint64{4567, 4600}},
+		{"exclude type", AuditConfig{ExcludeTypes: []string{"CRED_REFR"}}, []uint64{4567, 4600}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a := newAuditMonitor(tt.cfg)
+			a.observe(append(append([]string{}, auditExecLines...), login, refresh), time.Now())
+			events := a.take()
+			if len(events) != len(tt.want) {
+				t.Fatalf("expected %d events, got %d", len(tt.want), len(events))
+			}
+			for i, ev := range events {
+				if ev.Serial != tt.want[i] {
+					t.Errorf("event %d: expected serial %d, got %d", i, tt.want[i], ev.Serial)
+				}
+			}
+		})
+	}
+}
+
+func TestAuditMonitor_RedactsUserInput(t *testing.T) {
+	useAuditIDFiles(t)
+	pipeline, err := newLogPipeline(LogRules{Redact: RedactConfig{Rules: []RedactRuleConfig{{Name: "secret", Pattern: `hunter2`}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := newAuditMonitor(AuditConfig{})
+	a.redact = pipeline.redactFields
+
+	tests := []struct {
+		name, line, field string
+	}{
+		// sudo mysql -phunter2
+		{"USER_CMD", `type=USER_CMD msg=audit(1709280002.000:4700): pid=1300 uid=1000 auid=1000 ses=4 msg='cwd="/home/alice" cmd=6D7973716C202D7068756E74657232 exe="/usr/bin/sudo" terminal=pts/0 res=success'`, "cmd"},
+		// su -\rhunter2\r
+		{"TTY", `type=TTY msg=audit(1709280003.000:4701): tty pid=1301 uid=1000 auid=1000 ses=4 major=136 minor=0 comm="bash" data=7375202D0D68756E746572320D`, "data"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			a.observe([]string{tt.line}, time.Now())
+			a.observe(nil, time.Now().Add(auditGroupTimeout))
+			events := a.take()
+			if len(events) != 1 || len(events[0].Records) != 1 {
+				t.Fatalf("expected one single-record event, got %+v", events)
+			}
+			got := events[0].Records[0].Fields[tt.field]
+			if got == "" || strings.Contains(got, "hunter2") {
+				t.Errorf("expected %s redacted, got %q", tt.field, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+package main
+
+import (
+	"encoding/json"
+	"log"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// Container log locations; variables so tests can point them at a temp dir.
+var (
+	dockerContainersDir = "/var/lib/docker/containers"
+	criContainerLogsDir = "/var/log/containers"
+)
+
+// containerInitialLines is how many trailing lines are read from a container
+// log the first time it is seen.
+const containerInitialLines = 50
+
+// maxPartialLogBytes bounds a reassembled partial line (Docker splits lines at
+// 16KiB, CRI marks them with P).
+const maxPartialLogBytes = 256 * 1024
+
+// containerLogSource is a running container and the file its runtime writes
+// stdout/stderr to.
+type containerLogSource struct {
+	path      string
+	runtime   string // docker or cri
+	container LogContainer
+}
+
+// dockerConfig is the subset of config.v2.json the agent needs.
+type dockerConfig struct {
+	ID    string `json:"ID"`
+	Name  string `json:"Name"`
+	State struct {
+		Running bool `json:"Running"`
+	} `json:"State"`
+	Config struct {
+		Image  string            `json:"Image"`
+		Labels map[string]string `json:"Labels"`
+	} `json:"Config"`
+	LogPath string `json:"LogPath"`
+}
+
+// containerLabelPrefixes are the label namespaces copied onto log entries;
+// other labels tend to be build metadata with little value per line.
+var containerLabelPrefixes = []string{"com.docker.compose.", "io.kubernetes."}
+
+func filterContainerLabels(labels map[string]string) map[string]string {
+	var out map[string]string
+	for k, v := range labels {
+		for _, prefix := range containerLabelPrefixes {
+			if strings.HasPrefix(k, prefix) {
+				if out == nil {
+					out = make(map[string]string)
+				}
+				out[k] = v
+				break
+			}
+		}
+	}
+	return out
+}
+
+// discoverDockerContainers lists running containers using the json-file log driver.
+func discoverDockerContainers() []containerLogSource {
+	dirs, err := os.ReadDir(dockerContainersDir)
+	if err != nil {
+		return nil
+	}
+
+	var sources []containerLogSource
+	for _, d := range dirs {
+		if !d.IsDir() {
+			continue
+		}
+		dir := filepath.Join(dockerContainersDir, d.Name())
+		data, err := os.ReadFile(filepath.Join(dir, "config.v2.json"))
+		if err != nil {
+			continue
+		}
+		var dc dockerConfig
+		if err := json.Unmarshal(data, &dc); err != nil || !dc.State.Running {
+			continue
+		}
+		if dc.ID == "" {
+			dc.ID = d.Name()
+		}
+		logPath := dc.LogPath
+		if logPath == "" {
+			logPath = filepath.Join(dir, dc.ID+"-json.log")
+		}
+		if _, err := os.Stat(logPath); err != nil {
+			continue // other log drivers (journald, syslog) have no file
+		}
+		sources = append(sources, containerLogSource{
+			path:    logPath,
+			runtime: "docker",
+			container: LogContainer{
+				ID:     dc.ID,
+				Name:   strings.TrimPrefix(dc.Name, "/"),
+				Image:  dc.Config.Image,
+				Labels: filterContainerLabels(dc.Config.Labels),
+			},
+		})
+	}
+	return sources
+}
+
+// criLogName matches kubelet's /var/log/containers/<pod>_<namespace>_<container>-<id>.log
+var criLogName = regexp.MustCompile(`^(.+)_([^_]+)_(.+)-([0-9a-f]{64})\.log$`)
+
+// discoverCRIContainers lists containers from kubelet's symlink directory,
+// which only holds entries for containers that still exist. The image is not
+// recorded there, so it is left empty.
+func discoverCRIContainers() []containerLogSource {
+	entries, err := os.ReadDir(criContainerLogsDir)
+	if err != nil {
+		return nil
+	}
+
+	var sources []containerLogSource
+	for _, e := range entries {
+		m := criLogName.FindStringSubmatch(e.Name())
+		if m == nil {
+			continue
+		}
+		sources = append(sources, containerLogSource{
+			path:    filepath.Join(criContainerLogsDir, e.Name()),
+			runtime: "cri",
+			container: LogContainer{
+				ID:   m[4],
+				Name: m[3],
+				Labels: map[string]string{
+					"io.kubernetes.pod.name":       m[1],
+					"io.kubernetes.pod.namespace":  m[2],
+					"io.kubernetes.container.name": m[3],
+				},
+			},
+		})
+	}
+	return sources
+}
+
+// containerLine is one decoded record from a container log file.
+type containerLine struct {
+	time    time.Time
+	stream  string
+	message string
+	partial bool // the message continues in the next record
+}
+
+// dockerLogLine is a line of Docker's json-file driver.
+type dockerLogLine struct {
+	Log    string `json:"log"`
+	Stream string `json:"stream"`
+	Time   string `json:"time"`
+}
+
+// parseDockerLogLine decodes {"log":"...\n","stream":"stdout","time":"..."}.
+// A log value without a trailing newline is a partial line.
+func parseDockerLogLine(line string) (containerLine, bool) {
+	var dl dockerLogLine
+	if err := json.Unmarshal([]byte(line), &dl); err != nil {
+		return containerLine{}, false
+	}
+	ts, _ := time.Parse(time.RFC3339Nano, dl.Time)
+	msg, complete := strings.CutSuffix(dl.Log, "\n")
+	return containerLine{time: ts, stream: dl.Stream, message: msg, partial: !complete}, true
+}
+
+// parseCRILogLine decodes "<RFC3339Nano> <stream> <P|F> <message>".
+func parseCRILogLine(line string) (containerLine, bool) {
+	parts := strings.SplitN(line, " ", 4)
+	if len(parts) < 3 {
+		return containerLine{}, false
+	}
+	ts, err := time.Parse(time.RFC3339Nano, parts[0])
+	if err != nil {
+		return containerLine{}, false
+	}
+	cl := containerLine{time: ts, stream: parts[1], partial: parts[2] == "P"}
+	if len(parts) == 4 {
+		cl.message = parts[3]
+	}
+	return cl, true
+}
+
+// containerSource tails the logs of running Docker and CRI containers.
+type containerSource struct {
+	tails    *fileTailer
+	partials map[string]string // partial lines per log file and stream until their final piece arrives
+}
+
+func newContainerSource(cfg Config, _ *log.Logger) (logSource, error) {
+	if !cfg.ContainerLogs {
+		return nil, nil
+	}
+	return &containerSource{
+		tails:    &fileTailer{cursors: make(map[string]*fileCursor)},
+		partials: make(map[string]string),
+	}, nil
+}
+
+func (*containerSource) name() string { return "container" }
+
+// collect discovers running containers and reads their new log lines. State
+// kept for containers that are gone is dropped.
+func (s *containerSource) collect(time.Duration) ([]LogEntry, error) {
+	sources := append(discoverDockerContainers(), discoverCRIContainers()...)
+	paths := make([]string, 0, len(sources))
+	live := make(map[string]bool, len(sources))
+	for _, src := range sources {
+		paths = append(paths, src.path)
+		live[src.path] = true
+	}
+	logHealth.prune("container", paths)
+	s.tails.prune(paths)
+	for key := range s.partials {
+		if path, _, _ := strings.Cut(key, "\x00"); !live[path] {
+			delete(s.partials, key)
+		}
+	}
+
+	var entries []LogEntry
+	for _, src := range sources {
+		entries = append(entries, s.collectLogs(src)...)
+	}
+	return entries, nil
+}
+
+// collectLogs reads the new lines of one container log file.
+func (s *containerSource) collectLogs(src containerLogSource) []LogEntry {
+	lines, err := s.tails.readNewLines(src.path, containerInitialLines)
+	if err != nil {
+		logHealth.fail(src.path, "container", err)
+		return nil
+	}
+
+	parse := parseDockerLogLine
+	if src.runtime == "cri" {
+		parse = parseCRILogLine
+	}
+
+	hostname, _ := os.Hostname()
+	container := src.container
+
+	var entries []LogEntry
+	emit := func(msg string, cl containerLine) {
+		msg = strings.TrimSpace(msg)
+		if msg == "" {
+			return
+		}
+		ts := cl.time
+		if ts.IsZero() {
+			ts = time.Now()
+		}
+		entries = append(entries, LogEntry{
+			Timestamp:  ts.UTC().Format(time.RFC3339Nano),
+			Level:      detectLogLevel(msg),
+			Service:    firstNonEmpty(container.Labels["com.docker.compose.service"], container.Name),
+			Host:       hostname,
+			Message:    msg,
+			Source:     src.path,
+			Container:  &container,
+			Attributes: map[string]string{"stream": cl.stream, "runtime": src.runtime},
+		})
+	}
+	for _, line := range lines {
+		cl, ok := parse(line)
+		if !ok {
+			continue
+		}
+		// stdout and stderr are interleaved in one file, so pieces are joined per stream
+		key := partialLogKey(src.path, cl.stream)
+		pending := s.partials[key]
+		if pending != "" && len(pending)+len(cl.message) > maxPartialLogBytes {
+			emit(pending, cl)
+			pending = ""
+		}
+		pending += cl.message
+		if cl.partial {
+			s.partials[key] = pending
+			continue
+		}
+		delete(s.partials, key)
+		emit(pending, cl)
+	}
+	logHealth.readLines(src.path, "container", lines, entries, s.tails.cursor(src.path))
+	return entries
+}
+
+// partialLogKey identifies the partial line being reassembled for one stream
+// of a container log file.
+func partialLogKey(path, stream string) string {
+	return path + "\x00" + stream
+}
@@ -0,0 +1,190 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func useContainerDirs(t *testing.T) (dockerDir, criDir string) {
+	t.Helper()
+	dockerDir, criDir = t.TempDir(), t.TempDir()
+	oldDocker, oldCRI := dockerContainersDir, criContainerLogsDir
+	dockerContainersDir, criContainerLogsDir = dockerDir, criDir
+	t.Cleanup(func() { dockerContainersDir, criContainerLogsDir = oldDocker, oldCRI })
+	return dockerDir, criDir
+}
+
+// --- parser Tests ---
+
+func TestParseDockerLogLine(t *testing.T) {
+	cl, ok := parseDockerLogLine(`{"log":"GET /healthz 200\n","stream":"stdout","time":"2026-03-01T10:00:00.123456789Z"}`)
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if cl.message != "GET /healthz 200" || cl.stream != "stdout" || cl.partial {
+		t.Errorf("unexpected line: %+v", cl)
+	}
+	if cl.time.Nanosecond() != 123456789 {
+		t.Errorf("expected nanosecond timestamp, got %v", cl.time)
+	}
+
+	if cl, _ := parseDockerLogLine(`{"log":"first half","stream":"stderr","time":"2026-03-01T10:00:00Z"}`); !cl.partial {
+		t.Error("expected line without newline to be partial")
+	}
+	if _, ok := parseDockerLogLine("not json"); ok {
+		t.Error("expected invalid line to be rejected")
+	}
+}
+
+func TestParseCRILogLine(t *testing.T) {
+	cl, ok := parseCRILogLine("2026-03-01T10:00:00.5+07:00 stderr F panic: boom now")
+	if !ok {
+		t.Fatal("expected line to parse")
+	}
+	if cl.message != "panic: boom now" || cl.stream != "stderr" || cl.partial {
+		t.Errorf("unexpected line: %+v", cl)
+	}
+
+	if cl, _ := parseCRILogLine("2026-03-01T10:00:00Z stdout P part"); !cl.partial {
+		t.Error("expected P tag to be partial")
+	}
+	if _, ok := parseCRILogLine("garbage"); ok {
+		t.Error("expected invalid line to be rejected")
+	}
+}
+
+// --- discovery / collection Tests ---
+
+func TestDiscoverDockerContainers(t *testing.T) {
+	dockerDir, _ := useContainerDirs(t)
+	id := strings.Repeat("a", 64)
+	dir := filepath.Join(dockerDir, id)
+	os.MkdirAll(dir, 0o755)
+	os.WriteFile(filepath.Join(dir, "config.v2.json"), []byte(`{
+		"ID": "`+id+`", "Name": "/shop_web_1", "State": {"Running": true},
+		"Config": {"Image": "nginx:1.27", "Labels": {
+			"com.docker.compose.project": "shop", "com.docker.compose.service": "web",
+			"org.opencontainers.image.revision": "abc"}}
+	}`), 0o644)
+	appendFile(t, filepath.Join(dir, id+"-json.log"), "")
+
+	stopped := filepath.Join(dockerDir, strings.Repeat("b", 64))
+	os.MkdirAll(stopped, 0o755)
+	os.WriteFile(filepath.Join(stopped, "config.v2.json"), []byte(`{"State": {"Running": false}}`), 0o644)
+
+	sources := discoverDockerContainers()
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 running container, got %d", len(sources))
+	}
+	c := sources[0].container
+	if c.ID != id || c.Name != "shop_web_1" || c.Image != "nginx:1.27" {
+		t.Errorf("unexpected container: %+v", c)
+	}
+	if len(c.Labels) != 2 || c.Labels["com.docker.compose.service"] != "web" {
+		t.Errorf("expected only compose labels, got %v", c.Labels)
+	}
+}
+
+func TestCollectContainerLogs_ReassemblesPartials(t *testing.T) {
+	_, criDir := useContainerDirs(t)
+	id := strings.Repeat("c", 64)
+	path := filepath.Join(criDir, "api-7d9_prod_server-"+id+".log")
+	appendFile(t, path, "")
+
+	sources := discoverCRIContainers()
+	if len(sources) != 1 {
+		t.Fatalf("expected 1 CRI container, got %d", len(sources))
+	}
+	src := sources[0]
+	if src.container.Labels["io.kubernetes.pod.namespace"] != "prod" || src.container.Name != "server" {
+		t.Errorf("unexpected container: %+v", src.container)
+	}
+
+	cs := &containerSource{tails: &fileTailer{cursors: make(map[string]*fileCursor)}, partials: make(map[string]string)}
+	cs.collectLogs(src) // position the tailer
+	appendFile(t, path, "2026-03-01T10:00:00Z stdout F started\n2026-03-01T10:00:01Z stderr P ERROR long ")
+	entries := cs.collectLogs(src)
+	if len(entries) != 1 || entries[0].Message != "started" {
+		t.Fatalf("expected one complete entry, got %+v", entries)
+	}
+
+	appendFile(t, path, "\n2026-03-01T10:00:01Z stderr F message\n")
+	entries = cs.collectLogs(src)
+	if len(entries) != 1 || entries[0].Message != "ERROR long message" {
+		t.Fatalf("expected reassembled entry, got %+v", entries)
+	}
+	e := entries[0]
+	if e.Level != "error" || e.Service != "server" || e.Attributes["stream"] != "stderr" || e.Container.ID != id {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+func TestContainerSource_ForgetsRemovedContainers(t *testing.T) {
+	_, criDir := useContainerDirs(t)
+	path := filepath.Join(criDir, "api-7d9_prod_server-"+strings.Repeat("d", 64)+".log")
+	appendFile(t, path, "2026-03-01T10:00:00Z stdout P half a line\n")
+
+	src, _ := newContainerSource(Config{ContainerLogs: true}, nil)
+	cs := src.(*containerSource)
+	cs.collect(time.Minute)
+	if cs.tails.cursor(path) == nil || cs.partials[partialLogKey(path, "stdout")] == "" {
+		t.Fatalf("expected cursor and partial line kept, got %v and %q", cs.tails.cursor(path), cs.partials[partialLogKey(path, "stdout")])
+	}
+
+	os.Remove(path)
+	cs.collect(time.Minute)
+	if cs.tails.cursor(path) != nil || len(cs.partials) != 0 {
+		t.Errorf("expected state of the removed container dropped, got %v and %v", cs.tails.cursor(path), cs.partials)
+	}
+}
+
+func TestCollectContainerLogs_ReassemblesPartialsPerStream(t *testing.T) {
+	_, criDir := useContainerDirs(t)
+	path := filepath.Join(criDir, "api-7d9_prod_server-"+strings.Repeat("e", 64)+".log")
+	appendFile(t, path, "")
+	src := discoverCRIContainers()[0]
+	cs := &containerSource{tails: &fileTailer{cursors: make(map[string]*fileCursor)}, partials: make(map[string]string)}
+	cs.collectLogs(src)
+
+	appendFile(t, path, "2026-03-01T10:00:00Z stdout P big response part 1, \n"+
+		"2026-03-01T10:00:00Z stderr F WARN slow query\n"+
+		"2026-03-01T10:00:01Z stdout F part 2\n")
+	entries := cs.collectLogs(src)
+	if len(entries) != 2 {
+		t.Fatalf("expected 2 entries, got %+v", entries)
+	}
+	if entries[0].Message != "WARN slow query" || entries[0].Attributes["stream"] != "stderr" {
+		t.Errorf("unexpected stderr entry: %+v", entries[0])
+	}
+	if entries[1].Message != "big response part 1, part 2" || entries[1].Attributes["stream"] != "stdout" {
+		t.Errorf("unexpected stdout entry: %+v", entries[1])
+	}
+}
+
+func TestCollectContainerLogs_FlushesOversizePartial(t *testing.T) {
+	_, criDir := useContainerDirs(t)
+	path := filepath.Join(criDir, "api-7d9_prod_server-"+strings.Repeat("f", 64)+".log")
+	appendFile(t, path, "")
+	src := discoverCRIContainers()[0]
+	cs := &containerSource{tails: &fileTailer{cursors: make(map[string]*fileCursor)}, partials: make(map[string]string)}
+	cs.collectLogs(src)
+
+	piece := strings.Repeat("a", 16*1024)
+	var stream strings.Builder
+	for i := 0; i < maxPartialLogBytes/len(piece)+1; i++ {
+		stream.WriteString("2026-03-01T10:00:00Z stdout P " + piece + "\n")
+	}
+	stream.WriteString("2026-03-01T10:00:01Z stdout F end\n")
+	appendFile(t, path, stream.String())
+
+	entries := cs.collectLogs(src)
+	if len(entries) != 2 {
+		t.Fatalf("expected the held text flushed before the next piece, got %d entries", len(entries))
+	}
+	if len(entries[0].Message) != maxPartialLogBytes || entries[1].Message != piece+"end" {
+		t.Errorf("unexpected sizes: %d and %d", len(entries[0].Message), len(entries[1].Message))
+	}
+}
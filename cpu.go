@@ -0,0 +1,167 @@
+package main
+
+import (
+	"errors"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+// cpuBaselineInterval is how long the first sample waits for a second reading,
+// so the very first CPU percentage is not measured over an undefined window.
+const cpuBaselineInterval = 250 * time.Millisecond
+
+// CPUTimesMetric is the share of time, in percent, one CPU (or all of them)
+// spent in each state since the previous sample. Steal exposes noisy
+// neighbours on VMs; iowait shows hosts stalled on disk.
+type CPUTimesMetric struct {
+	CPU     string  `json:"cpu"` // "total", "cpu0", "cpu1", ...
+	User    float64 `json:"user"`
+	System  float64 `json:"system"`
+	Nice    float64 `json:"nice"`
+	Idle    float64 `json:"idle"`
+	Iowait  float64 `json:"iowait"`
+	Irq     float64 `json:"irq"`
+	Softirq float64 `json:"softirq"`
+	Steal   float64 `json:"steal"`
+}
+
+// busy is the share of time not idle or waiting on I/O, matching cpu.Percent.
+func (m CPUTimesMetric) busy() float64 {
+	return max(0, 100-m.Idle-m.Iowait)
+}
+
+// cpuSampler keeps the previous CPU time counters for delta computation.
+type cpuSampler struct {
+	mu    sync.Mutex
+	total *cpu.TimesStat
+	cores map[string]cpu.TimesStat
+}
+
+var cpuTimes = &cpuSampler{}
+
+// read returns the CPU time breakdown since the previous call, in
+// total and per core. The first call takes a short baseline sample.
+func (s *cpuSampler) read() (CPUTimesMetric, []CPUTimesMetric, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	if s.total == nil {
+		if err := s.store(); err != nil {
+			return CPUTimesMetric{}, nil, err
+		}
+		time.Sleep(cpuBaselineInterval)
+	}
+	prevTotal, prevCores := *s.total, s.cores
+	if err := s.store(); err != nil {
+		return CPUTimesMetric{}, nil, err
+	}
+
+	total, ok := cpuTimesDelta(prevTotal, *s.total)
+	if !ok {
+		return CPUTimesMetric{}, nil, errors.New("cpu times did not advance")
+	}
+	total.CPU = "total"
+
+	cores := make([]CPUTimesMetric, 0, len(s.cores))
+	for name, cur := range s.cores {
+		prev, ok := prevCores[name]
+		if !ok {
+			continue // CPU brought online since the previous sample
+		}
+		if m, ok := cpuTimesDelta(prev, cur); ok {
+			m.CPU = name
+			cores = append(cores, m)
+		}
+	}
+	sortCPUTimes(cores)
+	return total, cores, nil
+}
+
+// store replaces the saved counters with fresh ones. Callers must hold s.mu.
+func (s *cpuSampler) store() error {
+	totals, err := cpu.Times(false)
+	if err != nil {
+		return err
+	}
+	if len(totals) == 0 {
+		return errors.New("cpu times empty")
+	}
+	perCPU, err := cpu.Times(true)
+	if err != nil {
+		return err
+	}
+
+	s.total = &totals[0]
+	s.cores = make(map[string]cpu.TimesStat, len(perCPU))
+	for _, t := range perCPU {
+		s.cores[t.CPU] = t
+	}
+	return nil
+}
+
+// cpuTimesSum is the total elapsed CPU time. Guest time is already part of
+// user time on Linux and is not added again.
+func cpuTimesSum(t cpu.TimesStat) float64 {
+	return t.User + t.System + t.Nice + t.Idle + t.Iowait + t.Irq + t.Softirq + t.Steal
+}
+
+// cpuTimesDelta converts two counter readings into percentages. It fails when
+// no time elapsed or a counter went backwards (CPU hotplug, counter reset).
+func cpuTimesDelta(prev, cur cpu.TimesStat) (CPUTimesMetric, bool) {
+	elapsed := cpuTimesSum(cur) - cpuTimesSum(prev)
+	if elapsed <= 0 {
+		return CPUTimesMetric{}, false
+	}
+	pct := func(a, b float64) (float64, bool) {
+		if b < a {
+			return 0, false
+		}
+		return (b - a) / elapsed * 100, true
+	}
+
+	var m CPUTimesMetric
+	fields := []struct {
+		dst       *float64
+		prev, cur float64
+	}{
+		{&m.User, prev.User, cur.User},
+		{&m.System, prev.System, cur.System},
+		{&m.Nice, prev.Nice, cur.Nice},
+		{&m.Idle, prev.Idle, cur.Idle},
+		{&m.Iowait, prev.Iowait, cur.Iowait},
+		{&m.Irq, prev.Irq, cur.Irq},
+		{&m.Softirq, prev.Softirq, cur.Softirq},
+		{&m.Steal, prev.Steal, cur.Steal},
+	}
+	for _, f := range fields {
+		v, ok := pct(f.prev, f.cur)
+		if !ok {
+			return CPUTimesMetric{}, false
+		}
+		*f.dst = v
+	}
+	return m, true
+}
+
+// sortCPUTimes orders cores numerically (cpu2 before cpu10).
+func sortCPUTimes(cores []CPUTimesMetric) {
+	index := func(name string) int {
+		n, err := strconv.Atoi(strings.TrimPrefix(name, "cpu"))
+		if err != nil {
+			return -1
+		}
+		return n
+	}
+	sort.Slice(cores, func(i, j int) bool {
+		a, b := index(cores[i].CPU), index(cores[j].CPU)
+		if a != b {
+			return a < b
+		}
+		return cores[i].CPU < cores[j].CPU
+	})
+}
@@ -0,0 +1,59 @@
+package main
+
+import (
+	"math"
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/cpu"
+)
+
+func TestCPUTimesDelta(t *testing.T) {
+	prev := cpu.TimesStat{User: 100, System: 50, Idle: 800, Iowait: 10, Steal: 40}
+	cur := cpu.TimesStat{User: 130, System: 60, Idle: 820, Iowait: 30, Steal: 40 + 20}
+
+	m, ok := cpuTimesDelta(prev, cur)
+	if !ok {
+		t.Fatal("expected delta")
+	}
+	// 100 seconds elapsed in total
+	want := CPUTimesMetric{User: 30, System: 10, Idle: 20, Iowait: 20, Steal: 20}
+	if m != want {
+		t.Errorf("got %+v, want %+v", m, want)
+	}
+	if busy := m.busy(); math.Abs(busy-60) > 1e-9 {
+		t.Errorf("busy = %v, want 60", busy)
+	}
+}
+
+func TestCPUTimesDelta_RejectsResetAndIdleWindow(t *testing.T) {
+	prev := cpu.TimesStat{User: 100, Idle: 800}
+	if _, ok := cpuTimesDelta(prev, prev); ok {
+		t.Error("expected no delta when no time elapsed")
+	}
+	if _, ok := cpuTimesDelta(prev, cpu.TimesStat{User: 10, Idle: 1000}); ok {
+		t.Error("expected no delta when a counter went backwards")
+	}
+}
+
+func TestCPUSamplerFirstReadIsMeaningful(t *testing.T) {
+	s := &cpuSampler{}
+	total, cores, err := s.read()
+	if err != nil {
+		t.Skipf("cpu times unavailable: %v", err)
+	}
+	sum := total.User + total.System + total.Nice + total.Idle + total.Iowait + total.Irq + total.Softirq + total.Steal
+	if math.Abs(sum-100) > 0.5 {
+		t.Errorf("breakdown should add up to 100%%, got %.2f", sum)
+	}
+	if total.CPU != "total" || len(cores) == 0 || cores[0].CPU != "cpu0" {
+		t.Errorf("unexpected labels: %q, %d cores", total.CPU, len(cores))
+	}
+}
+
+func TestSortCPUTimes(t *testing.T) {
+	cores := []CPUTimesMetric{{CPU: "cpu10"}, {CPU: "cpu2"}, {CPU: "cpu0"}}
+	sortCPUTimes(cores)
+	if cores[0].CPU != "cpu0" || cores[1].CPU != "cpu2" || cores[2].CPU != "cpu10" {
+		t.Errorf("unexpected order: %+v", cores)
+	}
+}
@@ -237,7 +237,7 @@ func sendServices(client *http.Client, cfg Config, services []DiscoveredService)
 
 // --- Log File Discovery ---
 
-// DiscoveredLogFile represents a .log file found on the server
+// DiscoveredLogFile represents a log file found on the server
 type DiscoveredLogFile struct {
 	Path       string `json:"path"`
 	SizeBytes  int64  `json:"size_bytes"`
@@ -247,93 +247,18 @@ type DiscoveredLogFile struct {
 // LogDiscoveryPayload is sent to POST /api/ingest/server-log-discovery
 type LogDiscoveryPayload struct {
 	Files []DiscoveredLogFile `json:"files"`
+	// Truncated names the scan budget (max_files, time_budget) that cut the scan short.
+	Truncated string `json:"truncated,omitempty"`
 }
 
-// logScanDirs are the directories to scan for .log files
-var logScanDirs = []string{
-	"/var/log",
-	"/opt",
-	"/home",
-	"/srv",
-	"/tmp",
-}
-
-// collectLogFiles scans common directories for .log files
-func collectLogFiles() []DiscoveredLogFile {
-	var files []DiscoveredLogFile
-	seen := make(map[string]bool)
-
-	for _, root := range logScanDirs {
-		info, err := os.Stat(root)
-		if err != nil || !info.IsDir() {
-			continue
-		}
-		scanDir(root, 0, 3, &files, seen)
-	}
-
-	return files
-}
-
-// scanDir recursively scans a directory for .log files up to maxDepth
-func scanDir(dir string, depth, maxDepth int, files *[]DiscoveredLogFile, seen map[string]bool) {
-	if depth > maxDepth {
-		return
-	}
-
-	entries, err := os.ReadDir(dir)
-	if err != nil {
-		return // skip unreadable dirs (permission denied, etc.)
-	}
-
-	for _, entry := range entries {
-		path := dir + "/" + entry.Name()
-
-		if entry.IsDir() {
-			// Skip common non-useful directories
-			name := entry.Name()
-			if name == "." || name == ".." || name == ".git" || name == "node_modules" || name == "__pycache__" {
-				continue
-			}
-			scanDir(path, depth+1, maxDepth, files, seen)
-			continue
-		}
-
-		// Only .log files
-		name := entry.Name()
-		if !strings.HasSuffix(name, ".log") {
-			continue
-		}
-		// Skip rotated/compressed files
-		if strings.HasSuffix(name, ".gz") || strings.HasSuffix(name, ".bz2") || strings.HasSuffix(name, ".xz") {
-			continue
-		}
-
-		if seen[path] {
-			continue
-		}
-		seen[path] = true
-
-		info, err := entry.Info()
-		if err != nil {
-			continue
-		}
-
-		// Skip empty files
-		if info.Size() == 0 {
-			continue
-		}
-
-		*files = append(*files, DiscoveredLogFile{
-			Path:       path,
-			SizeBytes:  info.Size(),
-			ModifiedAt: info.ModTime().UTC().Format(time.RFC3339),
-		})
-	}
+// collectLogFiles scans the configured discovery roots for log files
+func collectLogFiles(cfg Config) ([]DiscoveredLogFile, string) {
+	return cfg.LogScanner.scan()
 }
 
 // sendLogDiscovery sends discovered log files to the backend
-func sendLogDiscovery(client *http.Client, cfg Config, logFiles []DiscoveredLogFile) error {
-	payload := LogDiscoveryPayload{Files: logFiles}
+func sendLogDiscovery(client *http.Client, cfg Config, logFiles []DiscoveredLogFile, truncated string) error {
+	payload := LogDiscoveryPayload{Files: logFiles, Truncated: truncated}
 
 	body, err := json.Marshal(payload)
 	if err != nil {
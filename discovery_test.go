@@ -4,6 +4,8 @@ import (
 	"encoding/json"
 	"net/http"
 	"net/http/httptest"
+	"os"
+	"path/filepath"
 	"testing"
 	"time"
 )
@@ -243,3 +245,118 @@ func TestSendServices_Unauthorized(t *testing.T) {
 		t.Fatal("expected error on 401 response")
 	}
 }
+
+// --- log file discovery Tests ---
+
+func newTestScanner(t *testing.T, cfg DiscoveryConfig) *logScanner {
+	t.Helper()
+	s, err := newLogScanner(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return s
+}
+
+func writeLogFile(t *testing.T, path string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), 0o755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte("line\n"), 0o644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func scannedPaths(files []DiscoveredLogFile) map[string]bool {
+	out := make(map[string]bool, len(files))
+	for _, f := range files {
+		out[f.Path] = true
+	}
+	return out
+}
+
+func TestLogScanner_IncludeExcludeAndDepth(t *testing.T) {
+	root := t.TempDir()
+	deep := filepath.Join(root, "a", "b", "c", "d")
+	writeLogFile(t, filepath.Join(deep, "app.out"))
+	writeLogFile(t, filepath.Join(deep, "catalina.2026-03-01.log"))
+	writeLogFile(t, filepath.Join(deep, "notes.md"))
+	writeLogFile(t, filepath.Join(deep, "catalina.out.1"))
+	writeLogFile(t, filepath.Join(root, "cache", "tmp.out"))
+	writeLogFile(t, filepath.Join(root, "node_modules", "x.out"))
+	os.WriteFile(filepath.Join(root, "empty.out"), nil, 0o644)
+
+	s := newTestScanner(t, DiscoveryConfig{
+		Roots:    []string{root},
+		MaxDepth: 5,
+		Include:  []string{"*.out", "*.txt", "catalina.*"},
+		Exclude:  []string{"cache"},
+	})
+	files, truncated := s.scan()
+	got := scannedPaths(files)
+	if truncated != "" {
+		t.Errorf("unexpected truncation: %s", truncated)
+	}
+	if len(got) != 2 || !got[filepath.Join(deep, "app.out")] || !got[filepath.Join(deep, "catalina.2026-03-01.log")] {
+		t.Errorf("unexpected files: %v", got)
+	}
+
+	shallow := newTestScanner(t, DiscoveryConfig{Roots: []string{root}, MaxDepth: 3, Include: []string{"*.out"}, Exclude: []string{"cache"}})
+	if files, _ := shallow.scan(); len(files) != 0 {
+		t.Errorf("expected depth limit to hide deep files, got %v", scannedPaths(files))
+	}
+}
+
+func TestLogScanner_Symlinks(t *testing.T) {
+	root := t.TempDir()
+	target := t.TempDir()
+	writeLogFile(t, filepath.Join(target, "linked.log"))
+	if err := os.Symlink(target, filepath.Join(root, "link")); err != nil {
+		t.Skipf("symlinks unsupported: %v", err)
+	}
+	// A loop back to the root must not recurse forever
+	os.Symlink(root, filepath.Join(root, "loop"))
+
+	s := newTestScanner(t, DiscoveryConfig{Roots: []string{root}})
+	if files, _ := s.scan(); len(files) != 0 {
+		t.Errorf("expected symlinked dirs to be skipped by default, got %v", scannedPaths(files))
+	}
+
+	s = newTestScanner(t, DiscoveryConfig{Roots: []string{root}, FollowSymlinks: true, MaxDepth: 10})
+	files, _ := s.scan()
+	if len(files) != 1 || files[0].Path != filepath.Join(root, "link", "linked.log") {
+		t.Errorf("expected one file via symlink, got %v", scannedPaths(files))
+	}
+}
+
+func TestLogScanner_MaxFilesBudget(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"a.log", "b.log", "c.log"} {
+		writeLogFile(t, filepath.Join(root, name))
+	}
+
+	s := newTestScanner(t, DiscoveryConfig{Roots: []string{root}, MaxFiles: 2})
+	files, truncated := s.scan()
+	if len(files) != 2 || truncated != "max_files" {
+		t.Errorf("expected scan stopped at 2 files, got %d (%q)", len(files), truncated)
+	}
+}
+
+func TestNewLogScanner_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  DiscoveryConfig
+	}{
+		{"relative root", DiscoveryConfig{Roots: []string{"var/log"}}},
+		{"bad pattern", DiscoveryConfig{Include: []string{"[a-"}}},
+		{"bad budget", DiscoveryConfig{TimeBudget: "soon"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newLogScanner(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
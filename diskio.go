@@ -0,0 +1,265 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// diskSectorSize is the unit of the sector counters in /proc/diskstats,
+// regardless of the device's real sector size.
+const diskSectorSize = 512
+
+// defaultDiskExclude skips virtual block devices unless --disk-include
+// names them explicitly.
+var defaultDiskExclude = []string{"loop*", "ram*", "zram*"}
+
+// DiskIOMetric is the activity of one block device since the previous sample.
+type DiskIOMetric struct {
+	Device      string `json:"device"`
+	ReadBytes   int64  `json:"read_bytes"`
+	WriteBytes  int64  `json:"write_bytes"`
+	ReadOps     int64  `json:"read_ops"`
+	WriteOps    int64  `json:"write_ops"`
+	ReadMerged  int64  `json:"read_merged"`
+	WriteMerged int64  `json:"write_merged"`
+	// Average time per completed request in milliseconds, queueing included
+	ReadAwaitMs  float64 `json:"read_await_ms"`
+	WriteAwaitMs float64 `json:"write_await_ms"`
+	QueueDepth   float64 `json:"queue_depth"`  // average requests in flight, as iostat's aqu-sz
+	InFlight     uint64  `json:"in_flight"`    // requests in flight at sample time
+	UtilPercent  float64 `json:"util_percent"` // share of time the device was busy
+}
+
+type DiskIOPayload struct {
+	Timestamp       string         `json:"timestamp"`
+	IntervalSeconds float64        `json:"interval_seconds"`
+	Devices         []DiskIOMetric `json:"devices"`
+}
+
+// diskStat is one line of /proc/diskstats.
+type diskStat struct {
+	reads, readMerged, readSectors, readMs     uint64
+	writes, writeMerged, writeSectors, writeMs uint64
+	inFlight, ioMs, weightedMs                 uint64
+}
+
+// diskStats is a /proc/diskstats sample of the selected devices.
+type diskStats struct {
+	at      time.Time
+	devices map[string]diskStat
+}
+
+// collectDiskIOMetrics reads /proc/diskstats and returns each selected
+// device's activity since prev, mirroring collectIfaceMetrics. It returns
+// nothing without error where /proc is missing.
+func collectDiskIOMetrics(cfg Config, prev diskStats, hasPrev bool) ([]DiskIOMetric, diskStats, bool, error) {
+	devices, err := readDiskStats()
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, prev, false, nil // not Linux
+	}
+	if err != nil {
+		return nil, prev, false, err
+	}
+
+	next := diskStats{at: time.Now(), devices: make(map[string]diskStat, len(devices))}
+	for name, stat := range devices {
+		if stat.reads+stat.writes == 0 {
+			continue // never used, e.g. an empty optical drive
+		}
+		if diskDeviceSelected(cfg, name) {
+			next.devices[name] = stat
+		}
+	}
+
+	if !hasPrev {
+		return nil, next, false, nil
+	}
+
+	metrics := diskIODeltas(prev, next)
+	return metrics, next, len(metrics) > 0, nil
+}
+
+// diskIODeltas converts two samples into per-device metrics. Devices that
+// appeared since prev or whose counters went backwards are skipped.
+func diskIODeltas(prev, next diskStats) []DiskIOMetric {
+	elapsedMs := float64(next.at.Sub(prev.at).Milliseconds())
+	if elapsedMs <= 0 {
+		return nil
+	}
+
+	metrics := make([]DiskIOMetric, 0, len(next.devices))
+	for name, curr := range next.devices {
+		old, ok := prev.devices[name]
+		if !ok {
+			continue
+		}
+		reads, ok1 := safeDelta(old.reads, curr.reads)
+		writes, ok2 := safeDelta(old.writes, curr.writes)
+		readMerged, ok3 := safeDelta(old.readMerged, curr.readMerged)
+		writeMerged, ok4 := safeDelta(old.writeMerged, curr.writeMerged)
+		readSectors, ok5 := safeDelta(old.readSectors, curr.readSectors)
+		writeSectors, ok6 := safeDelta(old.writeSectors, curr.writeSectors)
+		readMs, ok7 := safeDelta(old.readMs, curr.readMs)
+		writeMs, ok8 := safeDelta(old.writeMs, curr.writeMs)
+		ioMs, ok9 := safeDelta(old.ioMs, curr.ioMs)
+		weightedMs, ok10 := safeDelta(old.weightedMs, curr.weightedMs)
+		if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6 && ok7 && ok8 && ok9 && ok10) {
+			continue
+		}
+
+		m := DiskIOMetric{
+			Device:      name,
+			ReadBytes:   readSectors * diskSectorSize,
+			WriteBytes:  writeSectors * diskSectorSize,
+			ReadOps:     reads,
+			WriteOps:    writes,
+			ReadMerged:  readMerged,
+			WriteMerged: writeMerged,
+			QueueDepth:  float64(weightedMs) / elapsedMs,
+			InFlight:    curr.inFlight,
+			UtilPercent: min(100, float64(ioMs)/elapsedMs*100),
+		}
+		if reads > 0 {
+			m.ReadAwaitMs = float64(readMs) / float64(reads)
+		}
+		if writes > 0 {
+			m.WriteAwaitMs = float64(writeMs) / float64(writes)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
+}
+
+// readDiskStats parses /proc/diskstats into counters keyed by device name.
+func readDiskStats() (map[string]diskStat, error) {
+	f, err := os.Open(filepath.Join(procRoot, "diskstats"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	devices := make(map[string]diskStat)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 14 {
+			continue
+		}
+		var v [11]uint64
+		valid := true
+		for i := range v {
+			if v[i], err = strconv.ParseUint(fields[3+i], 10, 64); err != nil {
+				valid = false
+				break
+			}
+		}
+		if !valid {
+			continue
+		}
+		devices[fields[2]] = diskStat{
+			reads: v[0], readMerged: v[1], readSectors: v[2], readMs: v[3],
+			writes: v[4], writeMerged: v[5], writeSectors: v[6], writeMs: v[7],
+			inFlight: v[8], ioMs: v[9], weightedMs: v[10],
+		}
+	}
+	return devices, scanner.Err()
+}
+
+// diskDeviceSelected applies --disk-include and --disk-exclude to a device.
+// Without an include list only whole disks outside the default excludes are
+// collected; partitions and excluded devices can be named explicitly.
+func diskDeviceSelected(cfg Config, name string) bool {
+	if matchesAnyGlob(cfg.DiskExclude, name) {
+		return false
+	}
+	if len(cfg.DiskInclude) > 0 {
+		return matchesAnyGlob(cfg.DiskInclude, name)
+	}
+	return !matchesAnyGlob(defaultDiskExclude, name) && isWholeDisk(name)
+}
+
+// isWholeDisk reports whether name is a disk rather than a partition. Only
+// disks have an entry in /sys/block; without sysfs every device counts.
+func isWholeDisk(name string) bool {
+	if _, err := os.Stat(filepath.Join(sysRoot, "block")); err != nil {
+		return true
+	}
+	// sysfs spells the "/" in names such as cciss/c0d0 as "!"
+	_, err := os.Stat(filepath.Join(sysRoot, "block", strings.ReplaceAll(name, "/", "!")))
+	return err == nil
+}
+
+func matchesAnyGlob(patterns []string, name string) bool {
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(pattern, name); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// validateGlobs rejects malformed device patterns at startup.
+func validateGlobs(patterns []string) error {
+	for _, pattern := range patterns {
+		if _, err := path.Match(pattern, ""); err != nil {
+			return fmt.Errorf("%q: %w", pattern, err)
+		}
+	}
+	return nil
+}
+
+func sendDiskIOMetrics(client *http.Client, cfg Config, timestamp string, interval time.Duration, devices []DiskIOMetric) error {
+	if len(devices) == 0 {
+		return nil
+	}
+
+	payload := DiskIOPayload{
+		Timestamp:       timestamp,
+		IntervalSeconds: interval.Seconds(),
+		Devices:         devices,
+	}
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	endpoint := cfg.BaseURL + "/api/ingest/server-disk-io"
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		respBody, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		msg := strings.TrimSpace(string(respBody))
+		if msg == "" {
+			msg = resp.Status
+		}
+		return fmt.Errorf("status=%d body=%s", resp.StatusCode, msg)
+	}
+
+	return nil
+}
@@ -0,0 +1,133 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+const testDiskstats = `   7       0 loop0 120 0 960 4 0 0 0 0 0 8 4 0 0 0 0
+   8       0 sda 1000 100 80000 2000 500 50 40000 5000 2 3000 7000 0 0 0 0
+   8       1 sda1 900 90 72000 1800 400 40 32000 4000 0 2500 5800
+   8      16 sdb 0 0 0 0 0 0 0 0 0 0 0
+ 104       0 cciss/c0d0 10 0 80 1 0 0 0 0 0 1 1
+`
+
+func TestReadDiskStats(t *testing.T) {
+	withProcRoot(t, map[string]string{"diskstats": testDiskstats})
+
+	devices, err := readDiskStats()
+	if err != nil {
+		t.Fatalf("readDiskStats: %v", err)
+	}
+	if len(devices) != 5 {
+		t.Fatalf("expected 5 devices, got %d: %v", len(devices), devices)
+	}
+	sda := devices["sda"]
+	if sda.reads != 1000 || sda.readMerged != 100 || sda.readSectors != 80000 || sda.readMs != 2000 ||
+		sda.writes != 500 || sda.writeMerged != 50 || sda.writeSectors != 40000 || sda.writeMs != 5000 ||
+		sda.inFlight != 2 || sda.ioMs != 3000 || sda.weightedMs != 7000 {
+		t.Errorf("unexpected sda counters: %+v", sda)
+	}
+	if _, ok := devices["cciss/c0d0"]; !ok {
+		t.Error("expected device names with a slash to be kept")
+	}
+}
+
+func TestCollectDiskIOMetrics_NotLinux(t *testing.T) {
+	withProcRoot(t, map[string]string{})
+	metrics, _, ok, err := collectDiskIOMetrics(Config{}, diskStats{}, false)
+	if metrics != nil || ok || err != nil {
+		t.Errorf("expected nothing without /proc/diskstats, got %v, %v, %v", metrics, ok, err)
+	}
+}
+
+func TestDiskDeviceSelected(t *testing.T) {
+	withSysRoot(t, map[string]string{
+		"block/sda/stat":        "",
+		"block/loop0/stat":      "",
+		"block/cciss!c0d0/stat": "",
+	})
+
+	tests := []struct {
+		name   string
+		cfg    Config
+		device string
+		expect bool
+	}{
+		{"whole disk", Config{}, "sda", true},
+		{"partition", Config{}, "sda1", false},
+		{"default exclude", Config{}, "loop0", false},
+		{"sysfs name with bang", Config{}, "cciss/c0d0", true},
+		{"user exclude", Config{DiskExclude: []string{"sd*"}}, "sda", false},
+		{"include partition", Config{DiskInclude: []string{"sda*"}}, "sda1", true},
+		{"include loop", Config{DiskInclude: []string{"loop0"}}, "loop0", true},
+		{"not included", Config{DiskInclude: []string{"nvme*"}}, "sda", false},
+		{"exclude beats include", Config{DiskInclude: []string{"sda*"}, DiskExclude: []string{"sda1"}}, "sda1", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := diskDeviceSelected(tt.cfg, tt.device); got != tt.expect {
+				t.Errorf("diskDeviceSelected(%q) = %v, expected %v", tt.device, got, tt.expect)
+			}
+		})
+	}
+}
+
+func TestDiskIODeltas(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	prev := diskStats{at: start, devices: map[string]diskStat{
+		"sda":  {reads: 1000, readMerged: 100, readSectors: 80000, readMs: 2000, writes: 500, writeMerged: 50, writeSectors: 40000, writeMs: 5000, ioMs: 3000, weightedMs: 7000},
+		"sdb":  {reads: 10, writes: 10, ioMs: 100},
+		"gone": {reads: 1},
+	}}
+	next := diskStats{at: start.Add(10 * time.Second), devices: map[string]diskStat{
+		"sda": {reads: 1100, readMerged: 110, readSectors: 88000, readMs: 2500, writes: 700, writeMerged: 70, writeSectors: 48000, writeMs: 6000, inFlight: 3, ioMs: 8000, weightedMs: 27000},
+		"sdb": {reads: 5, writes: 10, ioMs: 100}, // counters reset
+		"new": {reads: 1},
+	}}
+
+	metrics := diskIODeltas(prev, next)
+	if len(metrics) != 1 {
+		t.Fatalf("expected only sda, got %+v", metrics)
+	}
+	m := metrics[0]
+	if m.Device != "sda" || m.ReadOps != 100 || m.WriteOps != 200 || m.ReadMerged != 10 || m.WriteMerged != 20 {
+		t.Errorf("unexpected op counts: %+v", m)
+	}
+	if m.ReadBytes != 8000*512 || m.WriteBytes != 8000*512 {
+		t.Errorf("unexpected byte counts: %+v", m)
+	}
+	near := func(a, b float64) bool { return math.Abs(a-b) < 1e-9 }
+	if !near(m.ReadAwaitMs, 5) || !near(m.WriteAwaitMs, 5) {
+		t.Errorf("expected 5ms await, got read %v write %v", m.ReadAwaitMs, m.WriteAwaitMs)
+	}
+	if !near(m.QueueDepth, 2) || !near(m.UtilPercent, 50) || m.InFlight != 3 {
+		t.Errorf("expected queue depth 2, util 50%%, 3 in flight, got %+v", m)
+	}
+
+	// Busy time can exceed wall time slightly on multi-queue devices
+	next.devices = map[string]diskStat{"sda": {reads: 1000, readSectors: 80000, readMs: 2000, writes: 500, writeSectors: 40000, writeMs: 5000, ioMs: 14000, weightedMs: 7000, readMerged: 100, writeMerged: 50}}
+	if m := diskIODeltas(prev, next); len(m) != 1 || m[0].UtilPercent != 100 || m[0].ReadAwaitMs != 0 {
+		t.Errorf("expected util capped at 100%% and no await without ops, got %+v", m)
+	}
+}
+
+func TestLoadConfig_DiskDevices(t *testing.T) {
+	cfg, err := loadConfig([]string{"-url", "http://localhost", "-token", "tok", "-disk-include", "sd*,nvme*", "-disk-exclude", "sdz"})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(cfg.DiskInclude) != 2 || len(cfg.DiskExclude) != 1 {
+		t.Fatalf("unexpected disk lists: include %v exclude %v", cfg.DiskInclude, cfg.DiskExclude)
+	}
+	args := buildRunArgs(cfg)
+	if args[len(args)-4] != "--disk-include" || args[len(args)-3] != "sd*,nvme*" ||
+		args[len(args)-2] != "--disk-exclude" || args[len(args)-1] != "sdz" {
+		t.Errorf("expected disk lists passed to service args, got %v", args)
+	}
+
+	if _, err := loadConfig([]string{"-url", "http://localhost", "-token", "tok", "-disk-exclude", "sd["}); err == nil {
+		t.Error("expected error for malformed device glob")
+	}
+}
@@ -4,6 +4,7 @@ import (
 	"os"
 	"runtime"
 	"strings"
+	"time"
 
 	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
@@ -18,6 +19,7 @@ type FactsPayload struct {
 	OSName         string     `json:"os_name"`
 	OSVersion      string     `json:"os_version"`
 	KernelVersion  string     `json:"kernel_version"`
+	BootTime       string     `json:"boot_time,omitempty"`
 	CPUModel       string     `json:"cpu_model"`
 	CPUCores       int        `json:"cpu_cores"`
 	MemTotalBytes  uint64     `json:"mem_total_bytes"`
@@ -65,6 +67,9 @@ func collectFacts() (FactsPayload, error) {
 		facts.OSName = hostInfo.Platform
 		facts.OSVersion = hostInfo.PlatformVersion
 		facts.KernelVersion = hostInfo.KernelVersion
+		if hostInfo.BootTime > 0 {
+			facts.BootTime = time.Unix(int64(hostInfo.BootTime), 0).UTC().Format(time.RFC3339)
+		}
 		facts.Virtualization = hostInfo.VirtualizationSystem
 		if hostInfo.VirtualizationRole == "guest" {
 			facts.Provider = detectProvider()
@@ -0,0 +1,172 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+// filesystemUsageTimeout bounds statfs on a single mount so a hung network
+// filesystem cannot stall the metrics loop.
+const filesystemUsageTimeout = 2 * time.Second
+
+// FilesystemMetric is the space and inode usage of one mounted filesystem.
+type FilesystemMetric struct {
+	Device            string  `json:"device"`
+	Mount             string  `json:"mount"`
+	FSType            string  `json:"fstype"`
+	TotalBytes        uint64  `json:"total_bytes"`
+	UsedBytes         uint64  `json:"used_bytes"`
+	FreeBytes         uint64  `json:"free_bytes"` // available to unprivileged users
+	UsedPercent       float64 `json:"used_percent"`
+	InodesTotal       uint64  `json:"inodes_total"`
+	InodesUsed        uint64  `json:"inodes_used"`
+	InodesFree        uint64  `json:"inodes_free"`
+	InodesUsedPercent float64 `json:"inodes_used_percent"`
+	ReadOnly          bool    `json:"read_only"`
+	// RemountedReadOnly is set while a mount the agent saw writable is read-only,
+	// typically after the kernel applied errors=remount-ro on I/O errors.
+	RemountedReadOnly bool `json:"remounted_read_only,omitempty"`
+}
+
+// filesystemSampler remembers which mounts were writable and which statfs
+// calls are still outstanding.
+type filesystemSampler struct {
+	mu       sync.Mutex
+	writable map[string]bool
+	pending  map[string]bool
+}
+
+var filesystems = &filesystemSampler{}
+
+var errFilesystemHung = errors.New("statfs timed out")
+
+// read returns usage for every mount that passes shouldSkipPartition. Mounts
+// that cannot be read are left out; only hung ones are reported as an error.
+func (s *filesystemSampler) read() ([]FilesystemMetric, error) {
+	parts, err := disk.Partitions(false)
+	if err != nil {
+		return nil, err
+	}
+
+	var metrics []FilesystemMetric
+	index := make(map[string]int)
+	var hung []string
+	for _, part := range parts {
+		if shouldSkipPartition(part.Fstype, part.Mountpoint) {
+			continue
+		}
+		usage, err := s.usage(part.Mountpoint)
+		if errors.Is(err, errFilesystemHung) {
+			hung = append(hung, part.Mountpoint)
+			continue
+		}
+		if err != nil {
+			continue
+		}
+
+		m := newFilesystemMetric(part, usage)
+		// A mount stacked on the same path hides the earlier one
+		if i, ok := index[m.Mount]; ok {
+			metrics[i] = m
+			continue
+		}
+		index[m.Mount] = len(metrics)
+		metrics = append(metrics, m)
+	}
+	s.markRemounts(metrics, hung)
+
+	if len(hung) > 0 {
+		return metrics, fmt.Errorf("%w: %s", errFilesystemHung, strings.Join(hung, ", "))
+	}
+	return metrics, nil
+}
+
+// usage runs statfs on mount with a timeout. A mount whose previous call
+// has not returned yet is not queried again.
+func (s *filesystemSampler) usage(mount string) (*disk.UsageStat, error) {
+	s.mu.Lock()
+	if s.pending[mount] {
+		s.mu.Unlock()
+		return nil, errFilesystemHung
+	}
+	if s.pending == nil {
+		s.pending = make(map[string]bool)
+	}
+	s.pending[mount] = true
+	s.mu.Unlock()
+
+	type result struct {
+		usage *disk.UsageStat
+		err   error
+	}
+	done := make(chan result, 1)
+	go func() {
+		usage, err := disk.Usage(mount)
+		s.mu.Lock()
+		delete(s.pending, mount)
+		s.mu.Unlock()
+		done <- result{usage, err}
+	}()
+
+	select {
+	case r := <-done:
+		return r.usage, r.err
+	case <-time.After(filesystemUsageTimeout):
+		return nil, errFilesystemHung
+	}
+}
+
+// markRemounts sets RemountedReadOnly on read-only mounts that were writable
+// at an earlier sample, and forgets mounts that are gone. Hung mounts keep
+// their state.
+func (s *filesystemSampler) markRemounts(metrics []FilesystemMetric, hung []string) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	writable := make(map[string]bool, len(metrics))
+	for _, mount := range hung {
+		if s.writable[mount] {
+			writable[mount] = true
+		}
+	}
+	for i := range metrics {
+		m := &metrics[i]
+		if !m.ReadOnly {
+			writable[m.Mount] = true
+			continue
+		}
+		if s.writable[m.Mount] {
+			m.RemountedReadOnly = true
+			writable[m.Mount] = true // keep flagging until it is writable again
+		}
+	}
+	s.writable = writable
+}
+
+func newFilesystemMetric(part disk.PartitionStat, usage *disk.UsageStat) FilesystemMetric {
+	m := FilesystemMetric{
+		Device:            part.Device,
+		Mount:             part.Mountpoint,
+		FSType:            part.Fstype,
+		TotalBytes:        usage.Total,
+		UsedBytes:         usage.Used,
+		FreeBytes:         usage.Free,
+		UsedPercent:       usage.UsedPercent,
+		InodesTotal:       usage.InodesTotal,
+		InodesUsed:        usage.InodesUsed,
+		InodesFree:        usage.InodesFree,
+		InodesUsedPercent: usage.InodesUsedPercent,
+	}
+	for _, opt := range part.Opts {
+		if opt == "ro" {
+			m.ReadOnly = true
+			break
+		}
+	}
+	return m
+}
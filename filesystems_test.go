@@ -0,0 +1,70 @@
+package main
+
+import (
+	"testing"
+
+	"github.com/shirou/gopsutil/v3/disk"
+)
+
+func TestNewFilesystemMetric(t *testing.T) {
+	part := disk.PartitionStat{Device: "/dev/sdb1", Mountpoint: "/data", Fstype: "ext4", Opts: []string{"ro", "relatime"}}
+	usage := &disk.UsageStat{Total: 1000, Used: 900, Free: 100, UsedPercent: 90, InodesTotal: 50, InodesUsed: 49, InodesFree: 1, InodesUsedPercent: 98}
+
+	m := newFilesystemMetric(part, usage)
+	if m.Mount != "/data" || m.Device != "/dev/sdb1" || m.FSType != "ext4" {
+		t.Errorf("unexpected identity: %+v", m)
+	}
+	if m.TotalBytes != 1000 || m.UsedBytes != 900 || m.FreeBytes != 100 || m.UsedPercent != 90 {
+		t.Errorf("unexpected space usage: %+v", m)
+	}
+	if m.InodesTotal != 50 || m.InodesUsed != 49 || m.InodesFree != 1 || m.InodesUsedPercent != 98 {
+		t.Errorf("unexpected inode usage: %+v", m)
+	}
+	if !m.ReadOnly {
+		t.Error("expected ro mount to be read-only")
+	}
+
+	part.Opts = []string{"rw", "errors=remount-ro"}
+	if newFilesystemMetric(part, usage).ReadOnly {
+		t.Error("errors=remount-ro alone must not mark the mount read-only")
+	}
+}
+
+func TestFilesystemSampler_MarkRemounts(t *testing.T) {
+	s := &filesystemSampler{}
+	sample := func(readOnly map[string]bool, hung ...string) map[string]bool {
+		var metrics []FilesystemMetric
+		for mount, ro := range readOnly {
+			metrics = append(metrics, FilesystemMetric{Mount: mount, ReadOnly: ro})
+		}
+		s.markRemounts(metrics, hung)
+		remounted := make(map[string]bool)
+		for _, m := range metrics {
+			if m.RemountedReadOnly {
+				remounted[m.Mount] = true
+			}
+		}
+		return remounted
+	}
+
+	// Read-only from the start (e.g. a mounted ISO) is not a remount
+	if got := sample(map[string]bool{"/": false, "/iso": true}); len(got) != 0 {
+		t.Fatalf("unexpected remounts on first sample: %v", got)
+	}
+	if got := sample(map[string]bool{"/": true, "/iso": true}); !got["/"] || got["/iso"] {
+		t.Fatalf("expected only / to be flagged, got %v", got)
+	}
+	// Stays flagged while read-only, even across a hung sample
+	if got := sample(map[string]bool{"/iso": true}, "/"); len(got) != 0 {
+		t.Fatalf("unexpected remounts: %v", got)
+	}
+	if got := sample(map[string]bool{"/": true}); !got["/"] {
+		t.Fatalf("expected / to stay flagged, got %v", got)
+	}
+	// Writable again, then gone: the state is forgotten
+	sample(map[string]bool{"/": false})
+	sample(nil)
+	if got := sample(map[string]bool{"/": true}); len(got) != 0 {
+		t.Fatalf("expected remounted mount to be forgotten, got %v", got)
+	}
+}
@@ -0,0 +1,131 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// LinkEvent is an interface going up or down between two samples.
+type LinkEvent struct {
+	Timestamp string `json:"timestamp"`
+	Iface     string `json:"iface"`
+	State     string `json:"state"`      // "up" or "down"
+	OperState string `json:"oper_state"` // kernel operstate, e.g. "lowerlayerdown"
+}
+
+// ifaceLink is the state of one interface from /sys/class/net.
+type ifaceLink struct {
+	kind       string // uevent DEVTYPE: bond, bridge, vlan, wlan, ...
+	operState  string
+	carrier    *bool
+	speedMbps  int64
+	duplex     string
+	master     string
+	lower      []string
+	multicast  uint64
+	collisions uint64
+}
+
+// up treats an interface as up when the kernel says so, or when it reports
+// "unknown" (common for tun and some virtual drivers) but has carrier.
+func (l ifaceLink) up() bool {
+	if l.operState == "unknown" {
+		return l.carrier != nil && *l.carrier
+	}
+	return l.operState == "up"
+}
+
+// readIfaceLink reads link state and the counters gopsutil does not expose.
+// It returns false where sysfs has no entry for the interface.
+func readIfaceLink(name string) (ifaceLink, bool) {
+	dir := filepath.Join(sysRoot, "class", "net", name)
+	if _, err := os.Stat(dir); err != nil {
+		return ifaceLink{}, false
+	}
+	read := func(file string) string {
+		data, err := os.ReadFile(filepath.Join(dir, file))
+		if err != nil {
+			return "" // speed and duplex fail with EINVAL while the link is down
+		}
+		return strings.TrimSpace(string(data))
+	}
+	readUint := func(file string) uint64 {
+		v, _ := strconv.ParseUint(read(file), 10, 64)
+		return v
+	}
+
+	l := ifaceLink{
+		operState:  read("operstate"),
+		duplex:     read("duplex"),
+		multicast:  readUint("statistics/multicast"),
+		collisions: readUint("statistics/collisions"),
+	}
+	if l.duplex == "unknown" {
+		l.duplex = ""
+	}
+	if speed, err := strconv.ParseInt(read("speed"), 10, 64); err == nil && speed > 0 {
+		l.speedMbps = speed
+	}
+	switch read("carrier") {
+	case "0":
+		l.carrier = new(bool)
+	case "1":
+		carrier := true
+		l.carrier = &carrier
+	}
+	for _, line := range strings.Split(read("uevent"), "\n") {
+		if kind, ok := strings.CutPrefix(line, "DEVTYPE="); ok {
+			l.kind = kind
+		}
+	}
+	if target, err := os.Readlink(filepath.Join(dir, "master")); err == nil {
+		l.master = filepath.Base(target)
+	}
+	if entries, err := os.ReadDir(dir); err == nil {
+		for _, entry := range entries {
+			if lower, ok := strings.CutPrefix(entry.Name(), "lower_"); ok {
+				l.lower = append(l.lower, lower)
+			}
+		}
+		sort.Strings(l.lower)
+	}
+	return l, true
+}
+
+// linkEvents reports interfaces whose up/down state changed since prev.
+// Interfaces that appeared or vanished are not reported.
+func linkEvents(prev, next map[string]ifaceLink, now time.Time) []LinkEvent {
+	var events []LinkEvent
+	for name, curr := range next {
+		old, ok := prev[name]
+		if !ok || old.up() == curr.up() {
+			continue
+		}
+		state := "down"
+		if curr.up() {
+			state = "up"
+		}
+		events = append(events, LinkEvent{
+			Timestamp: now.UTC().Format(time.RFC3339Nano),
+			Iface:     name,
+			State:     state,
+			OperState: curr.operState,
+		})
+	}
+	sort.Slice(events, func(i, j int) bool { return events[i].Iface < events[j].Iface })
+	return events
+}
+
+// linkUtilization is the share of link speed used by bytes over elapsed,
+// or nil when the speed is unknown.
+func linkUtilization(bytes int64, elapsed time.Duration, speedMbps int64) *float64 {
+	if speedMbps <= 0 || elapsed <= 0 {
+		return nil
+	}
+	pct := min(100, float64(bytes)*8/elapsed.Seconds()/(float64(speedMbps)*1e6)*100)
+	return &pct
+}
@@ -0,0 +1,145 @@
+package main
+
+import (
+	"math"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	gnet "github.com/shirou/gopsutil/v3/net"
+)
+
+func TestReadIfaceLink(t *testing.T) {
+	dir := withSysRoot(t, map[string]string{
+		"class/net/eth0/operstate":             "up\n",
+		"class/net/eth0/carrier":               "1\n",
+		"class/net/eth0/speed":                 "1000\n",
+		"class/net/eth0/duplex":                "full\n",
+		"class/net/eth0/uevent":                "INTERFACE=eth0\nIFINDEX=2\n",
+		"class/net/eth0/statistics/multicast":  "42\n",
+		"class/net/eth0/statistics/collisions": "3\n",
+		"class/net/bond0/operstate":            "up\n",
+		"class/net/bond0/uevent":               "DEVTYPE=bond\nINTERFACE=bond0\n",
+		"class/net/bond0.100/operstate":        "lowerlayerdown\n",
+		"class/net/bond0.100/carrier":          "0\n",
+		"class/net/bond0.100/speed":            "-1\n",
+		"class/net/bond0.100/duplex":           "unknown\n",
+		"class/net/bond0.100/uevent":           "DEVTYPE=vlan\nINTERFACE=bond0.100\n",
+	})
+	net := filepath.Join(dir, "class", "net")
+	for link, target := range map[string]string{
+		"eth0/master":           "../bond0",
+		"bond0/lower_eth0":      "../eth0",
+		"bond0/lower_eth1":      "../eth1",
+		"bond0.100/lower_bond0": "../bond0",
+	} {
+		if err := os.Symlink(target, filepath.Join(net, link)); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	eth0, ok := readIfaceLink("eth0")
+	if !ok {
+		t.Fatal("expected eth0 to be found")
+	}
+	if eth0.operState != "up" || eth0.carrier == nil || !*eth0.carrier || eth0.speedMbps != 1000 || eth0.duplex != "full" {
+		t.Errorf("unexpected eth0 link: %+v", eth0)
+	}
+	if eth0.kind != "" || eth0.master != "bond0" || eth0.multicast != 42 || eth0.collisions != 3 {
+		t.Errorf("unexpected eth0 details: %+v", eth0)
+	}
+
+	bond, _ := readIfaceLink("bond0")
+	if bond.kind != "bond" || !reflect.DeepEqual(bond.lower, []string{"eth0", "eth1"}) {
+		t.Errorf("unexpected bond0: %+v", bond)
+	}
+	if bond.carrier != nil || bond.speedMbps != 0 {
+		t.Errorf("expected unknown carrier and speed for bond0, got %+v", bond)
+	}
+
+	vlan, _ := readIfaceLink("bond0.100")
+	if vlan.kind != "vlan" || !reflect.DeepEqual(vlan.lower, []string{"bond0"}) || vlan.up() {
+		t.Errorf("unexpected bond0.100: %+v", vlan)
+	}
+	if vlan.speedMbps != 0 || vlan.duplex != "" {
+		t.Errorf("expected unknown speed and duplex to be dropped, got %+v", vlan)
+	}
+
+	if _, ok := readIfaceLink("missing0"); ok {
+		t.Error("expected missing interface not to be found")
+	}
+}
+
+func TestLinkEvents(t *testing.T) {
+	carrier := true
+	prev := map[string]ifaceLink{
+		"eth0": {operState: "up"},
+		"eth1": {operState: "down"},
+		"tun0": {operState: "unknown", carrier: &carrier},
+		"eth2": {operState: "up"},
+	}
+	next := map[string]ifaceLink{
+		"eth0": {operState: "lowerlayerdown"},
+		"eth1": {operState: "up"},
+		"tun0": {operState: "unknown", carrier: &carrier},
+		"eth3": {operState: "down"}, // new, no event
+	}
+
+	events := linkEvents(prev, next, time.Unix(1700000000, 0))
+	if len(events) != 2 {
+		t.Fatalf("expected 2 events, got %+v", events)
+	}
+	if events[0].Iface != "eth0" || events[0].State != "down" || events[0].OperState != "lowerlayerdown" {
+		t.Errorf("unexpected first event: %+v", events[0])
+	}
+	if events[1].Iface != "eth1" || events[1].State != "up" {
+		t.Errorf("unexpected second event: %+v", events[1])
+	}
+}
+
+func TestIfaceDeltas(t *testing.T) {
+	start := time.Unix(1700000000, 0)
+	prev := ifaceSample{
+		at: start,
+		counters: map[string]gnet.IOCountersStat{
+			"eth0": {BytesRecv: 1000, BytesSent: 1000, Dropin: 5, Fifoin: 1},
+			"eth1": {BytesRecv: 500},
+		},
+		links: map[string]ifaceLink{"eth0": {operState: "up", speedMbps: 100, multicast: 10, collisions: 2}},
+	}
+	next := ifaceSample{
+		at: start.Add(10 * time.Second),
+		counters: map[string]gnet.IOCountersStat{
+			// 62.5 MB in 10s is 50 Mbit/s: half of a 100 Mbit/s link
+			"eth0": {BytesRecv: 1000 + 62_500_000, BytesSent: 1000, Dropin: 8, Dropout: 1, Fifoin: 2},
+			"eth1": {BytesRecv: 100}, // counters reset
+		},
+		links: map[string]ifaceLink{"eth0": {operState: "up", speedMbps: 100, multicast: 15, collisions: 2, master: "bond0"}},
+	}
+
+	metrics := ifaceDeltas(prev, next)
+	if len(metrics) != 1 {
+		t.Fatalf("expected only eth0, got %+v", metrics)
+	}
+	m := metrics[0]
+	if m.DropsIn != 3 || m.DropsOut != 1 || m.FifoErrorsIn != 1 || m.MulticastIn != 5 || m.Collisions != 0 {
+		t.Errorf("unexpected error counters: %+v", m)
+	}
+	if m.UtilInPercent == nil || math.Abs(*m.UtilInPercent-50) > 1e-9 {
+		t.Errorf("expected 50%% inbound utilisation, got %v", m.UtilInPercent)
+	}
+	if m.UtilOutPercent == nil || *m.UtilOutPercent != 0 {
+		t.Errorf("expected 0%% outbound utilisation, got %v", m.UtilOutPercent)
+	}
+	if m.Master != "bond0" || m.SpeedMbps != 100 || m.OperState != "up" {
+		t.Errorf("unexpected link state: %+v", m)
+	}
+
+	// Unknown speed: no utilisation
+	next.links["eth0"] = ifaceLink{operState: "up"}
+	if m := ifaceDeltas(prev, next); m[0].UtilInPercent != nil {
+		t.Errorf("expected no utilisation without link speed, got %v", *m[0].UtilInPercent)
+	}
+}
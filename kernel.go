@@ -0,0 +1,338 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// kmsgPath is read when journalctl -k is unavailable; a variable for tests.
+var kmsgPath = "/dev/kmsg"
+
+// oomKillRetention is how long an OOM kill stays available for matching
+// against watchdog crashes, which are only checked every few minutes.
+const oomKillRetention = 15 * time.Minute
+
+// maxPendingKernelEvents bounds events kept for retry while the backend is down.
+const maxPendingKernelEvents = 1000
+
+// KernelEvent is a structured event extracted from kernel messages.
+type KernelEvent struct {
+	Timestamp      string `json:"timestamp"`
+	Type           string `json:"type"` // oom_kill, segfault, hung_task, fs_error, io_error, mce
+	PID            int32  `json:"pid,omitempty"`
+	Process        string `json:"process,omitempty"`
+	RSSKB          int64  `json:"rss_kb,omitempty"` // oom_kill: anon + file + shmem RSS of the victim
+	Cgroup         string `json:"cgroup,omitempty"` // oom_kill: memory cgroup of the victim
+	Device         string `json:"device,omitempty"`
+	Module         string `json:"module,omitempty"` // segfault: object the fault happened in
+	BlockedSeconds int    `json:"blocked_seconds,omitempty"`
+	Message        string `json:"message"`
+}
+
+// KernelEventPayload is sent to POST /api/ingest/server-kernel-events
+type KernelEventPayload struct {
+	Events []KernelEvent `json:"events"`
+}
+
+var (
+	oomKilledRe  = regexp.MustCompile(`(?:Out of memory|Memory cgroup out of memory): Killed process (\d+) \(([^)]*)\)(?:.*?anon-rss:(\d+)kB, file-rss:(\d+)kB, shmem-rss:(\d+)kB)?`)
+	oomContextRe = regexp.MustCompile(`^oom-kill:.*?task_memcg=([^,]*),task=([^,]*),pid=(\d+)`)
+	segfaultRe   = regexp.MustCompile(`^(.+?)\[(\d+)\]: segfault at \S+ ip \S+ sp \S+ error \d+(?: in ([^\[\s]+))?`)
+	hungTaskRe   = regexp.MustCompile(`task (.+):(\d+) blocked for more than (\d+) seconds`)
+	fsErrorRe    = regexp.MustCompile(`^(EXT[234]-fs|BTRFS|F2FS)(?: error| critical|-fs error)? \(device ([^)]+)\)`)
+	xfsErrorRe   = regexp.MustCompile(`^XFS \(([^)]+)\): .*(?:Corruption|I/O error|shut down|SHUTDOWN)`)
+	ioErrorRe    = regexp.MustCompile(`I/O error, dev (\S+?),`)
+	mceRe        = regexp.MustCompile(`^(?:mce: \[Hardware Error\]|\[Hardware Error\]|EDAC |Machine check events logged)`)
+)
+
+// parseKernelMessage classifies a kernel message. ext4 warnings (e.g. "EXT4-fs
+// (sda1): mounted") do not match the error patterns and are ignored.
+func parseKernelMessage(ts, msg string) (KernelEvent, bool) {
+	ev := KernelEvent{Timestamp: ts, Message: msg}
+
+	if m := oomKilledRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = "oom_kill"
+		pid, _ := strconv.Atoi(m[1])
+		ev.PID, ev.Process = int32(pid), m[2]
+		for _, kb := range m[3:6] {
+			n, _ := strconv.ParseInt(kb, 10, 64)
+			ev.RSSKB += n
+		}
+	} else if m := segfaultRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = "segfault"
+		pid, _ := strconv.Atoi(m[2])
+		ev.Process, ev.PID, ev.Module = m[1], int32(pid), m[3]
+	} else if m := hungTaskRe.FindStringSubmatch(msg); m != nil {
+		ev.Type = "hung_task"
+		pid, _ := strconv.Atoi(m[2])
+		ev.Process, ev.PID = m[1], int32(pid)
+		ev.BlockedSeconds, _ = strconv.Atoi(m[3])
+	} else if m := fsErrorRe.FindStringSubmatch(msg); m != nil && strings.Contains(strings.ToLower(msg), "error") {
+		ev.Type, ev.Device = "fs_error", m[2]
+	} else if m := xfsErrorRe.FindStringSubmatch(msg); m != nil {
+		ev.Type, ev.Device = "fs_error", m[1]
+	} else if m := ioErrorRe.FindStringSubmatch(msg); m != nil {
+		ev.Type, ev.Device = "io_error", m[1]
+	} else if mceRe.MatchString(msg) {
+		ev.Type = "mce"
+	} else {
+		return ev, false
+	}
+	return ev, true
+}
+
+// oomKillLog remembers recent OOM kills so watchdog crashes can be explained.
+type oomKillLog struct {
+	mu     sync.Mutex
+	recent []oomKill
+}
+
+type oomKill struct {
+	event KernelEvent
+	seen  time.Time
+}
+
+var oomKills = &oomKillLog{}
+
+func (l *oomKillLog) record(ev KernelEvent) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	now := time.Now()
+	kept := l.recent[:0]
+	for _, k := range l.recent {
+		if now.Sub(k.seen) < oomKillRetention {
+			kept = append(kept, k)
+		}
+	}
+	l.recent = append(kept, oomKill{event: ev, seen: now})
+}
+
+// match returns the most recent OOM kill of one of pids, or else of a process
+// with the given name (the kernel truncates names to 15 characters).
+func (l *oomKillLog) match(name string, pids []int32) *KernelEvent {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	for i := len(l.recent) - 1; i >= 0; i-- {
+		ev := l.recent[i].event
+		if time.Since(l.recent[i].seen) >= oomKillRetention {
+			break
+		}
+		for _, pid := range pids {
+			if pid == ev.PID {
+				return &ev
+			}
+		}
+		if ev.Process != "" && (ev.Process == name || (len(name) > 15 && ev.Process == name[:15])) {
+			return &ev
+		}
+	}
+	return nil
+}
+
+// kernelMonitor reads kernel messages from the journal, falling back to
+// /dev/kmsg, and queues structured events until the backend accepts them.
+type kernelMonitor struct {
+	mu      sync.Mutex
+	journal *journalState
+	kmsg    *os.File
+	cgroups map[int32]string // pid -> memcg from the oom-kill context line
+	pending []KernelEvent
+	redact  func(fields ...*string)
+}
+
+func newKernelMonitor() *kernelMonitor {
+	return &kernelMonitor{
+		journal: &journalState{},
+		cgroups: make(map[int32]string),
+		redact:  func(...*string) {},
+	}
+}
+
+// observe classifies kernel messages and queues the resulting events.
+func (k *kernelMonitor) observe(entries []LogEntry) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+
+	for _, e := range entries {
+		// The oom-kill context line precedes "Killed process" and carries the cgroup
+		if m := oomContextRe.FindStringSubmatch(e.Message); m != nil {
+			pid, _ := strconv.Atoi(m[3])
+			k.cgroups[int32(pid)] = m[1]
+			continue
+		}
+		ev, ok := parseKernelMessage(e.Timestamp, e.Message)
+		if !ok {
+			continue
+		}
+		// Messages can quote file paths and process names
+		k.redact(&ev.Message)
+		if ev.Type == "oom_kill" {
+			ev.Cgroup = k.cgroups[ev.PID]
+			delete(k.cgroups, ev.PID)
+			oomKills.record(ev)
+		}
+		k.pending = append(k.pending, ev)
+	}
+	if len(k.pending) > maxPendingKernelEvents {
+		k.pending = k.pending[len(k.pending)-maxPendingKernelEvents:]
+	}
+	// Context lines without a matching kill should not accumulate
+	if len(k.cgroups) > 100 {
+		k.cgroups = make(map[int32]string)
+	}
+}
+
+func (k *kernelMonitor) take() []KernelEvent {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	events := k.pending
+	k.pending = nil
+	return events
+}
+
+func (k *kernelMonitor) restore(events []KernelEvent) {
+	k.mu.Lock()
+	defer k.mu.Unlock()
+	k.pending = append(events, k.pending...)
+	if len(k.pending) > maxPendingKernelEvents {
+		k.pending = k.pending[len(k.pending)-maxPendingKernelEvents:]
+	}
+}
+
+// collect reads new kernel messages.
+func (k *kernelMonitor) collect(since time.Duration) ([]LogEntry, error) {
+	entries, err := readJournal(k.journal, since, "-k")
+	if err == nil && (len(entries) > 0 || k.journal.get() != "") {
+		return entries, nil
+	}
+	return k.readKmsg()
+}
+
+// readKmsg returns the records appended to /dev/kmsg since the previous call.
+// The first call only positions at the end of the ring buffer.
+func (k *kernelMonitor) readKmsg() ([]LogEntry, error) {
+	if k.kmsg == nil {
+		f, err := os.OpenFile(kmsgPath, os.O_RDONLY|syscall.O_NONBLOCK, 0)
+		if err != nil {
+			return nil, fmt.Errorf("open %s: %w", kmsgPath, err)
+		}
+		if _, err := f.Seek(0, io.SeekEnd); err != nil {
+			f.Close()
+			return nil, err
+		}
+		k.kmsg = f
+		return nil, nil
+	}
+
+	boot := time.Now()
+	if bt, err := host.BootTime(); err == nil {
+		boot = time.Unix(int64(bt), 0)
+	}
+
+	var entries []LogEntry
+	buf := make([]byte, 8192)
+	for len(entries) < maxLogScanEntries {
+		k.kmsg.SetReadDeadline(time.Now().Add(100 * time.Millisecond))
+		n, err := k.kmsg.Read(buf)
+		if err != nil {
+			if errors.Is(err, syscall.EPIPE) {
+				continue // records were overwritten before we read them
+			}
+			if errors.Is(err, syscall.EAGAIN) || errors.Is(err, os.ErrDeadlineExceeded) || err == io.EOF {
+				break
+			}
+			return entries, err
+		}
+		if e, ok := parseKmsgRecord(buf[:n], boot); ok {
+			entries = append(entries, e)
+		}
+	}
+	return entries, nil
+}
+
+// parseKmsgRecord decodes "<prio>,<seq>,<usec since boot>,<flags>;<message>".
+func parseKmsgRecord(rec []byte, boot time.Time) (LogEntry, bool) {
+	head, msg, found := bytes.Cut(rec, []byte{';'})
+	if !found {
+		return LogEntry{}, false
+	}
+	// Continuation lines (" KEY=value") follow the first line
+	msg, _, _ = bytes.Cut(msg, []byte{'\n'})
+
+	fields := strings.Split(string(head), ",")
+	if len(fields) < 3 {
+		return LogEntry{}, false
+	}
+	prio, _ := strconv.Atoi(fields[0])
+	usec, _ := strconv.ParseInt(fields[2], 10, 64)
+
+	return LogEntry{
+		Timestamp: boot.Add(time.Duration(usec) * time.Microsecond).UTC().Format(time.RFC3339Nano),
+		Level:     mapJournalPriority(strconv.Itoa(prio & 7)),
+		Service:   "kernel",
+		Message:   string(msg),
+		Source:    "kmsg",
+	}, true
+}
+
+// sendKernelEventsToBackend collects kernel events and ships the queue.
+func sendKernelEventsToBackend(client *http.Client, cfg Config, logger *log.Logger, since time.Duration) {
+	k := cfg.Kernel
+	entries, err := k.collect(since)
+	if err != nil {
+		logger.Printf("kernel event collect error: %v", err)
+	}
+	k.observe(entries)
+
+	events := k.take()
+	if len(events) == 0 {
+		return
+	}
+	if err := sendKernelEvents(client, cfg, KernelEventPayload{Events: events}); err != nil {
+		k.restore(events)
+		logger.Printf("kernel events ingest failed: %v", err)
+	} else {
+		logger.Printf("kernel events sent: %d", len(events))
+	}
+}
+
+// sendKernelEvents sends kernel events to backend
+func sendKernelEvents(client *http.Client, cfg Config, payload KernelEventPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := cfg.BaseURL + "/api/ingest/server-kernel-events"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,108 @@
+package main
+
+import (
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- parseKernelMessage Tests ---
+
+func TestParseKernelMessage(t *testing.T) {
+	tests := []struct {
+		msg  string
+		want KernelEvent
+	}{
+		{"Out of memory: Killed process 4242 (java) total-vm:8123456kB, anon-rss:2000000kB, file-rss:1000kB, shmem-rss:24kB, UID:1000 pgtables:5000kB oom_score_adj:0",
+			KernelEvent{Type: "oom_kill", PID: 4242, Process: "java", RSSKB: 2001024}},
+		{"Memory cgroup out of memory: Killed process 77 (node) total-vm:100kB, anon-rss:50kB, file-rss:0kB, shmem-rss:0kB",
+			KernelEvent{Type: "oom_kill", PID: 77, Process: "node", RSSKB: 50}},
+		{"nginx[991]: segfault at 0 ip 00007f2a1b2c3d4e sp 00007ffd5e6f7a80 error 4 in libc.so.6[7f2a1b200000+195000]",
+			KernelEvent{Type: "segfault", PID: 991, Process: "nginx", Module: "libc.so.6"}},
+		{"INFO: task kworker/u16:2:318 blocked for more than 122 seconds.",
+			KernelEvent{Type: "hung_task", PID: 318, Process: "kworker/u16:2", BlockedSeconds: 122}},
+		{"EXT4-fs error (device sda1): ext4_find_entry:1455: inode #2: comm ls: reading directory lblock 0",
+			KernelEvent{Type: "fs_error", Device: "sda1"}},
+		{"XFS (dm-0): Metadata Corruption detected at xfs_buf_ioend+0x5a/0x1c0",
+			KernelEvent{Type: "fs_error", Device: "dm-0"}},
+		{"blk_update_request: I/O error, dev sdb, sector 2048 op 0x0:(READ) flags 0x0",
+			KernelEvent{Type: "io_error", Device: "sdb"}},
+		{"mce: [Hardware Error]: Machine check events logged",
+			KernelEvent{Type: "mce"}},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseKernelMessage("", tt.msg)
+		if !ok {
+			t.Errorf("%q: expected event", tt.msg)
+			continue
+		}
+		got.Message = ""
+		if got != tt.want {
+			t.Errorf("%q:\n got  %+v\n want %+v", tt.msg, got, tt.want)
+		}
+	}
+
+	for _, msg := range []string{"EXT4-fs (sda1): mounted filesystem with ordered data mode", "XFS (sda2): Mounting V5 Filesystem"} {
+		if _, ok := parseKernelMessage("", msg); ok {
+			t.Errorf("%q: expected informational message to be ignored", msg)
+		}
+	}
+}
+
+func TestParseKmsgRecord(t *testing.T) {
+	boot := time.Date(2026, 3, 1, 0, 0, 0, 0, time.UTC)
+	e, ok := parseKmsgRecord([]byte("3,1234,5000000,-;Out of memory: Killed process 1 (x)\n SUBSYSTEM=mem\n"), boot)
+	if !ok {
+		t.Fatal("expected record to parse")
+	}
+	if e.Level != "error" || e.Message != "Out of memory: Killed process 1 (x)" || e.Timestamp != "2026-03-01T00:00:05Z" {
+		t.Errorf("unexpected entry: %+v", e)
+	}
+}
+
+// --- OOM / watchdog linking Tests ---
+
+func TestKernelMonitor_OOMKillCgroupAndWatchdogLink(t *testing.T) {
+	oomKills = &oomKillLog{}
+	t.Cleanup(func() { oomKills = &oomKillLog{} })
+
+	k := newKernelMonitor()
+	k.observe([]LogEntry{
+		{Message: "oom-kill:constraint=CONSTRAINT_MEMCG,nodemask=(null),cpuset=/,mems_allowed=0,oom_memcg=/system.slice/api.service,task_memcg=/system.slice/api.service,task=java,pid=4242,uid=1000"},
+		{Message: "Memory cgroup out of memory: Killed process 4242 (java) total-vm:100kB, anon-rss:64kB, file-rss:0kB, shmem-rss:0kB"},
+	})
+
+	events := k.take()
+	if len(events) != 1 || events[0].Cgroup != "/system.slice/api.service" {
+		t.Fatalf("expected one oom_kill with cgroup, got %+v", events)
+	}
+
+	if kill := oomKills.match("java", []int32{4242, 4300}); kill == nil || kill.PID != 4242 {
+		t.Errorf("expected crash of PID 4242 linked to the OOM kill, got %+v", kill)
+	}
+	if kill := oomKills.match("", missingPIDs([]int32{10, 4242}, []int32{10, 5000})); kill == nil {
+		t.Error("expected restart linked through the missing PID")
+	}
+	if kill := oomKills.match("python3", []int32{1}); kill != nil {
+		t.Errorf("expected unrelated process not linked, got %+v", kill)
+	}
+}
+
+func TestKernelMonitor_RedactsMessages(t *testing.T) {
+	pipeline, err := newLogPipeline(LogRules{Redact: RedactConfig{Rules: []RedactRuleConfig{{Name: "secret", Pattern: `hunter2`}}}})
+	if err != nil {
+		t.Fatal(err)
+	}
+	k := newKernelMonitor()
+	k.redact = pipeline.redactFields
+	k.observe([]LogEntry{{Message: "EXT4-fs error (device sda1): ext4_lookup:1855: inode #2: comm backup: deleted inode referenced: /srv/hunter2.key"}})
+
+	events := k.take()
+	if len(events) != 1 || events[0].Device != "sda1" {
+		t.Fatalf("expected one filesystem error, got %+v", events)
+	}
+	if strings.Contains(events[0].Message, "hunter2") {
+		t.Errorf("expected message redacted, got %q", events[0].Message)
+	}
+}
@@ -0,0 +1,126 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// KernelStatMetric is kernel-wide resource usage whose exhaustion breaks
+// every process on the host at once. Linux only.
+type KernelStatMetric struct {
+	FileHandles            uint64   `json:"file_handles"` // allocated minus unused, from fs/file-nr
+	FileHandlesMax         uint64   `json:"file_handles_max"`
+	FileHandlesUsedPercent float64  `json:"file_handles_used_percent"`
+	Tasks                  uint64   `json:"tasks"` // processes and threads, each holding a PID
+	PIDMax                 uint64   `json:"pid_max"`
+	PIDsUsedPercent        float64  `json:"pids_used_percent"`
+	EntropyAvailBits       *uint64  `json:"entropy_avail_bits,omitempty"`
+	ContextSwitchesPS      *float64 `json:"context_switches_per_sec,omitempty"`
+	InterruptsPS           *float64 `json:"interrupts_per_sec,omitempty"`
+	ForksPS                *float64 `json:"forks_per_sec,omitempty"`
+}
+
+// kernelStatSampler keeps the previous /proc/stat counters for rate computation.
+type kernelStatSampler struct {
+	mu   sync.Mutex
+	prev map[string]uint64
+	at   time.Time
+}
+
+var kernelStatRates = &kernelStatSampler{}
+
+// readKernelStat reads file handle, PID and entropy usage and the context
+// switch, interrupt and fork rates since the previous call. It returns nil
+// without error where /proc is missing.
+func readKernelStat() (*KernelStatMetric, error) {
+	fileNr, err := readProcUints(filepath.Join("sys", "fs", "file-nr"))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("file-nr: %w", err)
+	}
+	if len(fileNr) < 3 {
+		return nil, errors.New("file-nr: malformed")
+	}
+	m := &KernelStatMetric{FileHandlesMax: fileNr[2]}
+	if fileNr[0] > fileNr[1] {
+		m.FileHandles = fileNr[0] - fileNr[1]
+	}
+	if m.FileHandlesMax > 0 {
+		m.FileHandlesUsedPercent = float64(m.FileHandles) / float64(m.FileHandlesMax) * 100
+	}
+
+	if pidMax, err := readProcUints(filepath.Join("sys", "kernel", "pid_max")); err == nil && len(pidMax) > 0 {
+		m.PIDMax = pidMax[0]
+	}
+	// The fourth field of loadavg is "running/total" scheduling entities
+	if data, err := os.ReadFile(filepath.Join(procRoot, "loadavg")); err == nil {
+		if fields := strings.Fields(string(data)); len(fields) >= 4 {
+			if _, total, ok := strings.Cut(fields[3], "/"); ok {
+				m.Tasks, _ = strconv.ParseUint(total, 10, 64)
+			}
+		}
+	}
+	if m.PIDMax > 0 {
+		m.PIDsUsedPercent = float64(m.Tasks) / float64(m.PIDMax) * 100
+	}
+
+	if entropy, err := readProcUints(filepath.Join("sys", "kernel", "random", "entropy_avail")); err == nil && len(entropy) > 0 {
+		m.EntropyAvailBits = &entropy[0]
+	}
+
+	if stat, err := readProcStat(); err == nil {
+		kernelStatRates.apply(m, stat, time.Now())
+	}
+	return m, nil
+}
+
+// apply sets the rates of m from the counter deltas since the previous call
+// and stores the new counters.
+func (s *kernelStatSampler) apply(m *KernelStatMetric, stat map[string]uint64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, at := s.prev, s.at
+	s.prev, s.at = stat, now
+
+	elapsed := now.Sub(at).Seconds()
+	if prev == nil || elapsed <= 0 {
+		return
+	}
+	rate := func(key string) *float64 {
+		cur, ok1 := stat[key]
+		old, ok2 := prev[key]
+		if !ok1 || !ok2 || cur < old {
+			return nil
+		}
+		v := float64(cur-old) / elapsed
+		return &v
+	}
+	m.ContextSwitchesPS = rate("ctxt")
+	m.InterruptsPS = rate("intr")
+	m.ForksPS = rate("processes") // forks since boot
+}
+
+// readProcUints parses a whitespace-separated line of numbers under procRoot.
+func readProcUints(name string) ([]uint64, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, name))
+	if err != nil {
+		return nil, err
+	}
+	var values []uint64
+	for _, field := range strings.Fields(string(data)) {
+		v, err := strconv.ParseUint(field, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", name, err)
+		}
+		values = append(values, v)
+	}
+	return values, nil
+}
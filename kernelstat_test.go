@@ -0,0 +1,60 @@
+package main
+
+import (
+	"math"
+	"testing"
+	"time"
+)
+
+func TestReadKernelStat(t *testing.T) {
+	old := kernelStatRates
+	kernelStatRates = &kernelStatSampler{}
+	t.Cleanup(func() { kernelStatRates = old })
+
+	withProcRoot(t, map[string]string{
+		"sys/fs/file-nr":                  "9000\t1000\t40000\n",
+		"sys/kernel/pid_max":              "32768\n",
+		"sys/kernel/random/entropy_avail": "256\n",
+		"loadavg":                         "0.12 0.10 0.09 3/8192 17284\n",
+		"stat":                            "cpu  1 2 3 4\nctxt 1000\nintr 500 10 20\nprocesses 300\n",
+	})
+
+	m, err := readKernelStat()
+	if err != nil {
+		t.Fatalf("readKernelStat: %v", err)
+	}
+	if m.FileHandles != 8000 || m.FileHandlesMax != 40000 || m.FileHandlesUsedPercent != 20 {
+		t.Errorf("unexpected file handles: %+v", m)
+	}
+	if m.Tasks != 8192 || m.PIDMax != 32768 || m.PIDsUsedPercent != 25 {
+		t.Errorf("unexpected PID usage: %+v", m)
+	}
+	if m.EntropyAvailBits == nil || *m.EntropyAvailBits != 256 {
+		t.Errorf("unexpected entropy: %v", m.EntropyAvailBits)
+	}
+}
+
+func TestKernelStatSampler_Rates(t *testing.T) {
+	s := &kernelStatSampler{}
+	start := time.Unix(1700000000, 0)
+
+	var m KernelStatMetric
+	s.apply(&m, map[string]uint64{"ctxt": 1000, "intr": 500, "processes": 300}, start)
+	if m.ContextSwitchesPS != nil || m.InterruptsPS != nil || m.ForksPS != nil {
+		t.Fatalf("expected no rates on the first sample, got %+v", m)
+	}
+
+	s.apply(&m, map[string]uint64{"ctxt": 21000, "intr": 10500, "processes": 320}, start.Add(10*time.Second))
+	near := func(p *float64, v float64) bool { return p != nil && math.Abs(*p-v) < 1e-9 }
+	if !near(m.ContextSwitchesPS, 2000) || !near(m.InterruptsPS, 1000) || !near(m.ForksPS, 2) {
+		t.Errorf("unexpected rates: ctxt %v intr %v forks %v", m.ContextSwitchesPS, m.InterruptsPS, m.ForksPS)
+	}
+}
+
+func TestReadKernelStat_Missing(t *testing.T) {
+	withProcRoot(t, map[string]string{})
+	m, err := readKernelStat()
+	if m != nil || err != nil {
+		t.Errorf("expected nil without /proc, got %+v, %v", m, err)
+	}
+}
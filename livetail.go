@@ -0,0 +1,309 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"net/url"
+	"os"
+	"os/exec"
+	"sync"
+	"time"
+)
+
+const (
+	liveTailPollInterval   = 5 * time.Second
+	liveTailIdlePoll       = 5 * time.Minute // after the backend answers 404
+	liveTailReadInterval   = 500 * time.Millisecond
+	liveTailInitialLines   = 20
+	defaultLiveTailTimeout = 5 * time.Minute
+	maxLiveTailTimeout     = 30 * time.Minute
+)
+
+// LiveTailSession is a backend request to stream one source in near real time.
+// Exactly one of Unit (journald) or Path (log file) is set.
+type LiveTailSession struct {
+	ID             string `json:"id"`
+	Unit           string `json:"unit,omitempty"`
+	Path           string `json:"path,omitempty"`
+	TimeoutSeconds int    `json:"timeout_seconds"`
+}
+
+func (s LiveTailSession) timeout() time.Duration {
+	d := time.Duration(s.TimeoutSeconds) * time.Second
+	if d <= 0 {
+		return defaultLiveTailTimeout
+	}
+	return min(d, maxLiveTailTimeout)
+}
+
+// liveTailManager polls the backend for live tail sessions and streams each
+// one as NDJSON over a chunked HTTP request until it times out, the backend
+// closes the stream, or the agent stops.
+type liveTailManager struct {
+	cfg    Config
+	logger *log.Logger
+	client *http.Client // polling; streams use their own client without a timeout
+
+	ctx    context.Context
+	cancel context.CancelFunc
+	wg     sync.WaitGroup
+
+	mu     sync.Mutex
+	active map[string]bool
+}
+
+// startLiveTail begins polling for sessions in the background.
+func startLiveTail(cfg Config, logger *log.Logger) *liveTailManager {
+	ctx, cancel := context.WithCancel(context.Background())
+	m := &liveTailManager{
+		cfg:    cfg,
+		logger: logger,
+		client: &http.Client{Timeout: cfg.Timeout},
+		ctx:    ctx,
+		cancel: cancel,
+		active: make(map[string]bool),
+	}
+	m.wg.Add(1)
+	go m.pollLoop()
+	return m
+}
+
+// close ends every session and waits for the streams to finish.
+func (m *liveTailManager) close() {
+	if m == nil {
+		return
+	}
+	m.cancel()
+	m.wg.Wait()
+}
+
+func (m *liveTailManager) pollLoop() {
+	defer m.wg.Done()
+	wait := time.Duration(0)
+	unsupported := false
+	for {
+		select {
+		case <-m.ctx.Done():
+			return
+		case <-time.After(wait):
+		}
+
+		sessions, err := fetchLiveTailSessions(m.client, m.cfg)
+		wait = liveTailPollInterval
+		if errors.Is(err, errLiveTailUnsupported) {
+			if !unsupported {
+				m.logger.Printf("live tail: backend has no live tail endpoint, polling every %s", liveTailIdlePoll)
+			}
+			unsupported, wait = true, liveTailIdlePoll
+			continue
+		}
+		unsupported = false
+		if err != nil {
+			m.logger.Printf("live tail poll failed: %v", err)
+			continue
+		}
+		for _, s := range sessions {
+			m.start(s)
+		}
+	}
+}
+
+// start launches a session unless it is already streaming or invalid.
+func (m *liveTailManager) start(s LiveTailSession) {
+	if s.ID == "" || (s.Unit == "") == (s.Path == "") {
+		m.logger.Printf("live tail: ignoring invalid session %q", s.ID)
+		return
+	}
+	if s.Path != "" && !m.cfg.LogScanner.allows(s.Path) {
+		m.logger.Printf("live tail: path %s is outside the discovery roots/patterns, refusing session %s", s.Path, s.ID)
+		return
+	}
+
+	m.mu.Lock()
+	if m.active[s.ID] {
+		m.mu.Unlock()
+		return
+	}
+	m.active[s.ID] = true
+	m.mu.Unlock()
+
+	m.wg.Add(1)
+	go func() {
+		defer m.wg.Done()
+		defer func() {
+			m.mu.Lock()
+			delete(m.active, s.ID)
+			m.mu.Unlock()
+		}()
+
+		ctx, cancel := context.WithTimeout(m.ctx, s.timeout())
+		defer cancel()
+		m.logger.Printf("live tail %s started (unit=%q path=%q timeout=%s)", s.ID, s.Unit, s.Path, s.timeout())
+		sent, err := m.stream(ctx, cancel, s)
+		if err != nil && ctx.Err() == nil {
+			m.logger.Printf("live tail %s ended: %v", s.ID, err)
+			return
+		}
+		m.logger.Printf("live tail %s ended: %d lines streamed", s.ID, sent)
+	}()
+}
+
+// stream posts the session's lines as they appear. The request body is a pipe,
+// so net/http sends it chunked and flushes after every write.
+func (m *liveTailManager) stream(ctx context.Context, cancel context.CancelFunc, s LiveTailSession) (int, error) {
+	pr, pw := io.Pipe()
+	sent := 0
+
+	produced := make(chan error, 1)
+	go func() {
+		enc := json.NewEncoder(pw)
+		write := func(entries []LogEntry) error {
+			if len(entries) == 0 {
+				return nil
+			}
+			payload := LogIngestPayload{Entries: entries}
+			m.cfg.Pipeline.prepare(&payload)
+			for _, e := range payload.Entries {
+				if err := enc.Encode(e); err != nil {
+					return err
+				}
+				sent++
+			}
+			return nil
+		}
+
+		var err error
+		if s.Unit != "" {
+			err = followJournal(ctx, s.Unit, write)
+		} else {
+			err = followFile(ctx, s.Path, write)
+		}
+		pw.CloseWithError(err)
+		produced <- err
+	}()
+
+	endpoint := m.cfg.BaseURL + "/api/ingest/live-tail/" + url.PathEscape(s.ID)
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint, pr)
+	if err != nil {
+		cancel()
+		<-produced
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/x-ndjson")
+	req.Header.Set("X-Agent-Token", m.cfg.Token)
+
+	resp, err := (&http.Client{}).Do(req)
+	// The backend answering (or failing) ends the session either way
+	cancel()
+	pr.Close()
+	prodErr := <-produced
+	if err != nil {
+		return sent, err
+	}
+	resp.Body.Close()
+	if resp.StatusCode >= 400 {
+		return sent, fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	if prodErr != nil && !errors.Is(prodErr, context.Canceled) && !errors.Is(prodErr, context.DeadlineExceeded) {
+		return sent, prodErr
+	}
+	return sent, nil
+}
+
+// followJournal streams new entries of a systemd unit via journalctl --follow.
+func followJournal(ctx context.Context, unit string, write func([]LogEntry) error) error {
+	cmd := exec.CommandContext(ctx, "journalctl", "--output", "json", "--no-pager",
+		"--follow", "--lines", "0", "--unit", unit)
+	out, err := cmd.StdoutPipe()
+	if err != nil {
+		return err
+	}
+	if err := cmd.Start(); err != nil {
+		return fmt.Errorf("journalctl: %w", err)
+	}
+	defer cmd.Wait()
+
+	hostname, _ := os.Hostname()
+	scanner := bufio.NewScanner(out)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024)
+	for scanner.Scan() {
+		if entry, _, ok := parseJournalLine(scanner.Bytes(), hostname); ok {
+			if err := write([]LogEntry{entry}); err != nil {
+				return err
+			}
+		}
+	}
+	if ctx.Err() != nil {
+		return ctx.Err()
+	}
+	return scanner.Err()
+}
+
+// followFile polls a file every liveTailReadInterval with its own tailer, so
+// the regular file shipping keeps its position.
+func followFile(ctx context.Context, path string, write func([]LogEntry) error) error {
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	initial := liveTailInitialLines
+	ticker := time.NewTicker(liveTailReadInterval)
+	defer ticker.Stop()
+
+	for {
+		lines, err := tailer.readNewLines(path, initial)
+		if err != nil {
+			return err
+		}
+		initial = 0
+		if err := write(fileLogEntries(path, lines)); err != nil {
+			return err
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// errLiveTailUnsupported means the backend has no live tail endpoint.
+var errLiveTailUnsupported = errors.New("live tail not supported by backend")
+
+// fetchLiveTailSessions retrieves the pending live tail sessions for this agent
+func fetchLiveTailSessions(client *http.Client, cfg Config) ([]LiveTailSession, error) {
+	endpoint := cfg.BaseURL + "/api/servers/me/live-tail"
+	ctx, cancel := context.WithTimeout(context.Background(), cfg.Timeout)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, errLiveTailUnsupported
+	}
+	if resp.StatusCode >= 300 {
+		return nil, fmt.Errorf("status=%d", resp.StatusCode)
+	}
+
+	var result struct {
+		Sessions []LiveTailSession `json:"sessions"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return nil, err
+	}
+	return result.Sessions, nil
+}
@@ -0,0 +1,148 @@
+package main
+
+import (
+	"bufio"
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func TestFetchLiveTailSessions(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/servers/me/live-tail" || r.Header.Get("X-Agent-Token") != "tok" {
+			http.NotFound(w, r)
+			return
+		}
+		w.Write([]byte(`{"sessions":[{"id":"s1","unit":"nginx.service","timeout_seconds":60}]}`))
+	}))
+	defer server.Close()
+
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	sessions, err := fetchLiveTailSessions(server.Client(), cfg)
+	if err != nil {
+		t.Fatalf("fetch: %v", err)
+	}
+	if len(sessions) != 1 || sessions[0].Unit != "nginx.service" || sessions[0].timeout() != time.Minute {
+		t.Fatalf("unexpected sessions: %+v", sessions)
+	}
+
+	cfg.Token = "other"
+	if _, err := fetchLiveTailSessions(server.Client(), cfg); err != errLiveTailUnsupported {
+		t.Fatalf("expected errLiveTailUnsupported on 404, got %v", err)
+	}
+}
+
+func TestLiveTailSessionTimeout(t *testing.T) {
+	if got := (LiveTailSession{}).timeout(); got != defaultLiveTailTimeout {
+		t.Errorf("default timeout = %s", got)
+	}
+	if got := (LiveTailSession{TimeoutSeconds: 7200}).timeout(); got != maxLiveTailTimeout {
+		t.Errorf("timeout should be capped, got %s", got)
+	}
+}
+
+func TestLiveTailStreamsFile(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	os.WriteFile(path, []byte("old line\n"), 0o644)
+
+	pipeline, err := newLogPipeline(LogRules{Redact: RedactConfig{Rules: []RedactRuleConfig{{Name: "secret", Pattern: `secret=\S+`}}}})
+	if err != nil {
+		t.Fatalf("pipeline: %v", err)
+	}
+	scanner, _ := newLogScanner(DiscoveryConfig{Roots: []string{dir}})
+
+	lines := make(chan LogEntry, 10)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ingest/live-tail/s1" {
+			http.NotFound(w, r)
+			return
+		}
+		sc := bufio.NewScanner(r.Body)
+		for sc.Scan() {
+			var e LogEntry
+			if json.Unmarshal(sc.Bytes(), &e) == nil {
+				lines <- e
+			}
+		}
+	}))
+	defer server.Close()
+
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second, Pipeline: pipeline, LogScanner: scanner}
+	m := startLiveTail(cfg, log.New(io.Discard, "", 0))
+	defer m.close()
+	m.start(LiveTailSession{ID: "s1", Path: path, TimeoutSeconds: 10})
+
+	expect := func(msg string) {
+		t.Helper()
+		select {
+		case e := <-lines:
+			if e.Message != msg {
+				t.Fatalf("got %q, want %q", e.Message, msg)
+			}
+		case <-time.After(3 * time.Second):
+			t.Fatalf("timed out waiting for %q", msg)
+		}
+	}
+	expect("old line")
+
+	f, _ := os.OpenFile(path, os.O_APPEND|os.O_WRONLY, 0o644)
+	f.WriteString("login secret=hunter2\n")
+	f.Close()
+	expect("login [REDACTED:secret]")
+}
+
+func TestLiveTailRefusesPathsOutsideDiscovery(t *testing.T) {
+	dir := t.TempDir()
+	scanner, _ := newLogScanner(DiscoveryConfig{Roots: []string{dir}})
+	os.WriteFile(filepath.Join(dir, "app.log"), []byte("x\n"), 0o644)
+	os.Symlink("/etc/passwd", filepath.Join(dir, "passwd.log"))
+
+	tests := []struct {
+		path  string
+		allow bool
+	}{
+		{filepath.Join(dir, "app.log"), true},
+		{filepath.Join(dir, "sub", "other.log"), true},
+		{filepath.Join(dir, "app.txt"), false},
+		{filepath.Join(dir, "node_modules", "x.log"), false},
+		{filepath.Join(dir, "..", "escape.log"), false},
+		{filepath.Join(dir, "passwd.log"), false},
+		{"/etc/shadow", false},
+		{"relative.log", false},
+	}
+	for _, tt := range tests {
+		if got := scanner.allows(tt.path); got != tt.allow {
+			t.Errorf("allows(%q) = %v, want %v", tt.path, got, tt.allow)
+		}
+	}
+}
+
+func TestLiveTailAllowsRootAndDepthLikeDiscovery(t *testing.T) {
+	fsRoot, _ := newLogScanner(DiscoveryConfig{Roots: []string{"/"}})
+	if !fsRoot.allows("/var/log/app.log") {
+		t.Error("expected a root of / to allow /var/log/app.log")
+	}
+
+	dir := t.TempDir()
+	shallow, _ := newLogScanner(DiscoveryConfig{Roots: []string{dir}, MaxDepth: 1})
+	tests := []struct {
+		path  string
+		allow bool
+	}{
+		{filepath.Join(dir, "app.log"), true},
+		{filepath.Join(dir, "a", "app.log"), true},
+		{filepath.Join(dir, "a", "b", "app.log"), false},
+	}
+	for _, tt := range tests {
+		if got := shallow.allows(tt.path); got != tt.allow {
+			t.Errorf("allows(%q) with max depth 1 = %v, want %v", tt.path, got, tt.allow)
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/shirou/gopsutil/v3/load"
+)
+
+// procRoot and sysRoot are where Linux /proc and /sys files are read from;
+// tests point them at fixture trees.
+var (
+	procRoot = "/proc"
+	sysRoot  = "/sys"
+)
+
+// LoadMetric is the load average and the current run queue.
+type LoadMetric struct {
+	Load1        float64 `json:"load1"`
+	Load5        float64 `json:"load5"`
+	Load15       float64 `json:"load15"`
+	ProcsRunning *uint64 `json:"procs_running,omitempty"` // Linux only
+	ProcsBlocked *uint64 `json:"procs_blocked,omitempty"` // waiting on I/O
+}
+
+// PressureStall is one line of a PSI file: the share of wall time some (or
+// all) tasks were stalled, averaged over 10s/60s/300s, and the total stall
+// time in microseconds since boot.
+type PressureStall struct {
+	Avg10   float64 `json:"avg10"`
+	Avg60   float64 `json:"avg60"`
+	Avg300  float64 `json:"avg300"`
+	TotalUS uint64  `json:"total_us"`
+}
+
+// PressureMetric holds the some/full lines of one resource. Full is missing
+// for CPU on kernels before 5.13.
+type PressureMetric struct {
+	Some PressureStall  `json:"some"`
+	Full *PressureStall `json:"full,omitempty"`
+}
+
+// PressureMetrics is Linux pressure stall information from /proc/pressure.
+type PressureMetrics struct {
+	CPU    *PressureMetric `json:"cpu,omitempty"`
+	Memory *PressureMetric `json:"memory,omitempty"`
+	IO     *PressureMetric `json:"io,omitempty"`
+}
+
+// readLoad returns the load average and, on Linux, the run queue from /proc/stat.
+func readLoad() (*LoadMetric, error) {
+	avg, err := load.Avg()
+	if err != nil {
+		return nil, err
+	}
+	m := &LoadMetric{Load1: avg.Load1, Load5: avg.Load5, Load15: avg.Load15}
+
+	if stat, err := readProcStat(); err == nil {
+		if v, ok := stat["procs_running"]; ok {
+			m.ProcsRunning = &v
+		}
+		if v, ok := stat["procs_blocked"]; ok {
+			m.ProcsBlocked = &v
+		}
+	}
+	return m, nil
+}
+
+// readProcStat returns the first value of every line of /proc/stat keyed by
+// its name ("ctxt", "processes", "procs_running", the interrupt total "intr", ...).
+func readProcStat() (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(procRoot, "stat"))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	stat := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // the intr line is long
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 || strings.HasPrefix(fields[0], "cpu") {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			stat[fields[0]] = v
+		}
+	}
+	return stat, scanner.Err()
+}
+
+// readPressure reads /proc/pressure. It returns nil without error when the
+// kernel has no PSI support (older than 4.20 or booted with psi=0).
+func readPressure() (*PressureMetrics, error) {
+	var m PressureMetrics
+	found := false
+	for _, r := range []struct {
+		name string
+		dst  **PressureMetric
+	}{{"cpu", &m.CPU}, {"memory", &m.Memory}, {"io", &m.IO}} {
+		data, err := os.ReadFile(filepath.Join(procRoot, "pressure", r.name))
+		if errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, err // EOPNOTSUPP when PSI is compiled in but disabled
+		}
+		p, err := parsePressure(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("pressure/%s: %w", r.name, err)
+		}
+		*r.dst, found = p, true
+	}
+	if !found {
+		return nil, nil
+	}
+	return &m, nil
+}
+
+// parsePressure parses the contents of a PSI file:
+//
+//	some avg10=1.14 avg60=1.45 avg300=1.34 total=41735859
+//	full avg10=0.00 avg60=0.00 avg300=0.00 total=0
+func parsePressure(data string) (*PressureMetric, error) {
+	var m PressureMetric
+	hasSome := false
+	for _, line := range strings.Split(strings.TrimSpace(data), "\n") {
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			continue
+		}
+		var s PressureStall
+		for _, kv := range fields[1:] {
+			key, value, ok := strings.Cut(kv, "=")
+			if !ok {
+				return nil, fmt.Errorf("malformed field %q", kv)
+			}
+			var err error
+			switch key {
+			case "avg10":
+				s.Avg10, err = strconv.ParseFloat(value, 64)
+			case "avg60":
+				s.Avg60, err = strconv.ParseFloat(value, 64)
+			case "avg300":
+				s.Avg300, err = strconv.ParseFloat(value, 64)
+			case "total":
+				s.TotalUS, err = strconv.ParseUint(value, 10, 64)
+			}
+			if err != nil {
+				return nil, fmt.Errorf("field %q: %w", kv, err)
+			}
+		}
+		switch fields[0] {
+		case "some":
+			m.Some, hasSome = s, true
+		case "full":
+			m.Full = &s
+		}
+	}
+	if !hasSome {
+		return nil, errors.New("no some line")
+	}
+	return &m, nil
+}
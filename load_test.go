@@ -0,0 +1,89 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// withProcRoot points procRoot at a temporary tree built from files.
+func withProcRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	return withFixtureRoot(t, &procRoot, files)
+}
+
+func withSysRoot(t *testing.T, files map[string]string) string {
+	t.Helper()
+	return withFixtureRoot(t, &sysRoot, files)
+}
+
+// withFixtureRoot writes files into a temporary tree and points root at it
+// for the duration of the test.
+func withFixtureRoot(t *testing.T, root *string, files map[string]string) string {
+	t.Helper()
+	dir := t.TempDir()
+	for name, content := range files {
+		p := filepath.Join(dir, name)
+		os.MkdirAll(filepath.Dir(p), 0o755)
+		if err := os.WriteFile(p, []byte(content), 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	old := *root
+	*root = dir
+	t.Cleanup(func() { *root = old })
+	return dir
+}
+
+func TestReadPressure(t *testing.T) {
+	withProcRoot(t, map[string]string{
+		"pressure/cpu":    "some avg10=1.14 avg60=1.45 avg300=1.34 total=41735859\n",
+		"pressure/memory": "some avg10=0.50 avg60=0.20 avg300=0.10 total=1200\nfull avg10=0.25 avg60=0.10 avg300=0.05 total=600\n",
+	})
+
+	p, err := readPressure()
+	if err != nil {
+		t.Fatalf("readPressure: %v", err)
+	}
+	if p.CPU == nil || p.CPU.Some.Avg10 != 1.14 || p.CPU.Some.TotalUS != 41735859 || p.CPU.Full != nil {
+		t.Errorf("unexpected cpu pressure: %+v", p.CPU)
+	}
+	if p.Memory == nil || p.Memory.Full == nil || p.Memory.Full.Avg60 != 0.10 || p.Memory.Full.TotalUS != 600 {
+		t.Errorf("unexpected memory pressure: %+v", p.Memory)
+	}
+	if p.IO != nil {
+		t.Errorf("io pressure should be missing, got %+v", p.IO)
+	}
+}
+
+func TestReadPressure_NoPSI(t *testing.T) {
+	withProcRoot(t, nil)
+	p, err := readPressure()
+	if err != nil || p != nil {
+		t.Errorf("expected nil without PSI, got %+v, %v", p, err)
+	}
+}
+
+func TestParsePressure_Malformed(t *testing.T) {
+	for _, data := range []string{"", "full avg10=0.00 avg60=0.00 avg300=0.00 total=0", "some avg10=x"} {
+		if _, err := parsePressure(data); err == nil {
+			t.Errorf("parsePressure(%q): expected error", data)
+		}
+	}
+}
+
+func TestReadProcStat(t *testing.T) {
+	withProcRoot(t, map[string]string{
+		"stat": "cpu  10 0 5 100 0 0 0 0 0 0\ncpu0 10 0 5 100 0 0 0 0 0 0\nintr 12345 0 1 2\nctxt 999\nprocesses 42\nprocs_running 3\nprocs_blocked 1\n",
+	})
+	stat, err := readProcStat()
+	if err != nil {
+		t.Fatalf("readProcStat: %v", err)
+	}
+	if stat["procs_running"] != 3 || stat["procs_blocked"] != 1 || stat["intr"] != 12345 || stat["ctxt"] != 999 {
+		t.Errorf("unexpected stat: %v", stat)
+	}
+	if _, ok := stat["cpu0"]; ok {
+		t.Error("cpu lines should be skipped")
+	}
+}
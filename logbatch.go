@@ -0,0 +1,178 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// Limits for log shipping. A request holds at most maxLogEntries entries and
+// roughly maxLogBatchBytes of JSON; larger polls are split into several
+// requests. Each source ships at most maxLogScanEntries entries per poll.
+const (
+	maxLogBatchBytes   = 512 * 1024
+	maxLogSendAttempts = 3
+)
+
+// logRetryBackoff is the wait before the first retry of a failed batch, doubled
+// for each further attempt.
+var logRetryBackoff = time.Second
+
+// logStatusError is returned by postLogs when the backend rejects a request.
+type logStatusError struct {
+	status int
+}
+
+func (e *logStatusError) Error() string {
+	return fmt.Sprintf("server returned %d", e.status)
+}
+
+// retryableLogError reports whether a failed batch is worth sending again:
+// network errors, 5xx, 408 and 429. Other 4xx responses reject the payload itself.
+func retryableLogError(err error) bool {
+	var se *logStatusError
+	if !errors.As(err, &se) {
+		return true
+	}
+	return se.status >= 500 || se.status == http.StatusRequestTimeout || se.status == http.StatusTooManyRequests
+}
+
+// logDropCounter accumulates entries dropped per source until a request
+// carrying the counts is accepted by the backend.
+type logDropCounter struct {
+	mu     sync.Mutex
+	counts map[string]int
+}
+
+var logDrops = &logDropCounter{counts: make(map[string]int)}
+
+func (c *logDropCounter) add(source string, n int) {
+	if n <= 0 {
+		return
+	}
+	c.mu.Lock()
+	c.counts[source] += n
+	c.mu.Unlock()
+}
+
+// take returns and clears the pending counts.
+func (c *logDropCounter) take() map[string]int {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if len(c.counts) == 0 {
+		return nil
+	}
+	counts := c.counts
+	c.counts = make(map[string]int)
+	return counts
+}
+
+// restore adds counts back after the request carrying them failed.
+func (c *logDropCounter) restore(counts map[string]int) {
+	for source, n := range counts {
+		c.add(source, n)
+	}
+}
+
+// logSourceKey names the source an entry is accounted under: the file path,
+// "journald", "syslog/udp", ... or the service when no source is set.
+func logSourceKey(e LogEntry) string {
+	if e.Source != "" {
+		return e.Source
+	}
+	return e.Service
+}
+
+// capPerSource keeps the newest maxLogScanEntries entries of each source,
+// recording the older ones as dropped. Relative order is unchanged.
+func capPerSource(entries []LogEntry) []LogEntry {
+	if len(entries) <= maxLogScanEntries {
+		return entries
+	}
+	counts := make(map[string]int)
+	for _, e := range entries {
+		counts[logSourceKey(e)]++
+	}
+
+	kept := entries[:0:0]
+	for _, e := range entries {
+		key := logSourceKey(e)
+		if counts[key] > maxLogScanEntries {
+			counts[key]--
+			logDrops.add(key, 1)
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// splitLogBatches splits entries, in order, into batches within maxLogEntries
+// and maxLogBatchBytes. An entry larger than the byte limit is sent on its own.
+func splitLogBatches(entries []LogEntry) [][]LogEntry {
+	var batches [][]LogEntry
+	start, size := 0, 0
+	for i, e := range entries {
+		n := 1024 // fallback estimate if the entry does not marshal
+		if b, err := json.Marshal(e); err == nil {
+			n = len(b) + 1
+		}
+		if i > start && (i-start >= maxLogEntries || size+n > maxLogBatchBytes) {
+			batches = append(batches, entries[start:i])
+			start, size = i, 0
+		}
+		size += n
+	}
+	if start < len(entries) {
+		batches = append(batches, entries[start:])
+	}
+	return batches
+}
+
+// shipLogs sends entries to the backend in order-preserving batches, retrying
+// each batch with backoff. Pending drop counts ride along with the first
+// batch. A batch the backend rejects with a non-retryable status is counted
+// as dropped and shipping continues. When a batch still fails after retries,
+// it and every later batch are returned as failed with the error; the caller
+// counts them as dropped unless they will be replayed.
+func shipLogs(client *http.Client, cfg Config, entries []LogEntry) (sent int, failed []LogEntry, err error) {
+	batches := splitLogBatches(entries)
+	for i, batch := range batches {
+		payload := LogIngestPayload{Entries: batch, Dropped: logDrops.take()}
+		cfg.Pipeline.prepare(&payload) // once, so retries keep the redaction counts
+
+		var sendErr error
+		for attempt := 0; attempt < maxLogSendAttempts; attempt++ {
+			if attempt > 0 {
+				time.Sleep(logRetryBackoff << (attempt - 1))
+			}
+			if sendErr = postLogs(client, cfg, payload); sendErr == nil || !retryableLogError(sendErr) {
+				break
+			}
+		}
+
+		if sendErr == nil {
+			sent += len(batch)
+			continue
+		}
+		logDrops.restore(payload.Dropped)
+		if !retryableLogError(sendErr) {
+			countLogDrops(batch)
+			continue
+		}
+		for _, rest := range batches[i:] {
+			failed = append(failed, rest...)
+		}
+		return sent, failed, sendErr
+	}
+	return sent, nil, nil
+}
+
+func countLogDrops(entries []LogEntry) {
+	for _, e := range entries {
+		logDrops.add(logSourceKey(e), 1)
+	}
+}
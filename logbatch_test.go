@@ -0,0 +1,154 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestSplitLogBatches_CountAndBytes(t *testing.T) {
+	var entries []LogEntry
+	for i := 0; i < maxLogEntries*2+5; i++ {
+		entries = append(entries, LogEntry{Source: "a", Message: fmt.Sprint(i)})
+	}
+	batches := splitLogBatches(entries)
+	if len(batches) != 3 || len(batches[0]) != maxLogEntries || len(batches[2]) != 5 {
+		t.Fatalf("unexpected batch sizes: %d batches", len(batches))
+	}
+
+	big := strings.Repeat("x", maxLogBatchBytes/3)
+	entries = []LogEntry{{Message: big}, {Message: big}, {Message: big}, {Message: strings.Repeat("y", maxLogBatchBytes)}}
+	batches = splitLogBatches(entries)
+	if len(batches) != 3 || len(batches[0]) != 2 || len(batches[1]) != 1 || len(batches[2]) != 1 {
+		t.Fatalf("expected byte-limited batches [2 1 1], got %d batches", len(batches))
+	}
+}
+
+func TestCapPerSource_CountsDrops(t *testing.T) {
+	logDrops.take()
+
+	var entries []LogEntry
+	for i := 0; i < maxLogScanEntries+10; i++ {
+		entries = append(entries, LogEntry{Source: "/var/log/noisy.log", Message: fmt.Sprint(i)})
+	}
+	entries = append(entries, LogEntry{Source: "journald", Message: "quiet"})
+
+	got := capPerSource(entries)
+	if len(got) != maxLogScanEntries+1 {
+		t.Fatalf("expected %d entries, got %d", maxLogScanEntries+1, len(got))
+	}
+	if got[0].Message != "10" || got[len(got)-1].Message != "quiet" {
+		t.Errorf("expected the oldest noisy lines dropped, got first=%q last=%q", got[0].Message, got[len(got)-1].Message)
+	}
+	if drops := logDrops.take(); drops["/var/log/noisy.log"] != 10 || len(drops) != 1 {
+		t.Errorf("unexpected drop counts: %v", drops)
+	}
+}
+
+func TestShipLogs_RetriesAndPreservesOrder(t *testing.T) {
+	defer func(d time.Duration) { logRetryBackoff = d }(logRetryBackoff)
+	logRetryBackoff = 0
+	logDrops.take()
+	logDrops.add("syslog", 3)
+
+	var mu sync.Mutex
+	var received []LogIngestPayload
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		defer mu.Unlock()
+		calls++
+		if calls == 2 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		var p LogIngestPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received = append(received, p)
+	}))
+	defer server.Close()
+
+	var entries []LogEntry
+	for i := 0; i < maxLogEntries+50; i++ {
+		entries = append(entries, LogEntry{Source: "app.log", Message: fmt.Sprint(i)})
+	}
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	sent, _, err := shipLogs(server.Client(), cfg, entries)
+	if err != nil || sent != len(entries) {
+		t.Fatalf("shipLogs = %d, %v", sent, err)
+	}
+
+	if len(received) != 2 || calls != 3 {
+		t.Fatalf("expected 2 accepted requests after 3 calls, got %d/%d", len(received), calls)
+	}
+	if received[0].Dropped["syslog"] != 3 || received[1].Dropped != nil {
+		t.Errorf("drop counts should ride on the first batch only: %v %v", received[0].Dropped, received[1].Dropped)
+	}
+	next := 0
+	for _, p := range received {
+		for _, e := range p.Entries {
+			if e.Message != fmt.Sprint(next) {
+				t.Fatalf("entry %d out of order: %q", next, e.Message)
+			}
+			next++
+		}
+	}
+}
+
+func TestShipLogs_FailureCountsDropsPerSource(t *testing.T) {
+	defer func(d time.Duration) { logRetryBackoff = d }(logRetryBackoff)
+	logRetryBackoff = 0
+	logDrops.take()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer server.Close()
+
+	entries := []LogEntry{{Source: "a.log"}, {Source: "a.log"}, {Source: "journald"}}
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	sent, failed, err := shipLogs(server.Client(), cfg, entries)
+	if err == nil || sent != 0 || len(failed) != len(entries) {
+		t.Fatalf("expected failure, got sent=%d failed=%d err=%v", sent, len(failed), err)
+	}
+	if calls != maxLogSendAttempts {
+		t.Errorf("expected %d attempts, got %d", maxLogSendAttempts, calls)
+	}
+	if drops := logDrops.take(); drops != nil {
+		t.Errorf("failed entries are left for the caller to count, got %v", drops)
+	}
+	countLogDrops(failed)
+	if drops := logDrops.take(); drops["a.log"] != 2 || drops["journald"] != 1 {
+		t.Errorf("unexpected drop counts: %v", drops)
+	}
+}
+
+func TestShipLogs_RejectedBatchIsNotRetried(t *testing.T) {
+	logDrops.take()
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusBadRequest)
+	}))
+	defer server.Close()
+
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	sent, failed, err := shipLogs(server.Client(), cfg, []LogEntry{{Source: "a.log"}})
+	if err != nil || sent != 0 || len(failed) != 0 {
+		t.Fatalf("a rejected batch is not a delivery failure, got sent=%d failed=%d err=%v", sent, len(failed), err)
+	}
+	if calls != 1 {
+		t.Errorf("400 should not be retried, got %d calls", calls)
+	}
+	if drops := logDrops.take(); drops["a.log"] != 1 {
+		t.Errorf("expected rejected entry counted as dropped, got %v", drops)
+	}
+}
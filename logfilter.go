@@ -0,0 +1,209 @@
+package main
+
+import (
+	"fmt"
+	"math/rand"
+	"path"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// filterRule decides whether matching entries are kept or dropped.
+// All non-empty match fields must match for the rule to apply.
+type filterRule struct {
+	keep    bool
+	service string // glob
+	levels  map[string]bool
+	source  string // glob on LogEntry.Source (file path, "journald", ...)
+	re      *regexp.Regexp
+}
+
+// rateLimit caps entries per minute for each source matching the glob.
+type rateLimit struct {
+	source    string
+	perMinute int
+}
+
+// logFilter applies drop/keep rules, per-source rate limits and probabilistic
+// sampling. Rules are evaluated in order and the first match wins; a "keep"
+// match bypasses sampling and rate limits. Error-level entries always pass.
+type logFilter struct {
+	rules    []filterRule
+	limits   []rateLimit
+	sampling map[string]float64 // level -> keep ratio (0..1)
+
+	now    func() time.Time
+	random func() float64
+
+	mu      sync.Mutex
+	windows map[string]*rateWindow
+	dropped map[string]int64 // reason -> count
+}
+
+type rateWindow struct {
+	start time.Time
+	count int
+}
+
+// newLogFilter compiles the filter section of the rules file.
+func newLogFilter(cfg FilterConfig) (*logFilter, error) {
+	f := &logFilter{
+		sampling: make(map[string]float64, len(cfg.Sampling)),
+		now:      time.Now,
+		random:   rand.Float64,
+		windows:  make(map[string]*rateWindow),
+		dropped:  make(map[string]int64),
+	}
+
+	for i, rc := range cfg.Rules {
+		rule := filterRule{service: rc.Service, source: rc.Source}
+		switch strings.ToLower(rc.Action) {
+		case "keep":
+			rule.keep = true
+		case "drop", "":
+		default:
+			return nil, fmt.Errorf("filter rule %d: unknown action %q", i, rc.Action)
+		}
+		if len(rc.Levels) > 0 {
+			rule.levels = make(map[string]bool, len(rc.Levels))
+			for _, lvl := range rc.Levels {
+				rule.levels[strings.ToLower(lvl)] = true
+			}
+		}
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("filter rule %d: %w", i, err)
+			}
+			rule.re = re
+		}
+		f.rules = append(f.rules, rule)
+	}
+
+	for i, rl := range cfg.RateLimits {
+		if rl.PerMinute <= 0 {
+			return nil, fmt.Errorf("rate limit %d: per_minute must be positive", i)
+		}
+		source := rl.Source
+		if source == "" {
+			source = "*"
+		}
+		f.limits = append(f.limits, rateLimit{source: source, perMinute: rl.PerMinute})
+	}
+
+	for level, ratio := range cfg.Sampling {
+		if ratio < 0 || ratio > 1 {
+			return nil, fmt.Errorf("sampling %s: ratio must be between 0 and 1", level)
+		}
+		f.sampling[strings.ToLower(level)] = ratio
+	}
+
+	return f, nil
+}
+
+// matches reports whether every configured field of the rule matches the entry.
+func (r filterRule) matches(e LogEntry) bool {
+	if r.service != "" && !globMatch(r.service, e.Service) {
+		return false
+	}
+	if r.levels != nil && !r.levels[e.Level] {
+		return false
+	}
+	if r.source != "" && !globMatch(r.source, e.Source) {
+		return false
+	}
+	if r.re != nil && !r.re.MatchString(e.Message) {
+		return false
+	}
+	return true
+}
+
+// filterEntries returns the entries that survive the rules, preserving order.
+func (f *logFilter) filterEntries(entries []LogEntry) []LogEntry {
+	if f == nil || (len(f.rules) == 0 && len(f.limits) == 0 && len(f.sampling) == 0) {
+		return entries
+	}
+
+	f.mu.Lock()
+	defer f.mu.Unlock()
+
+	kept := entries[:0:0]
+	for _, e := range entries {
+		if reason := f.dropReason(e); reason != "" {
+			f.dropped[reason]++
+			continue
+		}
+		kept = append(kept, e)
+	}
+	return kept
+}
+
+// dropReason returns why an entry should be dropped, or "" to keep it.
+// Callers must hold f.mu.
+func (f *logFilter) dropReason(e LogEntry) string {
+	if e.Level == "error" {
+		return ""
+	}
+
+	for _, rule := range f.rules {
+		if !rule.matches(e) {
+			continue
+		}
+		if rule.keep {
+			return ""
+		}
+		return "rule"
+	}
+
+	if ratio, ok := f.sampling[e.Level]; ok && f.random() >= ratio {
+		return "sampled"
+	}
+
+	for _, rl := range f.limits {
+		if !globMatch(rl.source, e.Source) {
+			continue
+		}
+		now := f.now()
+		w := f.windows[e.Source]
+		if w == nil || now.Sub(w.start) >= time.Minute {
+			w = &rateWindow{start: now}
+			f.windows[e.Source] = w
+		}
+		if w.count >= rl.perMinute {
+			return "rate_limited"
+		}
+		w.count++
+		break
+	}
+
+	return ""
+}
+
+// droppedTotals returns cumulative drop counts by reason.
+func (f *logFilter) droppedTotals() map[string]int64 {
+	if f == nil {
+		return nil
+	}
+	f.mu.Lock()
+	defer f.mu.Unlock()
+	out := make(map[string]int64, len(f.dropped))
+	for reason, n := range f.dropped {
+		out[reason] = n
+	}
+	return out
+}
+
+// globMatch matches name against a shell-style glob, treating a malformed
+// pattern as a literal comparison.
+func globMatch(pattern, name string) bool {
+	if pattern == "*" {
+		return true
+	}
+	ok, err := path.Match(pattern, name)
+	if err != nil {
+		return pattern == name
+	}
+	return ok
+}
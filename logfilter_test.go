@@ -0,0 +1,180 @@
+package main
+
+import (
+	"testing"
+	"time"
+)
+
+func newTestFilter(t *testing.T, cfg FilterConfig) *logFilter {
+	t.Helper()
+	f, err := newLogFilter(cfg)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return f
+}
+
+// --- filter rule Tests ---
+
+func TestLogFilter_DropByService(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{
+		Rules: []FilterRuleConfig{{Action: "drop", Service: "CRON"}},
+	})
+
+	entries := []LogEntry{
+		{Service: "CRON", Level: "info", Message: "session opened"},
+		{Service: "sshd", Level: "info", Message: "accepted key"},
+		{Service: "CRON", Level: "error", Message: "job failed"},
+	}
+	got := f.filterEntries(entries)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d", len(got))
+	}
+	if got[0].Service != "sshd" || got[1].Message != "job failed" {
+		t.Errorf("unexpected entries kept: %+v", got)
+	}
+	if f.droppedTotals()["rule"] != 1 {
+		t.Errorf("expected 1 rule drop, got %v", f.droppedTotals())
+	}
+}
+
+func TestLogFilter_DropBySourceAndPattern(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{
+		Rules: []FilterRuleConfig{{Source: "/var/log/nginx/*.log", Pattern: `GET /healthz`}},
+	})
+
+	entries := []LogEntry{
+		{Source: "/var/log/nginx/access.log", Level: "info", Message: `"GET /healthz HTTP/1.1" 200`},
+		{Source: "/var/log/nginx/access.log", Level: "info", Message: `"GET /api/users HTTP/1.1" 200`},
+		{Source: "/var/log/app.log", Level: "info", Message: `GET /healthz`},
+	}
+	got := f.filterEntries(entries)
+	if len(got) != 2 {
+		t.Fatalf("expected 2 entries, got %d: %+v", len(got), got)
+	}
+}
+
+func TestLogFilter_KeepRuleWinsOverLaterDrop(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{
+		Rules: []FilterRuleConfig{
+			{Action: "keep", Pattern: "payment"},
+			{Action: "drop", Levels: []string{"info"}},
+		},
+		Sampling: map[string]float64{"info": 0},
+	})
+
+	entries := []LogEntry{
+		{Level: "info", Message: "payment accepted"},
+		{Level: "info", Message: "cache warmed"},
+	}
+	got := f.filterEntries(entries)
+	if len(got) != 1 || got[0].Message != "payment accepted" {
+		t.Errorf("expected only keep-rule entry, got %+v", got)
+	}
+}
+
+func TestLogFilter_ErrorsAlwaysKept(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{
+		Rules:      []FilterRuleConfig{{Action: "drop", Service: "*"}},
+		RateLimits: []RateLimitConfig{{PerMinute: 1}},
+		Sampling:   map[string]float64{"error": 0},
+	})
+
+	entries := []LogEntry{
+		{Service: "app", Level: "error", Message: "a"},
+		{Service: "app", Level: "error", Message: "b"},
+	}
+	if got := f.filterEntries(entries); len(got) != 2 {
+		t.Errorf("expected errors to bypass filters, got %d", len(got))
+	}
+}
+
+// --- sampling / rate limit Tests ---
+
+func TestLogFilter_Sampling(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{Sampling: map[string]float64{"debug": 0.25}})
+	values := []float64{0.1, 0.5, 0.2, 0.9}
+	f.random = func() float64 {
+		v := values[0]
+		values = values[1:]
+		return v
+	}
+
+	entries := []LogEntry{
+		{Level: "debug", Message: "1"},
+		{Level: "debug", Message: "2"},
+		{Level: "debug", Message: "3"},
+		{Level: "debug", Message: "4"},
+		{Level: "info", Message: "unsampled"},
+	}
+	got := f.filterEntries(entries)
+	if len(got) != 3 || got[0].Message != "1" || got[1].Message != "3" || got[2].Message != "unsampled" {
+		t.Errorf("unexpected sampled entries: %+v", got)
+	}
+	if f.droppedTotals()["sampled"] != 2 {
+		t.Errorf("expected 2 sampled drops, got %v", f.droppedTotals())
+	}
+}
+
+func TestLogFilter_RateLimitPerSource(t *testing.T) {
+	f := newTestFilter(t, FilterConfig{RateLimits: []RateLimitConfig{{Source: "journald", PerMinute: 2}}})
+	now := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	f.now = func() time.Time { return now }
+
+	batch := []LogEntry{
+		{Source: "journald", Level: "info"},
+		{Source: "journald", Level: "info"},
+		{Source: "journald", Level: "info"},
+		{Source: "/var/log/app.log", Level: "info"},
+	}
+	if got := f.filterEntries(batch); len(got) != 3 {
+		t.Fatalf("expected 3 entries within limit, got %d", len(got))
+	}
+
+	now = now.Add(61 * time.Second)
+	if got := f.filterEntries(batch[:1]); len(got) != 1 {
+		t.Errorf("expected window reset after a minute, got %d", len(got))
+	}
+}
+
+func TestNewLogFilter_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  FilterConfig
+	}{
+		{"bad action", FilterConfig{Rules: []FilterRuleConfig{{Action: "maybe"}}}},
+		{"bad regex", FilterConfig{Rules: []FilterRuleConfig{{Pattern: "("}}}},
+		{"zero rate", FilterConfig{RateLimits: []RateLimitConfig{{PerMinute: 0}}}},
+		{"bad ratio", FilterConfig{Sampling: map[string]float64{"info": 1.5}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newLogFilter(tt.cfg); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+// --- selectEntries Tests ---
+
+func TestLogPipeline_SelectEntriesCapsAfterFilter(t *testing.T) {
+	p, err := newLogPipeline(LogRules{Filter: FilterConfig{
+		Rules: []FilterRuleConfig{{Service: "noisy"}},
+	}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	var entries []LogEntry
+	for i := 0; i < maxLogEntries; i++ {
+		entries = append(entries, LogEntry{Service: "noisy", Level: "info"})
+	}
+	entries = append(entries, LogEntry{Service: "app", Level: "info", Message: "important"})
+
+	got := p.selectEntries(entries)
+	if len(got) != 1 || got[0].Message != "important" {
+		t.Errorf("expected noisy entries filtered before cap, got %d entries", len(got))
+	}
+}
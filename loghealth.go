@@ -0,0 +1,199 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"sync"
+	"time"
+)
+
+// LogSourceHealth tells a quiet source apart from one the agent cannot read.
+// Byte and line counts are cumulative since the agent started.
+type LogSourceHealth struct {
+	Source     string `json:"source"` // file path or "journald"
+	Type       string `json:"type"`   // journald, syslog_file, file, container
+	Readable   bool   `json:"readable"`
+	Error      string `json:"error,omitempty"`
+	BytesRead  int64  `json:"bytes_read"`
+	LinesRead  int64  `json:"lines_read"`
+	LastLineAt string `json:"last_line_at,omitempty"`
+	Offset     int64  `json:"offset,omitempty"`
+	Rotations  int    `json:"rotations,omitempty"`
+	CheckedAt  string `json:"checked_at"`
+}
+
+// LogHealthPayload is sent to POST /api/ingest/server-log-health
+type LogHealthPayload struct {
+	Timestamp string            `json:"timestamp"`
+	Sources   []LogSourceHealth `json:"sources"`
+}
+
+// logHealthTracker records the outcome of every read of a log source.
+type logHealthTracker struct {
+	mu      sync.Mutex
+	sources map[string]*LogSourceHealth
+}
+
+var logHealth = &logHealthTracker{sources: make(map[string]*LogSourceHealth)}
+
+// get returns the record for source, creating it. Callers must hold h.mu.
+func (h *logHealthTracker) get(source, typ string) *LogSourceHealth {
+	s := h.sources[source]
+	if s == nil {
+		s = &LogSourceHealth{Source: source, Type: typ}
+		h.sources[source] = s
+	}
+	s.CheckedAt = time.Now().UTC().Format(time.RFC3339)
+	return s
+}
+
+// read records a successful read of n lines totalling size bytes.
+func (h *logHealthTracker) read(source, typ string, size int64, lines int, lastLineAt string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(source, typ)
+	s.Readable, s.Error = true, ""
+	s.BytesRead += size
+	s.LinesRead += int64(lines)
+	if lines > 0 && lastLineAt != "" {
+		s.LastLineAt = lastLineAt
+	}
+}
+
+// readLines records raw lines read from a file source and its new position.
+func (h *logHealthTracker) readLines(source, typ string, lines []string, entries []LogEntry, cur *fileCursor) {
+	var size int64
+	for _, line := range lines {
+		size += int64(len(line)) + 1
+	}
+	h.read(source, typ, size, len(lines), lastEntryTime(entries))
+	if cur == nil {
+		return
+	}
+	h.mu.Lock()
+	s := h.get(source, typ)
+	s.Offset, s.Rotations = cur.offset, cur.rotations
+	h.mu.Unlock()
+}
+
+// fail records an unreadable source. Counters are kept.
+func (h *logHealthTracker) fail(source, typ string, err error) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	s := h.get(source, typ)
+	s.Readable, s.Error = false, err.Error()
+}
+
+// prune forgets sources of typ that are no longer in keep (unmonitored paths,
+// removed containers).
+func (h *logHealthTracker) prune(typ string, keep []string) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		keepSet[k] = true
+	}
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	for source, s := range h.sources {
+		if s.Type == typ && !keepSet[source] {
+			delete(h.sources, source)
+		}
+	}
+}
+
+// snapshot returns a copy of every record sorted by source.
+func (h *logHealthTracker) snapshot() []LogSourceHealth {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+	out := make([]LogSourceHealth, 0, len(h.sources))
+	for _, s := range h.sources {
+		out = append(out, *s)
+	}
+	sort.Slice(out, func(i, j int) bool { return out[i].Source < out[j].Source })
+	return out
+}
+
+// lastEntryTime returns the timestamp of the newest entry, if any.
+func lastEntryTime(entries []LogEntry) string {
+	if len(entries) == 0 {
+		return ""
+	}
+	return entries[len(entries)-1].Timestamp
+}
+
+// entryBytes approximates the bytes read for sources without raw lines.
+func entryBytes(entries []LogEntry) int64 {
+	var n int64
+	for _, e := range entries {
+		n += int64(len(e.Message)) + 1
+	}
+	return n
+}
+
+// formatLogHealth renders one source for the test command output.
+func formatLogHealth(s LogSourceHealth) string {
+	if !s.Readable {
+		return fmt.Sprintf("❌ %s [%s]: %s", s.Source, s.Type, s.Error)
+	}
+	last := s.LastLineAt
+	if last == "" {
+		last = "never"
+	}
+	return fmt.Sprintf("✅ %s [%s]: %d lines, %d bytes, last line %s, offset %d, rotations %d",
+		s.Source, s.Type, s.LinesRead, s.BytesRead, last, s.Offset, s.Rotations)
+}
+
+// sendLogHealthToBackend reports the state of every log source read so far.
+func sendLogHealthToBackend(client *http.Client, cfg Config, logger *log.Logger) {
+	sources := logHealth.snapshot()
+	if len(sources) == 0 {
+		return
+	}
+
+	unreadable := 0
+	for _, s := range sources {
+		if !s.Readable {
+			unreadable++
+		}
+	}
+
+	payload := LogHealthPayload{
+		Timestamp: time.Now().UTC().Format(time.RFC3339),
+		Sources:   sources,
+	}
+	if err := sendLogHealth(client, cfg, payload); err != nil {
+		logger.Printf("log health ingest failed: %v", err)
+	} else {
+		logger.Printf("log health sent: %d sources (%d unreadable)", len(sources), unreadable)
+	}
+}
+
+// sendLogHealth sends log source health to backend
+func sendLogHealth(client *http.Client, cfg Config, payload LogHealthPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := cfg.BaseURL + "/api/ingest/server-log-health"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,88 @@
+package main
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func healthOf(t *testing.T, source string) LogSourceHealth {
+	t.Helper()
+	for _, s := range logHealth.snapshot() {
+		if s.Source == source {
+			return s
+		}
+	}
+	t.Fatalf("no health record for %s", source)
+	return LogSourceHealth{}
+}
+
+func TestLogHealth_MissingFileIsUnreadable(t *testing.T) {
+	tails := &fileTailer{cursors: make(map[string]*fileCursor)}
+	path := filepath.Join(t.TempDir(), "missing.log")
+	if entries := collectFileLogs(tails, path, time.Minute); entries != nil {
+		t.Fatalf("expected no entries, got %d", len(entries))
+	}
+	s := healthOf(t, path)
+	if s.Readable || s.Error == "" || s.Type != "file" {
+		t.Errorf("expected unreadable file with error, got %+v", s)
+	}
+
+	logHealth.prune("file", nil)
+	for _, s := range logHealth.snapshot() {
+		if s.Source == path {
+			t.Errorf("pruned source still reported")
+		}
+	}
+}
+
+func TestLogHealth_CountsLinesOffsetAndRotations(t *testing.T) {
+	tails := &fileTailer{cursors: make(map[string]*fileCursor)}
+	path := filepath.Join(t.TempDir(), "app.log")
+	os.WriteFile(path, []byte("2024-05-01T10:00:00Z first\n"), 0o644)
+
+	collectFileLogs(tails, path, time.Minute)
+	s := healthOf(t, path)
+	if !s.Readable || s.LinesRead != 1 || s.BytesRead != 27 || s.Offset != 27 || s.Rotations != 0 {
+		t.Fatalf("unexpected health after first read: %+v", s)
+	}
+	if s.LastLineAt != "2024-05-01T10:00:00Z" {
+		t.Errorf("LastLineAt = %q", s.LastLineAt)
+	}
+
+	// Replace the file, as logrotate with create does
+	os.Rename(path, path+".1")
+	os.WriteFile(path, []byte("second\nthird\n"), 0o644)
+	collectFileLogs(tails, path, time.Minute)
+
+	s = healthOf(t, path)
+	if s.LinesRead != 3 || s.Offset != 13 || s.Rotations != 1 {
+		t.Errorf("unexpected health after rotation: %+v", s)
+	}
+	logHealth.prune("file", nil)
+}
+
+func TestSendLogHealth(t *testing.T) {
+	var got LogHealthPayload
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ingest/server-log-health" {
+			http.NotFound(w, r)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer server.Close()
+
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	payload := LogHealthPayload{Sources: []LogSourceHealth{{Source: "/var/log/app.log", Type: "file", Error: "permission denied"}}}
+	if err := sendLogHealth(server.Client(), cfg, payload); err != nil {
+		t.Fatalf("send: %v", err)
+	}
+	if len(got.Sources) != 1 || got.Sources[0].Error != "permission denied" || got.Sources[0].Readable {
+		t.Errorf("unexpected payload: %+v", got)
+	}
+}
@@ -0,0 +1,277 @@
+package main
+
+import (
+	"fmt"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// maxLogMetricSeries bounds label cardinality so a bad capture group (e.g. a
+// request ID) cannot grow memory without limit.
+const maxLogMetricSeries = 1000
+
+// LogMetricSample is a counter or gauge derived from log lines, sent with MetricPayload.
+type LogMetricSample struct {
+	Name   string            `json:"name"`
+	Type   string            `json:"type"` // counter or gauge
+	Labels map[string]string `json:"labels,omitempty"`
+	Value  float64           `json:"value"`
+}
+
+// logMetricRule matches entries and turns them into a metric observation.
+type logMetricRule struct {
+	name       string
+	gauge      bool
+	service    string // glob
+	source     string // glob
+	field      string
+	re         *regexp.Regexp
+	labels     []string // capture group names used as labels
+	valueGroup string
+}
+
+// logMetricExtractor accumulates observations between metric flushes.
+// Counters hold the delta since the last flush; gauges hold the last value.
+type logMetricExtractor struct {
+	rules []logMetricRule
+
+	mu     sync.Mutex
+	series map[string]*LogMetricSample
+}
+
+// newLogMetricExtractor compiles the metrics section of the rules file.
+func newLogMetricExtractor(cfgs []LogMetricRuleConfig) (*logMetricExtractor, error) {
+	x := &logMetricExtractor{series: make(map[string]*LogMetricSample)}
+
+	for i, rc := range cfgs {
+		if rc.Name == "" {
+			return nil, fmt.Errorf("log metric %d: name is required", i)
+		}
+		rule := logMetricRule{
+			name:       rc.Name,
+			service:    rc.Service,
+			source:     rc.Source,
+			field:      rc.Field,
+			valueGroup: rc.ValueGroup,
+		}
+		switch strings.ToLower(rc.Type) {
+		case "", "counter":
+		case "gauge":
+			rule.gauge = true
+			if rc.ValueGroup == "" {
+				return nil, fmt.Errorf("log metric %s: gauge requires value_group", rc.Name)
+			}
+		default:
+			return nil, fmt.Errorf("log metric %s: unknown type %q", rc.Name, rc.Type)
+		}
+		if rule.field == "" {
+			rule.field = "message"
+		}
+
+		if rc.Pattern != "" {
+			re, err := regexp.Compile(rc.Pattern)
+			if err != nil {
+				return nil, fmt.Errorf("log metric %s: %w", rc.Name, err)
+			}
+			rule.re = re
+		}
+		if rc.ValueGroup != "" && (rule.re == nil || rule.re.SubexpIndex(rc.ValueGroup) < 0) {
+			return nil, fmt.Errorf("log metric %s: value_group %q is not a named group in pattern", rc.Name, rc.ValueGroup)
+		}
+
+		if len(rc.Labels) > 0 {
+			for _, label := range rc.Labels {
+				if rule.re == nil || rule.re.SubexpIndex(label) < 0 {
+					return nil, fmt.Errorf("log metric %s: label %q is not a named group in pattern", rc.Name, label)
+				}
+			}
+			rule.labels = rc.Labels
+		} else if rule.re != nil {
+			for _, name := range rule.re.SubexpNames() {
+				if name != "" && name != rc.ValueGroup {
+					rule.labels = append(rule.labels, name)
+				}
+			}
+		}
+
+		x.rules = append(x.rules, rule)
+	}
+
+	return x, nil
+}
+
+// entryField resolves a rule field name against an entry. Attributes are
+// addressed as "attributes.<key>", container metadata as "container.<field>"
+// and "container.labels.<key>".
+func entryField(e LogEntry, field string) string {
+	switch field {
+	case "message":
+		return e.Message
+	case "service":
+		return e.Service
+	case "level":
+		return e.Level
+	case "host":
+		return e.Host
+	case "source":
+		return e.Source
+	case "unit":
+		return e.Unit
+	case "transport":
+		return e.Transport
+	}
+	if key, ok := strings.CutPrefix(field, "attributes."); ok {
+		return e.Attributes[key]
+	}
+	if e.Container != nil {
+		switch field {
+		case "container.id":
+			return e.Container.ID
+		case "container.name":
+			return e.Container.Name
+		case "container.image":
+			return e.Container.Image
+		}
+		if key, ok := strings.CutPrefix(field, "container.labels."); ok {
+			return e.Container.Labels[key]
+		}
+	}
+	return ""
+}
+
+// observe evaluates every rule against every entry.
+func (x *logMetricExtractor) observe(entries []LogEntry) {
+	if x == nil || len(x.rules) == 0 {
+		return
+	}
+
+	x.mu.Lock()
+	defer x.mu.Unlock()
+
+	for _, e := range entries {
+		for _, rule := range x.rules {
+			if rule.service != "" && !globMatch(rule.service, e.Service) {
+				continue
+			}
+			if rule.source != "" && !globMatch(rule.source, e.Source) {
+				continue
+			}
+
+			value := 1.0
+			var labels map[string]string
+			if rule.re != nil {
+				m := rule.re.FindStringSubmatch(entryField(e, rule.field))
+				if m == nil {
+					continue
+				}
+				if len(rule.labels) > 0 {
+					labels = make(map[string]string, len(rule.labels))
+					for _, name := range rule.labels {
+						labels[name] = m[rule.re.SubexpIndex(name)]
+					}
+				}
+				if rule.valueGroup != "" {
+					v, err := strconv.ParseFloat(m[rule.re.SubexpIndex(rule.valueGroup)], 64)
+					if err != nil {
+						continue
+					}
+					value = v
+				}
+			}
+
+			x.record(rule.name, rule.gauge, labels, value)
+		}
+	}
+}
+
+// record adds an observation. Callers must hold x.mu.
+func (x *logMetricExtractor) record(name string, gauge bool, labels map[string]string, value float64) {
+	key := seriesKey(name, labels)
+	s := x.series[key]
+	if s == nil {
+		if len(x.series) >= maxLogMetricSeries {
+			return
+		}
+		typ := "counter"
+		if gauge {
+			typ = "gauge"
+		}
+		s = &LogMetricSample{Name: name, Type: typ, Labels: labels}
+		x.series[key] = s
+	}
+	if gauge {
+		s.Value = value
+	} else {
+		s.Value += value
+	}
+}
+
+// take returns the pending samples sorted by series and resets the extractor.
+func (x *logMetricExtractor) take() []LogMetricSample {
+	if x == nil {
+		return nil
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	if len(x.series) == 0 {
+		return nil
+	}
+
+	keys := make([]string, 0, len(x.series))
+	for key := range x.series {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	samples := make([]LogMetricSample, 0, len(keys))
+	for _, key := range keys {
+		samples = append(samples, *x.series[key])
+	}
+	x.series = make(map[string]*LogMetricSample)
+	return samples
+}
+
+// restore puts samples back after a failed send so counter deltas are not lost.
+// Gauges observed since the take keep their newer value.
+func (x *logMetricExtractor) restore(samples []LogMetricSample) {
+	if x == nil || len(samples) == 0 {
+		return
+	}
+	x.mu.Lock()
+	defer x.mu.Unlock()
+	for _, sample := range samples {
+		key := seriesKey(sample.Name, sample.Labels)
+		if s, ok := x.series[key]; ok {
+			if sample.Type == "counter" {
+				s.Value += sample.Value
+			}
+			continue
+		}
+		x.record(sample.Name, sample.Type == "gauge", sample.Labels, sample.Value)
+	}
+}
+
+// seriesKey builds a stable identity from the metric name and sorted labels.
+func seriesKey(name string, labels map[string]string) string {
+	if len(labels) == 0 {
+		return name
+	}
+	names := make([]string, 0, len(labels))
+	for k := range labels {
+		names = append(names, k)
+	}
+	sort.Strings(names)
+
+	var b strings.Builder
+	b.WriteString(name)
+	for _, k := range names {
+		b.WriteString("\x00")
+		b.WriteString(k)
+		b.WriteString("=")
+		b.WriteString(labels[k])
+	}
+	return b.String()
+}
@@ -0,0 +1,140 @@
+package main
+
+import (
+	"testing"
+)
+
+func newTestExtractor(t *testing.T, cfgs ...LogMetricRuleConfig) *logMetricExtractor {
+	t.Helper()
+	x, err := newLogMetricExtractor(cfgs)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	return x
+}
+
+func TestLogMetricExtractor_CounterWithLabels(t *testing.T) {
+	x := newTestExtractor(t, LogMetricRuleConfig{
+		Name:    "http_responses",
+		Source:  "/var/log/nginx/*.log",
+		Pattern: `" (?P<status>5\d\d) `,
+	})
+
+	x.observe([]LogEntry{
+		{Source: "/var/log/nginx/access.log", Message: `"GET / HTTP/1.1" 502 0`},
+		{Source: "/var/log/nginx/access.log", Message: `"GET / HTTP/1.1" 502 0`},
+		{Source: "/var/log/nginx/access.log", Message: `"GET / HTTP/1.1" 503 0`},
+		{Source: "/var/log/nginx/access.log", Message: `"GET / HTTP/1.1" 200 0`},
+		{Source: "/var/log/app.log", Message: `"GET / HTTP/1.1" 500 0`},
+	})
+
+	samples := x.take()
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 series, got %d: %+v", len(samples), samples)
+	}
+	if samples[0].Labels["status"] != "502" || samples[0].Value != 2 || samples[0].Type != "counter" {
+		t.Errorf("unexpected first sample: %+v", samples[0])
+	}
+	if samples[1].Labels["status"] != "503" || samples[1].Value != 1 {
+		t.Errorf("unexpected second sample: %+v", samples[1])
+	}
+	if again := x.take(); len(again) != 0 {
+		t.Errorf("expected take to reset counters, got %+v", again)
+	}
+}
+
+func TestLogMetricExtractor_GaugeAndField(t *testing.T) {
+	x := newTestExtractor(t,
+		LogMetricRuleConfig{
+			Name:       "queue_depth",
+			Type:       "gauge",
+			Pattern:    `depth=(?P<depth>\d+)`,
+			ValueGroup: "depth",
+		},
+		LogMetricRuleConfig{
+			Name:    "oom_messages",
+			Field:   "attributes.facility",
+			Pattern: `^kern$`,
+		},
+	)
+
+	x.observe([]LogEntry{
+		{Message: "worker depth=12"},
+		{Message: "worker depth=7"},
+		{Message: "Out of memory", Attributes: map[string]string{"facility": "kern"}},
+	})
+
+	samples := x.take()
+	if len(samples) != 2 {
+		t.Fatalf("expected 2 series, got %+v", samples)
+	}
+	for _, s := range samples {
+		switch s.Name {
+		case "queue_depth":
+			if s.Type != "gauge" || s.Value != 7 || len(s.Labels) != 0 {
+				t.Errorf("unexpected gauge: %+v", s)
+			}
+		case "oom_messages":
+			if s.Value != 1 {
+				t.Errorf("unexpected counter: %+v", s)
+			}
+		}
+	}
+}
+
+func TestLogMetricExtractor_RestoreAfterFailure(t *testing.T) {
+	x := newTestExtractor(t, LogMetricRuleConfig{Name: "errors", Pattern: "ERROR"})
+
+	x.observe([]LogEntry{{Message: "ERROR a"}, {Message: "ERROR b"}})
+	samples := x.take()
+	x.observe([]LogEntry{{Message: "ERROR c"}})
+	x.restore(samples)
+
+	got := x.take()
+	if len(got) != 1 || got[0].Value != 3 {
+		t.Errorf("expected restored counter value 3, got %+v", got)
+	}
+}
+
+func TestNewLogMetricExtractor_InvalidConfig(t *testing.T) {
+	tests := []struct {
+		name string
+		cfg  LogMetricRuleConfig
+	}{
+		{"missing name", LogMetricRuleConfig{Pattern: "x"}},
+		{"bad type", LogMetricRuleConfig{Name: "a", Type: "histogram"}},
+		{"gauge without value", LogMetricRuleConfig{Name: "a", Type: "gauge", Pattern: "x"}},
+		{"unknown value group", LogMetricRuleConfig{Name: "a", Pattern: "x", ValueGroup: "v"}},
+		{"unknown label", LogMetricRuleConfig{Name: "a", Pattern: "(?P<x>.)", Labels: []string{"y"}}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if _, err := newLogMetricExtractor([]LogMetricRuleConfig{tt.cfg}); err == nil {
+				t.Error("expected error")
+			}
+		})
+	}
+}
+
+func TestLogPipeline_MetricsSeeFilteredLines(t *testing.T) {
+	p, err := newLogPipeline(LogRules{
+		Filter:  FilterConfig{Rules: []FilterRuleConfig{{Service: "CRON"}}},
+		Metrics: []LogMetricRuleConfig{{Name: "cron_runs", Service: "CRON"}},
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	kept := p.filterEntries([]LogEntry{
+		{Service: "CRON", Level: "info"},
+		{Service: "CRON", Level: "info"},
+	})
+	if len(kept) != 0 {
+		t.Errorf("expected CRON lines filtered, got %d", len(kept))
+	}
+	samples := p.takeLogMetrics()
+	if len(samples) != 1 || samples[0].Value != 2 {
+		t.Errorf("expected filtered lines counted, got %+v", samples)
+	}
+}
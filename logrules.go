@@ -0,0 +1,126 @@
+package main
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+)
+
+// LogRules is the optional JSON rules file passed via --log-rules (env LOG_RULES_FILE).
+// Every section is optional; a missing file section keeps the built-in defaults.
+type LogRules struct {
+	Redact    RedactConfig          `json:"redact"`
+	Filter    FilterConfig          `json:"filter"`
+	Metrics   []LogMetricRuleConfig `json:"metrics"`
+	Discovery DiscoveryConfig       `json:"discovery"`
+	Security  SecurityConfig        `json:"security"`
+	Audit     AuditConfig           `json:"audit"`
+	// Sources configures individual log sources by name (journald,
+	// syslog_file, file, container, syslog_receiver).
+	Sources map[string]LogSourceConfig `json:"sources"`
+}
+
+// LogSourceConfig disables a log source or gives it its own filter, applied
+// after the global filter section.
+type LogSourceConfig struct {
+	Disabled bool         `json:"disabled"`
+	Filter   FilterConfig `json:"filter"`
+}
+
+// RedactConfig controls secret/PII redaction of outgoing log messages.
+type RedactConfig struct {
+	// DisableBuiltin lists built-in detectors to turn off (jwt, bearer,
+	// aws_access_key, aws_secret_key, url_credentials, card, email).
+	DisableBuiltin    []string           `json:"disable_builtin"`
+	DisableAllBuiltin bool               `json:"disable_all_builtin"`
+	Rules             []RedactRuleConfig `json:"rules"`
+}
+
+// RedactRuleConfig is a user-defined redaction rule.
+type RedactRuleConfig struct {
+	Name        string `json:"name"`
+	Pattern     string `json:"pattern"`
+	Replacement string `json:"replacement"`
+}
+
+// loadLogRules reads and decodes the rules file. An empty path yields the defaults.
+func loadLogRules(path string) (LogRules, error) {
+	var rules LogRules
+	if path == "" {
+		return rules, nil
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return rules, fmt.Errorf("read log rules: %w", err)
+	}
+	if err := json.Unmarshal(data, &rules); err != nil {
+		return rules, fmt.Errorf("parse log rules %s: %w", path, err)
+	}
+	return rules, nil
+}
+
+// FilterConfig controls which log entries are shipped.
+type FilterConfig struct {
+	Rules      []FilterRuleConfig `json:"rules"`
+	RateLimits []RateLimitConfig  `json:"rate_limits"`
+	// Sampling maps a level (debug, info, warning) to the fraction of entries kept.
+	Sampling map[string]float64 `json:"sampling"`
+}
+
+// FilterRuleConfig matches entries by service, level, source path and message regex.
+// Service and Source accept shell-style globs.
+type FilterRuleConfig struct {
+	Action  string   `json:"action"` // "drop" (default) or "keep"
+	Service string   `json:"service"`
+	Levels  []string `json:"levels"`
+	Source  string   `json:"source"`
+	Pattern string   `json:"pattern"`
+}
+
+// RateLimitConfig caps entries per minute for each source matching the glob.
+type RateLimitConfig struct {
+	Source    string `json:"source"`
+	PerMinute int    `json:"per_minute"`
+}
+
+// LogMetricRuleConfig derives a counter or gauge from matching log lines.
+// Named capture groups in Pattern become labels unless Labels narrows them.
+type LogMetricRuleConfig struct {
+	Name       string   `json:"name"`
+	Type       string   `json:"type"` // "counter" (default) or "gauge"
+	Service    string   `json:"service"`
+	Source     string   `json:"source"`
+	Field      string   `json:"field"` // message (default), service, level, host, source, unit, transport, attributes.<key> or container.<id|name|image|labels.<key>>
+	Pattern    string   `json:"pattern"`
+	Labels     []string `json:"labels"`
+	ValueGroup string   `json:"value_group"` // named group holding the value (required for gauges)
+}
+
+// DiscoveryConfig controls where the agent looks for log files to offer for
+// monitoring. Zero values keep the defaults (common roots, depth 3, *.log).
+type DiscoveryConfig struct {
+	Roots          []string `json:"roots"`
+	MaxDepth       int      `json:"max_depth"`
+	Include        []string `json:"include"` // globs on the base name, or the full path when they contain "/"
+	Exclude        []string `json:"exclude"` // added to the built-in excludes; also prunes directories
+	FollowSymlinks bool     `json:"follow_symlinks"`
+	MaxFiles       int      `json:"max_files"`
+	TimeBudget     string   `json:"time_budget"` // e.g. "10s"
+}
+
+// SecurityConfig tunes security event extraction (--security-events).
+type SecurityConfig struct {
+	// BruteForceThreshold is the number of failed logins from one source IP
+	// within BruteForceWindow (e.g. "5m") that raises a brute_force event.
+	BruteForceThreshold int    `json:"brute_force_threshold"`
+	BruteForceWindow    string `json:"brute_force_window"`
+}
+
+// AuditConfig selects which auditd events are shipped (--audit-events).
+// Empty lists match everything.
+type AuditConfig struct {
+	Keys         []string `json:"keys"`          // audit rule keys (-k), e.g. "exec", "identity"
+	Types        []string `json:"types"`         // events containing one of these record types
+	ExcludeTypes []string `json:"exclude_types"` // primary event types to drop, e.g. "CRED_REFR"
+}
@@ -11,6 +11,7 @@ import (
 	"os/exec"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
@@ -21,11 +22,37 @@ type LogEntry struct {
 	Service   string `json:"service"`
 	Host      string `json:"host"`
 	Message   string `json:"message"`
+	Source    string `json:"source,omitempty"` // "journald" or the file path the line came from
+
+	// Journald metadata, used to correlate lines with units and watchdog PIDs
+	Unit      string        `json:"unit,omitempty"`
+	PID       int32         `json:"pid,omitempty"`
+	UID       *int          `json:"uid,omitempty"` // pointer so root (0) is still sent
+	BootID    string        `json:"boot_id,omitempty"`
+	Transport string        `json:"transport,omitempty"` // journal, syslog, stdout, kernel, audit
+	CodeFile  string        `json:"code_file,omitempty"`
+	CodeLine  int           `json:"code_line,omitempty"`
+	Container *LogContainer `json:"container,omitempty"`
+
+	// Attributes carries source-specific metadata (syslog facility, structured data, ...).
+	Attributes map[string]string `json:"attributes,omitempty"`
+}
+
+// LogContainer identifies the container a log line came from.
+type LogContainer struct {
+	ID     string            `json:"id,omitempty"`
+	Name   string            `json:"name,omitempty"`
+	Image  string            `json:"image,omitempty"`
+	Labels map[string]string `json:"labels,omitempty"` // compose and Kubernetes labels
 }
 
 // LogIngestPayload is the payload sent to /api/ingest/server-logs
 type LogIngestPayload struct {
-	Entries []LogEntry `json:"entries"`
+	Entries    []LogEntry     `json:"entries"`
+	Redactions map[string]int `json:"redactions,omitempty"`
+	// Dropped counts entries per source that were never delivered since the
+	// previous accepted request (per-poll caps, batches that kept failing).
+	Dropped map[string]int `json:"dropped,omitempty"`
 }
 
 // journalctlEntry represents a parsed journalctl JSON line
@@ -36,33 +63,123 @@ type journalctlEntry struct {
 	Comm              string `json:"_COMM"`
 	Hostname          string `json:"_HOSTNAME"`
 	RealtimeTimestamp string `json:"__REALTIME_TIMESTAMP"`
+	Cursor            string `json:"__CURSOR"`
+	SystemdUnit       string `json:"_SYSTEMD_UNIT"`
+	SystemdUserUnit   string `json:"_SYSTEMD_USER_UNIT"`
+	PID               string `json:"_PID"`
+	SyslogPID         string `json:"SYSLOG_PID"`
+	UID               string `json:"_UID"`
+	BootID            string `json:"_BOOT_ID"`
+	Transport         string `json:"_TRANSPORT"`
+	CodeFile          string `json:"CODE_FILE"`
+	CodeLine          string `json:"CODE_LINE"`
+	ContainerName     string `json:"CONTAINER_NAME"`
+	ContainerID       string `json:"CONTAINER_ID_FULL"`
+	ContainerIDShort  string `json:"CONTAINER_ID"`
+}
+
+// journalState remembers the last journal cursor so each poll only returns
+// entries written since the previous one.
+type journalState struct {
+	mu     sync.Mutex
+	cursor string
 }
 
+// maxLogEntries is the most entries sent in one request; see logbatch.go.
 const maxLogEntries = 200
 
-// collectLogs gathers recent system logs from journalctl or syslog fallback.
-// sinceDuration controls how far back to look (e.g. 1*time.Minute for frequent polling).
-func collectLogs(since time.Duration) ([]LogEntry, error) {
-	entries, err := collectJournalctlLogs(since)
-	if err == nil && len(entries) > 0 {
-		return entries, nil
+// maxLogScanEntries bounds how many raw lines are read per poll before filtering,
+// so noisy sources can be dropped without starving the maxLogEntries budget.
+const maxLogScanEntries = 1000
+
+// journaldSource reads the system journal, narrowed to LogUnits when set.
+type journaldSource struct {
+	units []string
+	pos   *journalState
+}
+
+func newJournaldSource(cfg Config, _ *log.Logger) (logSource, error) {
+	if _, err := exec.LookPath("journalctl"); err != nil {
+		return nil, nil
 	}
+	return &journaldSource{units: cfg.LogUnits, pos: &journalState{}}, nil
+}
 
-	// Fallback: read /var/log/syslog or /var/log/messages
-	return collectSyslogFallback(since)
+func (s *journaldSource) name() string { return "journald" }
+
+// inUse reports whether the journal holds entries to ship. Hosts where it is
+// empty or unreadable (rsyslog-only setups, some containers) use syslog_file
+// instead. With a unit filter the journal is always used, since a plain
+// syslog file cannot honour it.
+func (s *journaldSource) inUse() bool {
+	if len(s.units) > 0 {
+		return true
+	}
+	out, err := exec.Command("journalctl", "--output", "json", "--no-pager", "-n", "1").Output()
+	return err == nil && len(bytes.TrimSpace(out)) > 0
+}
+
+// journalReadable reports whether journalctl can read the journal at all,
+// whether or not it holds entries.
+func journalReadable() bool {
+	return exec.Command("journalctl", "--no-pager", "-q", "-n", "0").Run() == nil
 }
 
-// collectJournalctlLogs reads recent logs from journalctl in JSON format.
-// since controls how far back to look for new entries.
-func collectJournalctlLogs(since time.Duration) ([]LogEntry, error) {
-	// Convert duration to journalctl's --since format (e.g. "1 minutes ago")
-	sinceStr := fmt.Sprintf("%d seconds ago", int(since.Seconds()))
-	cmd := exec.Command("journalctl",
-		"--since", sinceStr,
-		"--output", "json",
-		"--no-pager",
-		"-n", strconv.Itoa(maxLogEntries),
-	)
+// collect reads journal entries written since the previous call.
+func (s *journaldSource) collect(since time.Duration) ([]LogEntry, error) {
+	var match []string
+	for _, unit := range s.units {
+		match = append(match, "--unit", unit)
+	}
+	entries, err := readJournal(s.pos, since, match...)
+	if err != nil {
+		logHealth.fail("journald", "journald", err)
+		return nil, err
+	}
+	logHealth.read("journald", "journald", entryBytes(entries), len(entries), lastEntryTime(entries))
+	return entries, nil
+}
+
+// syslogFileSource tails /var/log/syslog or /var/log/messages on hosts where
+// journald is missing or empty.
+type syslogFileSource struct {
+	tails *fileTailer
+}
+
+func newSyslogFileSource(cfg Config, _ *log.Logger) (logSource, error) {
+	if len(cfg.LogUnits) > 0 {
+		return nil, nil // a plain syslog file cannot honour the unit filter
+	}
+	return &syslogFileSource{tails: &fileTailer{cursors: make(map[string]*fileCursor)}}, nil
+}
+
+func (s *syslogFileSource) name() string { return "syslog_file" }
+
+func (s *journalState) get() string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return s.cursor
+}
+
+func (s *journalState) set(cursor string) {
+	s.mu.Lock()
+	s.cursor = cursor
+	s.mu.Unlock()
+}
+
+// readJournal reads entries after the cursor in pos, narrowed by extra
+// journalctl arguments (units, field matches, -k). Collectors that need their
+// own view of the journal keep their own journalState.
+func readJournal(pos *journalState, since time.Duration, match ...string) ([]LogEntry, error) {
+	args := []string{"--output", "json", "--no-pager", "-n", strconv.Itoa(maxLogScanEntries)}
+	args = append(args, match...)
+	if cursor := pos.get(); cursor != "" {
+		args = append(args, "--after-cursor", cursor)
+	} else {
+		// Convert duration to journalctl's --since format (e.g. "60 seconds ago")
+		args = append(args, "--since", fmt.Sprintf("%d seconds ago", int(since.Seconds())))
+	}
+	cmd := exec.Command("journalctl", args...)
 
 	out, err := cmd.Output()
 	if err != nil {
@@ -71,6 +188,7 @@ func collectJournalctlLogs(since time.Duration) ([]LogEntry, error) {
 
 	hostname, _ := os.Hostname()
 	var entries []LogEntry
+	lastCursor := ""
 
 	scanner := bufio.NewScanner(bytes.NewReader(out))
 	// Increase buffer for potentially long log lines
@@ -82,51 +200,29 @@ func collectJournalctlLogs(since time.Duration) ([]LogEntry, error) {
 			continue
 		}
 
-		var je journalctlEntry
-		if err := json.Unmarshal(line, &je); err != nil {
-			continue
-		}
-
-		if je.Message == "" {
-			continue
-		}
-
-		// Skip omnipulse-agent's own logs to avoid feedback loop
-		svc := je.SyslogIdentifier
-		if svc == "" {
-			svc = je.Comm
+		entry, cursor, ok := parseJournalLine(line, hostname)
+		if cursor != "" {
+			lastCursor = cursor
 		}
-		if svc == "omnipulse-agent" || svc == serviceName {
-			continue
-		}
-
-		ts := parseJournalTimestamp(je.RealtimeTimestamp)
-		level := mapJournalPriority(je.Priority)
-
-		host := je.Hostname
-		if host == "" {
-			host = hostname
+		if ok {
+			entries = append(entries, entry)
 		}
+	}
 
-		entries = append(entries, LogEntry{
-			Timestamp: ts,
-			Level:     level,
-			Service:   svc,
-			Host:      host,
-			Message:   je.Message,
-		})
+	if lastCursor != "" {
+		pos.set(lastCursor)
 	}
 
-	if len(entries) > maxLogEntries {
-		entries = entries[len(entries)-maxLogEntries:]
+	if len(entries) > maxLogScanEntries {
+		entries = entries[len(entries)-maxLogScanEntries:]
 	}
 
 	return entries, nil
 }
 
-// collectSyslogFallback reads the last lines from /var/log/syslog or /var/log/messages.
-// since controls how many lines to tail (shorter durations = fewer lines).
-func collectSyslogFallback(since time.Duration) ([]LogEntry, error) {
+// collect reads new lines from /var/log/syslog or /var/log/messages.
+// since controls how many lines to tail on the first read (shorter durations = fewer lines).
+func (s *syslogFileSource) collect(since time.Duration) ([]LogEntry, error) {
 	logFiles := []string{"/var/log/syslog", "/var/log/messages"}
 	var target string
 	for _, f := range logFiles {
@@ -144,12 +240,12 @@ func collectSyslogFallback(since time.Duration) ([]LogEntry, error) {
 	if tailLines < 10 {
 		tailLines = 10
 	}
-	if tailLines > 200 {
-		tailLines = 200
+	if tailLines > maxLogScanEntries {
+		tailLines = maxLogScanEntries
 	}
-	cmd := exec.Command("tail", "-n", strconv.Itoa(tailLines), target)
-	out, err := cmd.Output()
+	lines, err := s.tails.readNewLines(target, tailLines)
 	if err != nil {
+		logHealth.fail(target, "syslog_file", err)
 		return nil, fmt.Errorf("tail %s: %w", target, err)
 	}
 
@@ -157,9 +253,8 @@ func collectSyslogFallback(since time.Duration) ([]LogEntry, error) {
 	now := time.Now().UTC()
 	var entries []LogEntry
 
-	scanner := bufio.NewScanner(bytes.NewReader(out))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
@@ -178,12 +273,77 @@ func collectSyslogFallback(since time.Duration) ([]LogEntry, error) {
 			Service:   svc,
 			Host:      hostname,
 			Message:   msg,
+			Source:    target,
 		})
 	}
 
+	logHealth.readLines(target, "syslog_file", lines, entries, s.tails.cursor(target))
 	return entries, nil
 }
 
+// toLogEntry copies journald metadata fields onto entry.
+func (je journalctlEntry) toLogEntry(entry LogEntry) LogEntry {
+	entry.Unit = firstNonEmpty(je.SystemdUnit, je.SystemdUserUnit)
+	if pid, err := strconv.ParseInt(firstNonEmpty(je.PID, je.SyslogPID), 10, 32); err == nil {
+		entry.PID = int32(pid)
+	}
+	if uid, err := strconv.Atoi(je.UID); err == nil {
+		entry.UID = &uid
+	}
+	entry.BootID = je.BootID
+	entry.Transport = je.Transport
+	entry.CodeFile = je.CodeFile
+	if line, err := strconv.Atoi(je.CodeLine); err == nil {
+		entry.CodeLine = line
+	}
+	if je.ContainerName != "" || je.ContainerID != "" || je.ContainerIDShort != "" {
+		entry.Container = &LogContainer{
+			ID:   firstNonEmpty(je.ContainerID, je.ContainerIDShort),
+			Name: je.ContainerName,
+		}
+	}
+	return entry
+}
+
+// parseJournalLine converts one line of journalctl JSON output. The cursor is
+// returned even for entries that are skipped (empty or the agent's own logs).
+func parseJournalLine(line []byte, hostname string) (LogEntry, string, bool) {
+	var je journalctlEntry
+	if err := json.Unmarshal(line, &je); err != nil {
+		return LogEntry{}, "", false
+	}
+
+	if je.Message == "" {
+		return LogEntry{}, je.Cursor, false
+	}
+
+	// Skip omnipulse-agent's own logs to avoid feedback loop
+	svc := je.SyslogIdentifier
+	if svc == "" {
+		svc = je.Comm
+	}
+	if svc == "omnipulse-agent" || svc == serviceName {
+		return LogEntry{}, je.Cursor, false
+	}
+
+	ts := parseJournalTimestamp(je.RealtimeTimestamp)
+	level := mapJournalPriority(je.Priority)
+
+	host := je.Hostname
+	if host == "" {
+		host = hostname
+	}
+
+	return je.toLogEntry(LogEntry{
+		Timestamp: ts,
+		Level:     level,
+		Service:   svc,
+		Host:      host,
+		Message:   je.Message,
+		Source:    "journald",
+	}), je.Cursor, true
+}
+
 // parseJournalTimestamp converts journalctl's __REALTIME_TIMESTAMP (microseconds since epoch) to RFC3339
 func parseJournalTimestamp(usecStr string) string {
 	usec, err := strconv.ParseInt(usecStr, 10, 64)
@@ -235,29 +395,15 @@ func parseSyslogLine(line string) (service, message string) {
 	return
 }
 
-// sendLogsToBackend collects and sends system logs.
-// since controls how far back to look for new log entries.
-func sendLogsToBackend(client *http.Client, cfg Config, logger *log.Logger, since time.Duration) {
-	entries, err := collectLogs(since)
-	if err != nil {
-		logger.Printf("log collect error: %v", err)
-		return
-	}
-
-	if len(entries) == 0 {
-		return
-	}
-
-	payload := LogIngestPayload{Entries: entries}
-	if err := sendLogs(client, cfg, payload); err != nil {
-		logger.Printf("log ingest failed: %v", err)
-	} else {
-		logger.Printf("logs sent: %d entries", len(entries))
-	}
+// sendLogs sends log payload to backend.
+// Entries are redacted by the configured pipeline before they leave the host.
+func sendLogs(client *http.Client, cfg Config, payload LogIngestPayload) error {
+	cfg.Pipeline.prepare(&payload)
+	return postLogs(client, cfg, payload)
 }
 
-// sendLogs sends log payload to backend
-func sendLogs(client *http.Client, cfg Config, payload LogIngestPayload) error {
+// postLogs sends an already prepared payload.
+func postLogs(client *http.Client, cfg Config, payload LogIngestPayload) error {
 	body, err := json.Marshal(payload)
 	if err != nil {
 		return err
@@ -278,17 +424,23 @@ func sendLogs(client *http.Client, cfg Config, payload LogIngestPayload) error {
 	defer resp.Body.Close()
 
 	if resp.StatusCode >= 400 {
-		return fmt.Errorf("server returned %d", resp.StatusCode)
+		return &logStatusError{status: resp.StatusCode}
 	}
 	return nil
 }
 
-// collectFileLogs tails the last lines of a specific log file.
-// since controls how many lines to tail (scaled by duration).
-func collectFileLogs(path string, since time.Duration) []LogEntry {
+// collectFileLogs returns lines appended to a specific log file since the last poll.
+// since controls how many lines to tail on the first read (scaled by duration).
+// Read errors are not returned but recorded in logHealth.
+func collectFileLogs(tails *fileTailer, path string, since time.Duration) []LogEntry {
 	// Check if file exists and is readable
 	info, err := os.Stat(path)
-	if err != nil || info.IsDir() {
+	if err != nil {
+		logHealth.fail(path, "file", err)
+		return nil
+	}
+	if info.IsDir() {
+		logHealth.fail(path, "file", fmt.Errorf("%s is a directory", path))
 		return nil
 	}
 
@@ -301,27 +453,26 @@ func collectFileLogs(path string, since time.Duration) []LogEntry {
 		tailLines = 100
 	}
 
-	cmd := exec.Command("tail", "-n", strconv.Itoa(tailLines), path)
-	out, err := cmd.Output()
+	lines, err := tails.readNewLines(path, tailLines)
 	if err != nil {
+		logHealth.fail(path, "file", err)
 		return nil
 	}
+	entries := fileLogEntries(path, lines)
+	logHealth.readLines(path, "file", lines, entries, tails.cursor(path))
+	return entries
+}
 
+// fileLogEntries turns raw lines of a plain log file into entries.
+func fileLogEntries(path string, lines []string) []LogEntry {
 	hostname, _ := os.Hostname()
 	now := time.Now().UTC()
 
-	// Use filename without extension as service name
-	baseName := path
-	if idx := strings.LastIndex(path, "/"); idx >= 0 {
-		baseName = path[idx+1:]
-	}
-	// Strip .log extension
-	baseName = strings.TrimSuffix(baseName, ".log")
+	baseName := fileLogService(path)
 
 	var entries []LogEntry
-	scanner := bufio.NewScanner(bytes.NewReader(out))
-	for scanner.Scan() {
-		line := strings.TrimSpace(scanner.Text())
+	for _, line := range lines {
+		line = strings.TrimSpace(line)
 		if line == "" {
 			continue
 		}
@@ -329,18 +480,33 @@ func collectFileLogs(path string, since time.Duration) []LogEntry {
 		// Detect basic log level from content
 		level := detectLogLevel(line)
 
+		ts := now
+		if parsed, ok := parseLineTimestamp(line); ok {
+			ts = parsed.UTC()
+		}
+
 		entries = append(entries, LogEntry{
-			Timestamp: now.Format(time.RFC3339Nano),
+			Timestamp: ts.Format(time.RFC3339Nano),
 			Level:     level,
 			Service:   baseName,
 			Host:      hostname,
 			Message:   line,
+			Source:    path,
 		})
 	}
 
 	return entries
 }
 
+// fileLogService uses the filename without .log extension as service name
+func fileLogService(path string) string {
+	baseName := path
+	if idx := strings.LastIndex(path, "/"); idx >= 0 {
+		baseName = path[idx+1:]
+	}
+	return strings.TrimSuffix(baseName, ".log")
+}
+
 // detectLogLevel does a simple keyword-based detection of log level
 func detectLogLevel(line string) string {
 	lower := strings.ToLower(line)
@@ -356,28 +522,78 @@ func detectLogLevel(line string) string {
 	}
 }
 
-// sendFileLogsToBackend collects and sends log entries from monitored .log files
-func sendFileLogsToBackend(client *http.Client, cfg Config, logger *log.Logger, paths []string, since time.Duration) {
-	var allEntries []LogEntry
+// monitoredFileSource tails the log files the backend asks to monitor.
+// Lines missed while the backend was unreachable are replayed, from the
+// cursors saved before the first failed send, once it accepts logs again
+// and before any newer lines are shipped.
+type monitoredFileSource struct {
+	tails      *fileTailer
+	fetched    bool
+	paths      []string
+	checkpoint map[string]*fileCursor // cursors before the latest collect
+	outageFrom map[string]*fileCursor // cursors before the first failed send
+}
 
-	for _, p := range paths {
-		entries := collectFileLogs(p, since)
-		allEntries = append(allEntries, entries...)
-	}
+func newMonitoredFileSource(Config, *log.Logger) (logSource, error) {
+	return &monitoredFileSource{tails: &fileTailer{cursors: make(map[string]*fileCursor)}}, nil
+}
+
+func (s *monitoredFileSource) name() string { return "file" }
 
-	if len(allEntries) == 0 {
+// refresh fetches the monitored paths, backfilling newly enabled ones.
+func (s *monitoredFileSource) refresh(client *http.Client, cfg Config, logger *log.Logger) {
+	paths, err := fetchMonitoredLogPaths(client, cfg)
+	if err != nil {
 		return
 	}
+	if added := addedPaths(s.paths, paths); s.fetched && len(added) > 0 && cfg.LogBackfillAge > 0 {
+		if err := backfillFileLogsToBackend(client, cfg, logger, s.tails, added, nil, nil); err != nil {
+			logger.Printf("backfill ingest failed: %v", err)
+		}
+	}
+	s.paths, s.fetched = paths, true
+	// Forget files no longer monitored
+	logHealth.prune("file", paths)
+	s.tails.prune(paths)
+}
 
-	// Cap total entries
-	if len(allEntries) > maxLogEntries {
-		allEntries = allEntries[len(allEntries)-maxLogEntries:]
+func (s *monitoredFileSource) collect(since time.Duration) ([]LogEntry, error) {
+	if len(s.paths) == 0 {
+		return nil, nil
+	}
+	s.checkpoint = s.tails.snapshot(s.paths)
+	var entries []LogEntry
+	for _, p := range s.paths {
+		entries = append(entries, collectFileLogs(s.tails, p, since)...)
 	}
+	return entries, nil
+}
 
-	payload := LogIngestPayload{Entries: allEntries}
-	if err := sendLogs(client, cfg, payload); err != nil {
-		logger.Printf("file log ingest failed: %v", err)
-	} else {
-		logger.Printf("file logs sent: %d entries from %d files", len(allEntries), len(paths))
+// shipped starts an outage when a send fails and backfill is enabled. The
+// failed entries are not counted as dropped, since the replay delivers them.
+func (s *monitoredFileSource) shipped(_ *http.Client, cfg Config, _ *log.Logger, err error) bool {
+	if err == nil || cfg.LogBackfillAge <= 0 {
+		return false
+	}
+	if s.outageFrom == nil {
+		s.outageFrom = s.checkpoint
 	}
+	return true
+}
+
+// replay resends the lines between the cursors saved before the first failed
+// send and those before the latest collect. Each path leaves the outage once
+// its lines are delivered, so a failure part way does not resend them.
+func (s *monitoredFileSource) replay(client *http.Client, cfg Config, logger *log.Logger) error {
+	for _, p := range s.paths {
+		if _, ok := s.outageFrom[p]; !ok {
+			continue
+		}
+		if err := backfillFileLogsToBackend(client, cfg, logger, s.tails, []string{p}, s.outageFrom, s.checkpoint); err != nil {
+			return err
+		}
+		delete(s.outageFrom, p)
+	}
+	s.outageFrom = nil
+	return nil
 }
@@ -0,0 +1,73 @@
+package main
+
+import (
+	"encoding/json"
+	"testing"
+)
+
+// --- journalctlEntry Tests ---
+
+func TestJournalctlEntry_ToLogEntry(t *testing.T) {
+	line := `{"MESSAGE":"Started worker","PRIORITY":"6","SYSLOG_IDENTIFIER":"app","_SYSTEMD_UNIT":"app.service",` +
+		`"_PID":"4242","_UID":"0","_BOOT_ID":"b00t","_TRANSPORT":"stdout","CODE_FILE":"main.c","CODE_LINE":"17",` +
+		`"CONTAINER_NAME":"web-1","CONTAINER_ID":"abc123","CONTAINER_ID_FULL":"abc123def456"}`
+
+	var je journalctlEntry
+	if err := json.Unmarshal([]byte(line), &je); err != nil {
+		t.Fatalf("unmarshal: %v", err)
+	}
+	entry := je.toLogEntry(LogEntry{Message: je.Message})
+
+	if entry.Unit != "app.service" {
+		t.Errorf("expected unit app.service, got %q", entry.Unit)
+	}
+	if entry.PID != 4242 {
+		t.Errorf("expected pid 4242, got %d", entry.PID)
+	}
+	if entry.UID == nil || *entry.UID != 0 {
+		t.Errorf("expected uid 0 to be kept, got %v", entry.UID)
+	}
+	if entry.BootID != "b00t" || entry.Transport != "stdout" {
+		t.Errorf("unexpected boot/transport: %q/%q", entry.BootID, entry.Transport)
+	}
+	if entry.CodeFile != "main.c" || entry.CodeLine != 17 {
+		t.Errorf("unexpected code location: %s:%d", entry.CodeFile, entry.CodeLine)
+	}
+	if entry.Container == nil || entry.Container.ID != "abc123def456" || entry.Container.Name != "web-1" {
+		t.Errorf("unexpected container: %+v", entry.Container)
+	}
+}
+
+func TestJournalctlEntry_ToLogEntryMinimal(t *testing.T) {
+	je := journalctlEntry{Message: "hello", SyslogPID: "99"}
+	entry := je.toLogEntry(LogEntry{Message: je.Message})
+
+	if entry.PID != 99 {
+		t.Errorf("expected SYSLOG_PID fallback, got %d", entry.PID)
+	}
+	if entry.UID != nil || entry.Container != nil {
+		t.Errorf("expected empty optional fields, got uid=%v container=%v", entry.UID, entry.Container)
+	}
+
+	body, _ := json.Marshal(entry)
+	var decoded map[string]interface{}
+	json.Unmarshal(body, &decoded)
+	for _, key := range []string{"unit", "uid", "boot_id", "container", "code_line"} {
+		if _, ok := decoded[key]; ok {
+			t.Errorf("expected %q omitted from JSON", key)
+		}
+	}
+}
+
+// --- mapJournalPriority Tests ---
+
+func TestMapJournalPriority(t *testing.T) {
+	tests := map[string]string{
+		"0": "error", "3": "error", "4": "warning", "5": "info", "6": "info", "7": "debug", "": "info",
+	}
+	for input, expect := range tests {
+		if got := mapJournalPriority(input); got != expect {
+			t.Errorf("mapJournalPriority(%q) = %q, expected %q", input, got, expect)
+		}
+	}
+}
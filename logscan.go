@@ -0,0 +1,280 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Defaults for log file discovery when the rules file has no discovery section.
+var (
+	defaultLogScanRoots    = []string{"/var/log", "/opt", "/home", "/srv", "/tmp"}
+	defaultLogScanInclude  = []string{"*.log"}
+	defaultLogScanMaxDepth = 3
+	defaultLogScanMaxFiles = 5000
+	defaultLogScanBudget   = 30 * time.Second
+)
+
+// builtinLogScanExclude is always applied: VCS/dependency trees and rotated or
+// compressed files, which are read through backfill rather than discovered.
+var builtinLogScanExclude = []string{
+	".git", "node_modules", "__pycache__",
+	"*.gz", "*.bz2", "*.xz", "*.[0-9]", "*.[0-9][0-9]",
+}
+
+// logScanner walks the configured roots looking for log files. Include and
+// exclude globs without a "/" match the base name; others match the full path.
+// Excludes apply to directories as well as files.
+type logScanner struct {
+	roots          []string
+	maxDepth       int
+	include        []string
+	exclude        []string
+	followSymlinks bool
+	maxFiles       int
+	budget         time.Duration
+}
+
+// newLogScanner validates the discovery section and fills in defaults.
+func newLogScanner(cfg DiscoveryConfig) (*logScanner, error) {
+	s := &logScanner{
+		roots:          cfg.Roots,
+		maxDepth:       cfg.MaxDepth,
+		include:        cfg.Include,
+		exclude:        append(append([]string{}, builtinLogScanExclude...), cfg.Exclude...),
+		followSymlinks: cfg.FollowSymlinks,
+		maxFiles:       cfg.MaxFiles,
+		budget:         defaultLogScanBudget,
+	}
+	if len(s.roots) == 0 {
+		s.roots = defaultLogScanRoots
+	}
+	if s.maxDepth <= 0 {
+		s.maxDepth = defaultLogScanMaxDepth
+	}
+	if len(s.include) == 0 {
+		s.include = defaultLogScanInclude
+	}
+	if s.maxFiles <= 0 {
+		s.maxFiles = defaultLogScanMaxFiles
+	}
+	if cfg.TimeBudget != "" {
+		d, err := time.ParseDuration(cfg.TimeBudget)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("discovery: invalid time_budget %q", cfg.TimeBudget)
+		}
+		s.budget = d
+	}
+
+	for _, root := range s.roots {
+		if !filepath.IsAbs(root) {
+			return nil, fmt.Errorf("discovery: root %q must be an absolute path", root)
+		}
+	}
+	for _, p := range append(append([]string{}, s.include...), s.exclude...) {
+		if _, err := path.Match(p, ""); err != nil {
+			return nil, fmt.Errorf("discovery: bad pattern %q: %w", p, err)
+		}
+	}
+	return s, nil
+}
+
+// logScan is the state of a single discovery run.
+type logScan struct {
+	*logScanner
+	deadline  time.Time
+	files     []DiscoveredLogFile
+	seen      map[string]bool // reported paths (resolved when following symlinks)
+	visited   map[string]bool // resolved directories, guards against symlink loops
+	truncated string          // budget that stopped the scan, if any
+}
+
+// scan walks every root and returns the files found. truncated names the
+// budget ("max_files" or "time_budget") that ended the scan early.
+func (s *logScanner) scan() (files []DiscoveredLogFile, truncated string) {
+	if s == nil {
+		s, _ = newLogScanner(DiscoveryConfig{})
+	}
+	st := &logScan{
+		logScanner: s,
+		deadline:   time.Now().Add(s.budget),
+		seen:       make(map[string]bool),
+		visited:    make(map[string]bool),
+	}
+
+	for _, root := range s.roots {
+		info, err := os.Stat(root)
+		if err != nil || !info.IsDir() {
+			continue
+		}
+		st.scanDir(filepath.Clean(root), 0)
+		if st.truncated != "" {
+			break
+		}
+	}
+	return st.files, st.truncated
+}
+
+func matchAny(patterns []string, p string) bool {
+	base := filepath.Base(p)
+	for _, pattern := range patterns {
+		target := base
+		if strings.Contains(pattern, "/") {
+			target = p
+		}
+		if ok, _ := path.Match(pattern, target); ok {
+			return true
+		}
+	}
+	return false
+}
+
+// overBudget reports whether the scan must stop, recording why.
+func (st *logScan) overBudget() bool {
+	switch {
+	case st.truncated != "":
+	case len(st.files) >= st.maxFiles:
+		st.truncated = "max_files"
+	case time.Now().After(st.deadline):
+		st.truncated = "time_budget"
+	default:
+		return false
+	}
+	return true
+}
+
+// scanDir recursively scans a directory for matching files up to maxDepth
+func (st *logScan) scanDir(dir string, depth int) {
+	if depth > st.maxDepth || st.overBudget() {
+		return
+	}
+
+	if st.followSymlinks {
+		real, err := filepath.EvalSymlinks(dir)
+		if err != nil || st.visited[real] {
+			return
+		}
+		st.visited[real] = true
+	}
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return // skip unreadable dirs (permission denied, etc.)
+	}
+
+	for _, entry := range entries {
+		if st.overBudget() {
+			return
+		}
+		p := filepath.Join(dir, entry.Name())
+		if matchAny(st.exclude, p) {
+			continue
+		}
+
+		isDir := entry.IsDir()
+		var info os.FileInfo
+		if entry.Type()&os.ModeSymlink != 0 {
+			// Symlinked files are reported either way; directories are only
+			// descended into when following symlinks.
+			info, err = os.Stat(p)
+			if err != nil {
+				continue
+			}
+			if info.IsDir() {
+				if st.followSymlinks {
+					st.scanDir(p, depth+1)
+				}
+				continue
+			}
+		} else if isDir {
+			st.scanDir(p, depth+1)
+			continue
+		}
+
+		if !entry.Type().IsRegular() && entry.Type()&os.ModeSymlink == 0 {
+			continue
+		}
+		if !matchAny(st.include, p) {
+			continue
+		}
+
+		key := p
+		if st.followSymlinks {
+			if real, err := filepath.EvalSymlinks(p); err == nil {
+				key = real
+			}
+		}
+		if st.seen[key] {
+			continue
+		}
+		st.seen[key] = true
+
+		if info == nil {
+			if info, err = entry.Info(); err != nil {
+				continue
+			}
+		}
+
+		// Skip empty files
+		if info.Size() == 0 {
+			continue
+		}
+
+		st.files = append(st.files, DiscoveredLogFile{
+			Path:       p,
+			SizeBytes:  info.Size(),
+			ModifiedAt: info.ModTime().UTC().Format(time.RFC3339),
+		})
+	}
+}
+
+// allows reports whether path is a file discovery would offer: under one of
+// the roots, matching an include glob and no exclude glob on any component.
+// Live tail uses it so the backend cannot stream arbitrary files; a symlink
+// must resolve to an allowed path as well.
+func (s *logScanner) allows(p string) bool {
+	if s == nil {
+		s, _ = newLogScanner(DiscoveryConfig{})
+	}
+	if !filepath.IsAbs(p) {
+		return false
+	}
+	p = filepath.Clean(p)
+	if resolved, err := filepath.EvalSymlinks(p); err == nil && resolved != p && !s.allowsPath(resolved) {
+		return false
+	}
+	return s.allowsPath(p)
+}
+
+func (s *logScanner) allowsPath(p string) bool {
+	if !matchAny(s.include, p) {
+		return false
+	}
+	for _, r := range s.roots {
+		if s.underRoot(filepath.Clean(r), p) {
+			return true
+		}
+	}
+	return false
+}
+
+// underRoot reports whether p is a file discovery reaches from root: within
+// maxDepth directories below it and with no excluded component in between.
+func (s *logScanner) underRoot(root, p string) bool {
+	rel, err := filepath.Rel(root, p)
+	if err != nil || rel == "." || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return false
+	}
+	if strings.Count(rel, string(filepath.Separator)) > s.maxDepth {
+		return false
+	}
+	for dir := p; dir != root; dir = filepath.Dir(dir) {
+		if matchAny(s.exclude, dir) {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,229 @@
+package main
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"sort"
+	"time"
+)
+
+// logSource is one origin of entries shipped to /api/ingest/server-logs. Each
+// source owns its cursor state and parser: collect returns only entries
+// written since the previous call, looking back at most since on the first.
+type logSource interface {
+	name() string
+	collect(since time.Duration) ([]LogEntry, error)
+}
+
+// logSourceRefresher is implemented by sources whose configuration comes from
+// the backend; refresh runs at startup and every facts interval.
+type logSourceRefresher interface {
+	refresh(client *http.Client, cfg Config, logger *log.Logger)
+}
+
+// logSourceShipper is implemented by sources that track delivery, e.g. to
+// replay lines lost during a backend outage. shipped is called after every
+// attempt to send the entries of one collect, with the error of a send that
+// failed after retries, and reports whether the unsent entries will be
+// replayed later.
+type logSourceShipper interface {
+	shipped(client *http.Client, cfg Config, logger *log.Logger, err error) (replays bool)
+}
+
+// logSourceReplayer is implemented by sources that resend entries lost during
+// a backend outage. replay runs before each collect is shipped, so replayed
+// lines reach the backend ahead of newer ones. While it fails, the collected
+// entries are held back: the next replay covers them.
+type logSourceReplayer interface {
+	replay(client *http.Client, cfg Config, logger *log.Logger) error
+}
+
+// logSourceCloser is implemented by sources holding listeners or processes.
+type logSourceCloser interface {
+	close()
+}
+
+// logSourceFactory builds a source from the agent config. It returns a nil
+// source when the source is not enabled on this host.
+type logSourceFactory func(cfg Config, logger *log.Logger) (logSource, error)
+
+// logSourceFactories lists the built-in sources in polling order. A new source
+// only needs a type implementing logSource and an entry here. journald must
+// come before syslog_file, which only starts when journald is not in use.
+var logSourceFactories = []struct {
+	name    string
+	factory logSourceFactory
+}{
+	{"journald", newJournaldSource},
+	{"syslog_file", newSyslogFileSource},
+	{"file", newMonitoredFileSource},
+	{"container", newContainerSource},
+	{"syslog_receiver", newSyslogReceiverSource},
+}
+
+// logSourceOptions is the compiled per-source section of the rules file.
+type logSourceOptions struct {
+	disabled bool
+	filter   *logFilter
+}
+
+// newLogSourceOptions validates the sources section of the rules file.
+func newLogSourceOptions(cfgs map[string]LogSourceConfig) (map[string]*logSourceOptions, error) {
+	known := make(map[string]bool, len(logSourceFactories))
+	for _, f := range logSourceFactories {
+		known[f.name] = true
+	}
+
+	opts := make(map[string]*logSourceOptions, len(cfgs))
+	for name, sc := range cfgs {
+		if !known[name] {
+			return nil, fmt.Errorf("sources: unknown log source %q", name)
+		}
+		filter, err := newLogFilter(sc.Filter)
+		if err != nil {
+			return nil, fmt.Errorf("sources.%s: %w", name, err)
+		}
+		opts[name] = &logSourceOptions{disabled: sc.Disabled, filter: filter}
+	}
+	return opts, nil
+}
+
+// activeLogSource is a started source with its own filter stage.
+type activeLogSource struct {
+	logSource
+	filter *logFilter
+}
+
+// logSources polls every enabled source and ships its entries separately, so
+// one failing source neither delays nor reorders another.
+type logSources struct {
+	sources []activeLogSource
+}
+
+// buildLogSource starts the named source, honouring the rules file. It
+// returns nil when the source is disabled or not applicable.
+func buildLogSource(name string, factory logSourceFactory, cfg Config, logger *log.Logger) (*activeLogSource, error) {
+	opts := cfg.LogSources[name]
+	if opts != nil && opts.disabled {
+		return nil, nil
+	}
+	src, err := factory(cfg, logger)
+	if err != nil || src == nil {
+		return nil, err
+	}
+	active := &activeLogSource{logSource: src}
+	if opts != nil {
+		active.filter = opts.filter
+	}
+	return active, nil
+}
+
+// startLogSources starts every registered source enabled for this host. The
+// system log is read from journald when the journal is in use and from
+// syslog_file otherwise, never from both.
+func startLogSources(cfg Config, logger *log.Logger) *logSources {
+	r := &logSources{}
+	var names []string
+	journal := false
+	for _, f := range logSourceFactories {
+		if f.name == "syslog_file" && journal {
+			continue
+		}
+		src, err := buildLogSource(f.name, f.factory, cfg, logger)
+		if err != nil {
+			logger.Printf("log source %s disabled: %v", f.name, err)
+			continue
+		}
+		if src == nil {
+			continue
+		}
+		if j, ok := src.logSource.(*journaldSource); ok {
+			if !j.inUse() {
+				logger.Printf("journal is empty or unreadable, reading syslog files instead")
+				continue
+			}
+			journal = true
+		}
+		r.sources = append(r.sources, *src)
+		names = append(names, f.name)
+	}
+	sort.Strings(names)
+	logger.Printf("log sources: %v", names)
+	return r
+}
+
+// refresh lets sources reload backend-provided configuration.
+func (r *logSources) refresh(client *http.Client, cfg Config, logger *log.Logger) {
+	for _, src := range r.sources {
+		if rf, ok := src.logSource.(logSourceRefresher); ok {
+			rf.refresh(client, cfg, logger)
+		}
+	}
+}
+
+// poll collects each source and ships what survives the pipeline. Once a
+// send fails after its retries the backend is taken to be down: the remaining
+// sources are left uncollected until the next poll instead of each waiting
+// through its own retries.
+func (r *logSources) poll(client *http.Client, cfg Config, logger *log.Logger, since time.Duration) {
+	for i, src := range r.sources {
+		entries, err := src.collect(since)
+		if err != nil {
+			logger.Printf("log collect error (%s): %v", src.name(), err)
+			continue
+		}
+
+		entries = cfg.Pipeline.selectEntries(entries, src.filter)
+		if rp, ok := src.logSource.(logSourceReplayer); ok {
+			if err := rp.replay(client, cfg, logger); err != nil {
+				logger.Printf("%s log replay failed: %v (%d new entries held back)", src.name(), err, len(entries))
+				r.deferRest(logger, i)
+				return
+			}
+		}
+		if len(entries) == 0 {
+			continue
+		}
+
+		sent, failed, err := shipLogs(client, cfg, entries)
+		switch {
+		case err != nil:
+			logger.Printf("%s log ingest failed: %v (%d of %d entries sent)", src.name(), err, sent, len(entries))
+		case sent < len(entries):
+			logger.Printf("%s logs sent: %d entries, %d rejected by the backend", src.name(), sent, len(entries)-sent)
+		default:
+			logger.Printf("%s logs sent: %d entries", src.name(), sent)
+		}
+		replays := false
+		if s, ok := src.logSource.(logSourceShipper); ok {
+			replays = s.shipped(client, cfg, logger, err)
+		}
+		if !replays {
+			countLogDrops(failed)
+		}
+		if err != nil {
+			r.deferRest(logger, i)
+			return
+		}
+	}
+}
+
+// deferRest logs the sources after index i that are skipped this poll.
+func (r *logSources) deferRest(logger *log.Logger, i int) {
+	if rest := len(r.sources) - i - 1; rest > 0 {
+		logger.Printf("log backend unreachable: %d more sources deferred to the next poll", rest)
+	}
+}
+
+// close releases listeners held by sources. A nil *logSources is a no-op.
+func (r *logSources) close() {
+	if r == nil {
+		return
+	}
+	for _, src := range r.sources {
+		if c, ok := src.logSource.(logSourceCloser); ok {
+			c.close()
+		}
+	}
+}
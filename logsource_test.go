@@ -0,0 +1,232 @@
+package main
+
+import (
+	"encoding/json"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+// fakeLogSource returns canned entries and records delivery results.
+type fakeLogSource struct {
+	entries []LogEntry
+	results []error
+	replays bool
+}
+
+func (s *fakeLogSource) name() string { return "fake" }
+
+func (s *fakeLogSource) collect(time.Duration) ([]LogEntry, error) {
+	entries := s.entries
+	s.entries = nil
+	return entries, nil
+}
+
+func (s *fakeLogSource) shipped(_ *http.Client, _ Config, _ *log.Logger, err error) bool {
+	s.results = append(s.results, err)
+	return s.replays
+}
+
+func TestNewLogSourceOptions(t *testing.T) {
+	if _, err := newLogSourceOptions(map[string]LogSourceConfig{"nope": {}}); err == nil {
+		t.Error("expected error for unknown source")
+	}
+	bad := LogSourceConfig{Filter: FilterConfig{Rules: []FilterRuleConfig{{Pattern: "("}}}}
+	if _, err := newLogSourceOptions(map[string]LogSourceConfig{"container": bad}); err == nil {
+		t.Error("expected error for invalid filter")
+	}
+
+	opts, err := newLogSourceOptions(map[string]LogSourceConfig{"container": {Disabled: true}})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	cfg := Config{ContainerLogs: true, LogSources: opts}
+	src, err := buildLogSource("container", newContainerSource, cfg, log.New(io.Discard, "", 0))
+	if err != nil || src != nil {
+		t.Errorf("disabled source should not start, got %v, %v", src, err)
+	}
+}
+
+func TestLogSources_PollAppliesSourceFilterAndReportsDelivery(t *testing.T) {
+	var received []LogEntry
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		var p LogIngestPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received = append(received, p.Entries...)
+	}))
+	defer server.Close()
+
+	filter, err := newLogFilter(FilterConfig{Rules: []FilterRuleConfig{{Pattern: "^healthcheck"}}})
+	if err != nil {
+		t.Fatalf("filter: %v", err)
+	}
+
+	fake := &fakeLogSource{entries: []LogEntry{
+		{Source: "fake", Level: "info", Message: "healthcheck ok"},
+		{Source: "fake", Level: "info", Message: "user login"},
+	}}
+	r := &logSources{sources: []activeLogSource{{logSource: fake, filter: filter}}}
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	logger := log.New(io.Discard, "", 0)
+
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	if len(received) != 1 || received[0].Message != "user login" {
+		t.Fatalf("expected only the unfiltered entry, got %+v", received)
+	}
+	if len(fake.results) != 1 || fake.results[0] != nil {
+		t.Fatalf("expected one successful delivery, got %v", fake.results)
+	}
+
+	// Nothing collected: no delivery attempt is reported
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	if len(fake.results) != 1 {
+		t.Fatalf("expected no report without entries, got %v", fake.results)
+	}
+
+	defer func(d time.Duration) { logRetryBackoff = d }(logRetryBackoff)
+	logRetryBackoff = 0
+	status = http.StatusServiceUnavailable
+	fake.entries = []LogEntry{{Source: "fake", Level: "info", Message: "lost"}}
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	if len(fake.results) != 2 || fake.results[1] == nil || !strings.Contains(fake.results[1].Error(), "503") {
+		t.Fatalf("expected failed delivery to be reported, got %v", fake.results)
+	}
+	if drops := logDrops.take(); drops["fake"] != 1 {
+		t.Errorf("expected unsent entry counted as dropped, got %v", drops)
+	}
+
+	// Entries the source replays later are not dropped
+	fake.replays = true
+	fake.entries = []LogEntry{{Source: "fake", Level: "info", Message: "replayed"}}
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	if drops := logDrops.take(); drops != nil {
+		t.Errorf("expected no drops for replayed entries, got %v", drops)
+	}
+
+	// A rejected batch is dropped but is not a delivery failure
+	status = http.StatusBadRequest
+	fake.entries = []LogEntry{{Source: "fake", Level: "info", Message: "malformed"}}
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	if len(fake.results) != 4 || fake.results[3] != nil {
+		t.Fatalf("expected rejection reported as delivered, got %v", fake.results)
+	}
+	if drops := logDrops.take(); drops["fake"] != 1 {
+		t.Errorf("expected rejected entry counted as dropped, got %v", drops)
+	}
+}
+
+func TestStartLogSources_ReadsSystemLogFromOneSource(t *testing.T) {
+	dir := t.TempDir()
+	t.Setenv("PATH", dir)
+	journalctl := func(output string) {
+		script := "#!/bin/sh\nprintf '" + output + "'\n"
+		if err := os.WriteFile(filepath.Join(dir, "journalctl"), []byte(script), 0o755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	names := func(cfg Config) []string {
+		var out []string
+		for _, src := range startLogSources(cfg, log.New(io.Discard, "", 0)).sources {
+			out = append(out, src.name())
+		}
+		return out
+	}
+	cfg := Config{}
+
+	journalctl(`{"MESSAGE":"hello"}\n`)
+	if got := names(cfg); strings.Join(got, ",") != "journald,file" {
+		t.Errorf("expected journald without syslog_file, got %v", got)
+	}
+
+	journalctl("")
+	if got := names(cfg); strings.Join(got, ",") != "syslog_file,file" {
+		t.Errorf("expected syslog_file for an empty journal, got %v", got)
+	}
+
+	opts, _ := newLogSourceOptions(map[string]LogSourceConfig{"journald": {Disabled: true}})
+	journalctl(`{"MESSAGE":"hello"}\n`)
+	if got := names(Config{LogSources: opts}); strings.Join(got, ",") != "syslog_file,file" {
+		t.Errorf("expected syslog_file when journald is disabled, got %v", got)
+	}
+}
+
+func TestLogSources_ReplaysOutageBeforeNewLines(t *testing.T) {
+	defer func(d time.Duration) { logRetryBackoff = d }(logRetryBackoff)
+	logRetryBackoff = 0
+
+	var received []string
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		var p LogIngestPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		for _, e := range p.Entries {
+			received = append(received, e.Message)
+		}
+	}))
+	defer server.Close()
+
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "first\n")
+	src, _ := newMonitoredFileSource(Config{}, nil)
+	files := src.(*monitoredFileSource)
+	files.paths, files.fetched = []string{path}, true
+	r := &logSources{sources: []activeLogSource{{logSource: files}}}
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second, LogBackfillAge: time.Hour}
+	logger := log.New(io.Discard, "", 0)
+
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	status = http.StatusServiceUnavailable
+	appendFile(t, path, "during outage\n")
+	r.poll(server.Client(), cfg, logger, time.Minute)
+	status = http.StatusOK
+	appendFile(t, path, "after recovery\n")
+	r.poll(server.Client(), cfg, logger, time.Minute)
+
+	if strings.Join(received, ",") != "first,during outage,after recovery" {
+		t.Errorf("expected lines delivered in order once, got %q", received)
+	}
+	if drops := logDrops.take(); drops != nil {
+		t.Errorf("expected replayed lines not counted as dropped, got %v", drops)
+	}
+}
+
+func TestLogSources_PollStopsAfterFailedSource(t *testing.T) {
+	defer func(d time.Duration) { logRetryBackoff = d }(logRetryBackoff)
+	logRetryBackoff = 0
+
+	calls := 0
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		calls++
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	defer server.Close()
+
+	first := &fakeLogSource{entries: []LogEntry{{Source: "fake", Message: "one"}}}
+	second := &fakeLogSource{entries: []LogEntry{{Source: "fake", Message: "two"}}}
+	r := &logSources{sources: []activeLogSource{{logSource: first}, {logSource: second}}}
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second}
+	r.poll(server.Client(), cfg, log.New(io.Discard, "", 0), time.Minute)
+
+	if calls != maxLogSendAttempts {
+		t.Errorf("expected only the first source to be retried, got %d requests", calls)
+	}
+	if len(second.results) != 0 || len(second.entries) != 1 {
+		t.Errorf("expected the second source left for the next poll, got %v", second.results)
+	}
+	logDrops.take()
+}
@@ -16,8 +16,8 @@ import (
 	"time"
 
 	"github.com/kardianos/service"
-	"github.com/shirou/gopsutil/v3/cpu"
 	"github.com/shirou/gopsutil/v3/disk"
+	"github.com/shirou/gopsutil/v3/host"
 	"github.com/shirou/gopsutil/v3/mem"
 	gnet "github.com/shirou/gopsutil/v3/net"
 )
@@ -27,10 +27,41 @@ import (
 var Version = "dev"
 
 type Config struct {
-	BaseURL  string
-	Token    string
-	Interval time.Duration
-	Timeout  time.Duration
+	BaseURL      string
+	Token        string
+	Interval     time.Duration
+	Timeout      time.Duration
+	LogRulesFile string
+	Pipeline     *logPipeline
+	LogScanner   *logScanner // log file discovery, from the rules file discovery section
+	LogSources   map[string]*logSourceOptions
+	SyslogUDP    string // listen address for the syslog receiver, e.g. ":5514"
+	SyslogTCP    string
+	SyslogUnix   string // unix datagram socket path, e.g. "/run/omnipulse/syslog.sock"
+	// SyslogUnixMode is the permission of the unix socket; 0 means 0660.
+	SyslogUnixMode os.FileMode
+	LogUnits       []string
+	// ContainerLogs enables tailing Docker json-file and CRI container logs.
+	ContainerLogs bool
+	// Security extracts SSH/sudo/su events; nil unless --security-events is set.
+	Security *securityMonitor
+	// Kernel extracts OOM kills, segfaults, hung tasks and hardware errors; nil unless --kernel-events is set.
+	Kernel *kernelMonitor
+	// Audit tails auditd's log; nil unless --audit-events is set.
+	Audit *auditMonitor
+
+	// LogBackfillAge enables replaying rotated (and compressed) siblings of
+	// monitored files after an ingest outage or when a path is newly enabled.
+	LogBackfillAge time.Duration
+
+	// DiskInclude limits disk I/O metrics to matching devices (globs, partitions
+	// allowed); DiskExclude is added to the default loop*, ram*, zram*.
+	DiskInclude []string
+	DiskExclude []string
+
+	// StateDir keeps state across agent restarts, such as the last boot ID
+	// used for reboot detection. Empty disables it.
+	StateDir string
 }
 
 type MetricPayload struct {
@@ -40,21 +71,54 @@ type MetricPayload struct {
 	Disk      float64 `json:"disk"`
 	NetIn     int64   `json:"net_in"`
 	NetOut    int64   `json:"net_out"`
+	Uptime    uint64  `json:"uptime_seconds,omitempty"`
+
+	// CPU time breakdown since the previous sample, in total and per core
+	CPUTimes *CPUTimesMetric   `json:"cpu_times,omitempty"`
+	CPUCores []CPUTimesMetric  `json:"cpu_cores,omitempty"`
+	Load     *LoadMetric       `json:"load,omitempty"`
+	Pressure *PressureMetrics  `json:"pressure,omitempty"`
+	Memory   *MemoryMetric     `json:"memory,omitempty"`
+	Kernel   *KernelStatMetric `json:"kernel,omitempty"`
+
+	Filesystems []FilesystemMetric `json:"filesystems,omitempty"`
+	NetStat     *NetStatMetric     `json:"netstat,omitempty"`
+	Sensors     []SensorMetric     `json:"sensors,omitempty"`
+
+	LogMetrics []LogMetricSample `json:"log_metrics,omitempty"`
 }
 
 type NetIfaceMetric struct {
-	Iface      string `json:"iface"`
-	BytesIn    int64  `json:"bytes_in"`
-	BytesOut   int64  `json:"bytes_out"`
-	PacketsIn  int64  `json:"packets_in"`
-	PacketsOut int64  `json:"packets_out"`
-	ErrorsIn   int64  `json:"errors_in"`
-	ErrorsOut  int64  `json:"errors_out"`
+	Iface         string `json:"iface"`
+	BytesIn       int64  `json:"bytes_in"`
+	BytesOut      int64  `json:"bytes_out"`
+	PacketsIn     int64  `json:"packets_in"`
+	PacketsOut    int64  `json:"packets_out"`
+	ErrorsIn      int64  `json:"errors_in"`
+	ErrorsOut     int64  `json:"errors_out"`
+	DropsIn       int64  `json:"drops_in"`
+	DropsOut      int64  `json:"drops_out"`
+	FifoErrorsIn  int64  `json:"fifo_errors_in"`
+	FifoErrorsOut int64  `json:"fifo_errors_out"`
+	MulticastIn   int64  `json:"multicast_in"`
+	Collisions    int64  `json:"collisions"`
+
+	// Link state from /sys/class/net; Linux only
+	Kind           string   `json:"kind,omitempty"` // bond, bridge, vlan, ... empty for plain NICs
+	OperState      string   `json:"oper_state,omitempty"`
+	Carrier        *bool    `json:"carrier,omitempty"`
+	SpeedMbps      int64    `json:"speed_mbps,omitempty"` // missing for virtual and down links
+	Duplex         string   `json:"duplex,omitempty"`
+	UtilInPercent  *float64 `json:"util_in_percent,omitempty"` // share of link speed
+	UtilOutPercent *float64 `json:"util_out_percent,omitempty"`
+	Master         string   `json:"master,omitempty"` // bond or bridge this interface belongs to
+	Lower          []string `json:"lower,omitempty"`  // VLAN parent, bond or bridge members
 }
 
 type NetIfacePayload struct {
 	Timestamp  string           `json:"timestamp"`
 	Interfaces []NetIfaceMetric `json:"interfaces"`
+	LinkEvents []LinkEvent      `json:"link_events,omitempty"`
 }
 
 type NetTotals struct {
@@ -169,6 +233,50 @@ func runTestCommand(args []string, logger *log.Logger) {
 		fmt.Println("✅")
 	}
 	fmt.Printf("   CPU: %.1f%% | Memory: %.1f%% | Disk: %.1f%%\n", payload.CPU, payload.Mem, payload.Disk)
+	if payload.Uptime > 0 {
+		fmt.Printf("   Uptime: %s\n", time.Duration(payload.Uptime)*time.Second)
+	}
+	if t := payload.CPUTimes; t != nil {
+		fmt.Printf("   CPU time: user %.1f%% | system %.1f%% | iowait %.1f%% | steal %.1f%% | %d cores\n",
+			t.User, t.System, t.Iowait, t.Steal, len(payload.CPUCores))
+	}
+	if l := payload.Load; l != nil {
+		fmt.Printf("   Load: %.2f %.2f %.2f", l.Load1, l.Load5, l.Load15)
+		if p := payload.Pressure; p != nil && p.CPU != nil {
+			fmt.Printf(" | CPU pressure (some avg10): %.2f%%", p.CPU.Some.Avg10)
+		}
+		fmt.Println()
+	}
+	for _, fs := range payload.Filesystems {
+		fmt.Printf("   %s: %.1f%% used | inodes %.1f%% used", fs.Mount, fs.UsedPercent, fs.InodesUsedPercent)
+		if fs.RemountedReadOnly {
+			fmt.Print(" | remounted read-only")
+		} else if fs.ReadOnly {
+			fmt.Print(" | read-only")
+		}
+		fmt.Println()
+	}
+	if n := payload.NetStat; n != nil {
+		fmt.Printf("   TCP: %d established | %d time_wait | %d close_wait | UDP sockets: %d",
+			n.TCPStates["ESTABLISHED"], n.TCPStates["TIME_WAIT"], n.TCPStates["CLOSE_WAIT"], n.UDPSockets)
+		if c := n.Conntrack; c != nil {
+			fmt.Printf(" | conntrack %d/%d", c.Count, c.Max)
+		}
+		fmt.Println()
+	}
+	var hottest *SensorMetric
+	for i, s := range payload.Sensors {
+		if s.Type == "temperature" && (hottest == nil || s.Value > hottest.Value) {
+			hottest = &payload.Sensors[i]
+		}
+	}
+	if hottest != nil {
+		fmt.Printf("   Sensors: %d | hottest %s %s: %.1f°C", len(payload.Sensors), hottest.Chip, firstNonEmpty(hottest.Label, hottest.Sensor), hottest.Value)
+		if hottest.Critical != nil {
+			fmt.Printf(" (critical %.1f°C)", *hottest.Critical)
+		}
+		fmt.Println()
+	}
 
 	fmt.Print("2. Sending metrics to backend... ")
 	if err := sendMetrics(client, cfg, payload); err != nil {
@@ -229,6 +337,49 @@ func runTestCommand(args []string, logger *log.Logger) {
 		fmt.Println("✅ Success!")
 	}
 
+	// Test 5: Read every log source once and report its health
+	fmt.Print("9. Checking log sources... ")
+	journal := false
+	for _, f := range logSourceFactories {
+		if f.name == "syslog_receiver" {
+			continue // would bind the listeners of a running agent
+		}
+		if f.name == "syslog_file" && journal {
+			continue
+		}
+		src, err := buildLogSource(f.name, f.factory, cfg, logger)
+		if err != nil || src == nil {
+			continue
+		}
+		if j, ok := src.logSource.(*journaldSource); ok {
+			if journal = j.inUse(); !journal {
+				continue
+			}
+		}
+		if rf, ok := src.logSource.(logSourceRefresher); ok {
+			rf.refresh(client, cfg, logger)
+		}
+		src.collect(time.Minute)
+	}
+	health := logHealth.snapshot()
+	unreadable := 0
+	for _, s := range health {
+		if !s.Readable {
+			unreadable++
+		}
+	}
+	fmt.Printf("✅ %d sources (%d unreadable)\n", len(health), unreadable)
+	for _, s := range health {
+		fmt.Printf("   %s\n", formatLogHealth(s))
+	}
+
+	fmt.Print("10. Sending log health to backend... ")
+	if err := sendLogHealth(client, cfg, LogHealthPayload{Timestamp: time.Now().UTC().Format(time.RFC3339), Sources: health}); err != nil {
+		fmt.Printf("❌ Failed: %v\n", err)
+	} else {
+		fmt.Println("✅ Success!")
+	}
+
 	fmt.Println()
 	fmt.Println("===================================")
 	fmt.Println("✅ Connection test completed!")
@@ -306,6 +457,48 @@ func buildRunArgs(cfg Config) []string {
 	if cfg.Interval > 0 {
 		args = append(args, "--interval", strconv.Itoa(int(cfg.Interval.Seconds())))
 	}
+	if cfg.LogRulesFile != "" {
+		args = append(args, "--log-rules", cfg.LogRulesFile)
+	}
+	if cfg.SyslogUDP != "" {
+		args = append(args, "--syslog-udp", cfg.SyslogUDP)
+	}
+	if cfg.SyslogTCP != "" {
+		args = append(args, "--syslog-tcp", cfg.SyslogTCP)
+	}
+	if cfg.SyslogUnix != "" {
+		args = append(args, "--syslog-unix", cfg.SyslogUnix)
+	}
+	if cfg.SyslogUnixMode != 0 {
+		args = append(args, "--syslog-unix-mode", fmt.Sprintf("%o", cfg.SyslogUnixMode))
+	}
+	if len(cfg.LogUnits) > 0 {
+		args = append(args, "--unit", strings.Join(cfg.LogUnits, ","))
+	}
+	if cfg.LogBackfillAge > 0 {
+		args = append(args, "--log-backfill-age", cfg.LogBackfillAge.String())
+	}
+	if cfg.ContainerLogs {
+		args = append(args, "--container-logs")
+	}
+	if cfg.Security != nil {
+		args = append(args, "--security-events")
+	}
+	if cfg.Kernel != nil {
+		args = append(args, "--kernel-events")
+	}
+	if cfg.Audit != nil {
+		args = append(args, "--audit-events")
+	}
+	if len(cfg.DiskInclude) > 0 {
+		args = append(args, "--disk-include", strings.Join(cfg.DiskInclude, ","))
+	}
+	if len(cfg.DiskExclude) > 0 {
+		args = append(args, "--disk-exclude", strings.Join(cfg.DiskExclude, ","))
+	}
+	if cfg.StateDir != "" && cfg.StateDir != defaultStateDir() {
+		args = append(args, "--state-dir", cfg.StateDir)
+	}
 	return args
 }
 
@@ -315,28 +508,36 @@ func runAgent(cfg Config, logger *log.Logger, stopCh <-chan struct{}) {
 	client := &http.Client{Timeout: cfg.Timeout}
 	prevNet := NetTotals{}
 	hasPrev := false
-	prevIfaces := map[string]gnet.IOCountersStat{}
+	prevIfaces := ifaceSample{}
 	hasPrevIfaces := false
+	prevDisks := diskStats{}
+	hasPrevDisks := false
 	failCount := 0
 
+	logSources := startLogSources(cfg, logger)
+	defer logSources.close()
+
+	// Backend-requested live tail sessions stream on their own connections
+	liveTail := startLiveTail(cfg, logger)
+	defer liveTail.close()
+
 	// Send facts on startup
 	sendFactsToBackend(client, cfg, logger)
+	sendRebootEventToBackend(client, cfg, logger)
 	sendServicesToBackend(client, cfg, logger)
 	sendProcessesToBackend(client, cfg, logger)
 	sendWatchdogToBackend(client, cfg, logger)
-	sendLogsToBackend(client, cfg, logger, 5*time.Minute) // initial: look back 5 min
+	logSources.refresh(client, cfg, logger)
+	logSources.poll(client, cfg, logger, 5*time.Minute) // initial: look back 5 min
 	sendInventoryToBackend(client, cfg, logger)
 	sendLogDiscoveryToBackend(client, cfg, logger)
 
-	// Fetch initial monitored log paths
-	monitoredLogPaths, _ := fetchMonitoredLogPaths(client, cfg)
-
 	// Track last facts sent time for periodic refresh
 	lastFactsSent := time.Now()
 	lastLogsSent := time.Now()
 	lastInventorySent := time.Now()
 	factsInterval := 5 * time.Minute
-	logsInterval := 30 * time.Second // logs sent more frequently for live tail
+	logsInterval := 30 * time.Second
 	inventoryInterval := 1 * time.Hour
 
 	for {
@@ -355,9 +556,12 @@ func runAgent(cfg Config, logger *log.Logger, stopCh <-chan struct{}) {
 			logger.Printf("collect warning: %v", warn)
 		}
 
+		payload.LogMetrics = cfg.Pipeline.takeLogMetrics()
+
 		if err := sendMetrics(client, cfg, payload); err != nil {
 			failCount++
 			logger.Printf("ingest failed: %v", err)
+			cfg.Pipeline.restoreLogMetrics(payload.LogMetrics)
 		} else {
 			failCount = 0
 			if netOK {
@@ -366,40 +570,73 @@ func runAgent(cfg Config, logger *log.Logger, stopCh <-chan struct{}) {
 			}
 		}
 
-		ifaceMetrics, nextIfaces, ifaceOK, ifaceWarn := collectIfaceMetrics(prevIfaces, hasPrevIfaces)
+		ifaceMetrics, linkEvents, nextIfaces, ifaceOK, ifaceWarn := collectIfaceMetrics(prevIfaces, hasPrevIfaces)
 		if ifaceWarn != nil {
 			logger.Printf("collect iface warning: %v", ifaceWarn)
 		}
-		if ifaceOK && len(ifaceMetrics) > 0 {
-			if err := sendNetworkMetrics(client, cfg, payload.Timestamp, ifaceMetrics); err != nil {
+		for _, ev := range linkEvents {
+			logger.Printf("link %s %s (operstate %s)", ev.Iface, ev.State, ev.OperState)
+		}
+		if (ifaceOK && len(ifaceMetrics) > 0) || len(linkEvents) > 0 {
+			if err := sendNetworkMetrics(client, cfg, payload.Timestamp, ifaceMetrics, linkEvents); err != nil {
 				logger.Printf("network ingest failed: %v", err)
 			}
 		}
-		if len(nextIfaces) > 0 {
+		if len(nextIfaces.counters) > 0 {
 			prevIfaces = nextIfaces
 			hasPrevIfaces = true
 		}
 
+		diskMetrics, nextDisks, diskOK, diskWarn := collectDiskIOMetrics(cfg, prevDisks, hasPrevDisks)
+		if diskWarn != nil {
+			logger.Printf("collect disk io warning: %v", diskWarn)
+		}
+		if diskOK {
+			interval := nextDisks.at.Sub(prevDisks.at)
+			if err := sendDiskIOMetrics(client, cfg, payload.Timestamp, interval, diskMetrics); err != nil {
+				logger.Printf("disk io ingest failed: %v", err)
+			}
+		}
+		if len(nextDisks.devices) > 0 {
+			prevDisks = nextDisks
+			hasPrevDisks = true
+		}
+
 		// Periodically refresh facts (every 5 minutes) — excludes logs
 		if time.Since(lastFactsSent) >= factsInterval {
 			sendFactsToBackend(client, cfg, logger)
+			sendRebootEventToBackend(client, cfg, logger) // retries an undelivered reboot event
 			sendServicesToBackend(client, cfg, logger)
 			sendProcessesToBackend(client, cfg, logger)
+			if cfg.Kernel != nil {
+				// Pick up fresh OOM kills so the watchdog can attribute crashes to them
+				sendKernelEventsToBackend(client, cfg, logger, logsInterval)
+			}
 			sendWatchdogToBackend(client, cfg, logger)
 			sendLogDiscoveryToBackend(client, cfg, logger)
-			// Refresh monitored paths from backend
-			if paths, err := fetchMonitoredLogPaths(client, cfg); err == nil {
-				monitoredLogPaths = paths
+			sendLogHealthToBackend(client, cfg, logger)
+			if totals := cfg.Pipeline.redactionTotals(); len(totals) > 0 {
+				logger.Printf("log redactions since start: %s", formatCounts(totals))
+			}
+			if dropped := cfg.Pipeline.droppedTotals(); len(dropped) > 0 {
+				logger.Printf("log entries filtered since start: %s", formatCounts(dropped))
 			}
+			// Refresh monitored paths and other backend-provided source settings
+			logSources.refresh(client, cfg, logger)
 			lastFactsSent = time.Now()
 		}
 
-		// Send logs more frequently (every 30 seconds) for live tail support
+		// Send logs more frequently (every 30 seconds); live tail sessions stream separately
 		if time.Since(lastLogsSent) >= logsInterval {
-			sendLogsToBackend(client, cfg, logger, 1*time.Minute) // 1 min lookback with overlap
-			// Also tail monitored log files
-			if len(monitoredLogPaths) > 0 {
-				sendFileLogsToBackend(client, cfg, logger, monitoredLogPaths, 1*time.Minute)
+			logSources.poll(client, cfg, logger, 1*time.Minute) // lookback only applies before the first cursor
+			if cfg.Security != nil {
+				sendSecurityEventsToBackend(client, cfg, logger, logsInterval)
+			}
+			if cfg.Kernel != nil {
+				sendKernelEventsToBackend(client, cfg, logger, logsInterval)
+			}
+			if cfg.Audit != nil {
+				sendAuditEventsToBackend(client, cfg, logger)
 			}
 			lastLogsSent = time.Now()
 		}
@@ -462,13 +699,16 @@ func sendServicesToBackend(client *http.Client, cfg Config, logger *log.Logger)
 	}
 }
 
-// sendLogDiscoveryToBackend scans and sends discovered .log files to the backend
+// sendLogDiscoveryToBackend scans and sends discovered log files to the backend
 func sendLogDiscoveryToBackend(client *http.Client, cfg Config, logger *log.Logger) {
-	logFiles := collectLogFiles()
-	if err := sendLogDiscovery(client, cfg, logFiles); err != nil {
+	logFiles, truncated := collectLogFiles(cfg)
+	if truncated != "" {
+		logger.Printf("log discovery stopped early: %s reached", truncated)
+	}
+	if err := sendLogDiscovery(client, cfg, logFiles, truncated); err != nil {
 		logger.Printf("log discovery ingest failed: %v", err)
 	} else {
-		logger.Printf("log discovery sent: %d .log files found", len(logFiles))
+		logger.Printf("log discovery sent: %d log files found", len(logFiles))
 	}
 }
 
@@ -514,6 +754,22 @@ func loadConfig(args []string) (Config, error) {
 	flagURL := fs.String("url", "", "Base URL (env OMNIPULSE_URL)")
 	flagToken := fs.String("token", "", "Agent token (env AGENT_TOKEN)")
 	flagInterval := fs.Int("interval", 0, "Interval in seconds (env INTERVAL_SECONDS)")
+	flagLogRules := fs.String("log-rules", "", "Path to JSON log rules file (env LOG_RULES_FILE)")
+	flagSyslogUDP := fs.String("syslog-udp", "", "Syslog UDP listen address (env SYSLOG_UDP_ADDR)")
+	flagSyslogTCP := fs.String("syslog-tcp", "", "Syslog TCP listen address (env SYSLOG_TCP_ADDR)")
+	flagSyslogUnix := fs.String("syslog-unix", "", "Syslog unix datagram socket path (env SYSLOG_UNIX_SOCKET)")
+	flagSyslogUnixMode := fs.String("syslog-unix-mode", "", "Octal permission of the syslog unix socket, default 660 (env SYSLOG_UNIX_MODE)")
+	flagBackfill := fs.String("log-backfill-age", "", "Backfill monitored logs from rotated files up to this age, e.g. 6h (env LOG_BACKFILL_AGE)")
+	flagContainerLogs := fs.Bool("container-logs", false, "Collect Docker and CRI container logs (env CONTAINER_LOGS)")
+	flagSecurity := fs.Bool("security-events", false, "Extract SSH/sudo/su security events from auth logs (env SECURITY_EVENTS)")
+	flagKernel := fs.Bool("kernel-events", false, "Extract OOM kills, segfaults, hung tasks and hardware errors from kernel messages (env KERNEL_EVENTS)")
+	flagAudit := fs.Bool("audit-events", false, "Parse auditd events from /var/log/audit/audit.log (env AUDIT_EVENTS)")
+	flagStateDir := fs.String("state-dir", "", "Directory for agent state such as the last boot ID (env STATE_DIR, default "+defaultStateDir()+")")
+	var flagUnits stringList
+	fs.Var(&flagUnits, "unit", "Only collect journald logs from these systemd units, repeatable or comma-separated (env LOG_UNITS)")
+	var flagDiskInclude, flagDiskExclude stringList
+	fs.Var(&flagDiskInclude, "disk-include", "Only collect disk I/O for these devices, globs, repeatable or comma-separated (env DISK_INCLUDE)")
+	fs.Var(&flagDiskExclude, "disk-exclude", "Skip disk I/O for these devices in addition to loop*, ram*, zram* (env DISK_EXCLUDE)")
 	if err := fs.Parse(args); err != nil {
 		return Config{}, err
 	}
@@ -538,20 +794,159 @@ func loadConfig(args []string) (Config, error) {
 		intervalSeconds = parsed
 	}
 
+	units := []string(flagUnits)
+	if len(units) == 0 {
+		units = splitList(os.Getenv("LOG_UNITS"))
+	}
+
+	diskInclude := []string(flagDiskInclude)
+	if len(diskInclude) == 0 {
+		diskInclude = splitList(os.Getenv("DISK_INCLUDE"))
+	}
+	if err := validateGlobs(diskInclude); err != nil {
+		return Config{}, fmt.Errorf("invalid DISK_INCLUDE: %w", err)
+	}
+	diskExclude := []string(flagDiskExclude)
+	if len(diskExclude) == 0 {
+		diskExclude = splitList(os.Getenv("DISK_EXCLUDE"))
+	}
+	if err := validateGlobs(diskExclude); err != nil {
+		return Config{}, fmt.Errorf("invalid DISK_EXCLUDE: %w", err)
+	}
+
+	containerLogs := *flagContainerLogs
+	if raw := strings.TrimSpace(os.Getenv("CONTAINER_LOGS")); raw != "" && !containerLogs {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid CONTAINER_LOGS: %q", raw)
+		}
+		containerLogs = parsed
+	}
+
+	securityEvents := *flagSecurity
+	if raw := strings.TrimSpace(os.Getenv("SECURITY_EVENTS")); raw != "" && !securityEvents {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid SECURITY_EVENTS: %q", raw)
+		}
+		securityEvents = parsed
+	}
+
+	kernelEvents := *flagKernel
+	if raw := strings.TrimSpace(os.Getenv("KERNEL_EVENTS")); raw != "" && !kernelEvents {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid KERNEL_EVENTS: %q", raw)
+		}
+		kernelEvents = parsed
+	}
+	var kernel *kernelMonitor
+	if kernelEvents {
+		kernel = newKernelMonitor()
+	}
+
+	auditEvents := *flagAudit
+	if raw := strings.TrimSpace(os.Getenv("AUDIT_EVENTS")); raw != "" && !auditEvents {
+		parsed, err := strconv.ParseBool(raw)
+		if err != nil {
+			return Config{}, fmt.Errorf("invalid AUDIT_EVENTS: %q", raw)
+		}
+		auditEvents = parsed
+	}
+
+	var syslogUnixMode os.FileMode
+	if raw := strings.TrimSpace(firstNonEmpty(*flagSyslogUnixMode, os.Getenv("SYSLOG_UNIX_MODE"))); raw != "" {
+		parsed, err := strconv.ParseUint(raw, 8, 32)
+		if err != nil || parsed == 0 || parsed > 0o777 {
+			return Config{}, fmt.Errorf("invalid SYSLOG_UNIX_MODE: %q", raw)
+		}
+		syslogUnixMode = os.FileMode(parsed)
+	}
+
+	var backfillAge time.Duration
+	if raw := strings.TrimSpace(firstNonEmpty(*flagBackfill, os.Getenv("LOG_BACKFILL_AGE"))); raw != "" {
+		parsed, err := time.ParseDuration(raw)
+		if err != nil || parsed < 0 {
+			return Config{}, fmt.Errorf("invalid LOG_BACKFILL_AGE: %q", raw)
+		}
+		backfillAge = parsed
+	}
+
+	logRulesFile := strings.TrimSpace(firstNonEmpty(*flagLogRules, os.Getenv("LOG_RULES_FILE")))
+	rules, err := loadLogRules(logRulesFile)
+	if err != nil {
+		return Config{}, err
+	}
+	pipeline, err := newLogPipeline(rules)
+	if err != nil {
+		return Config{}, fmt.Errorf("log rules: %w", err)
+	}
+	scanner, err := newLogScanner(rules.Discovery)
+	if err != nil {
+		return Config{}, fmt.Errorf("log rules: %w", err)
+	}
+	sourceOpts, err := newLogSourceOptions(rules.Sources)
+	if err != nil {
+		return Config{}, fmt.Errorf("log rules: %w", err)
+	}
+	var security *securityMonitor
+	if securityEvents {
+		if security, err = newSecurityMonitor(rules.Security); err != nil {
+			return Config{}, fmt.Errorf("log rules: %w", err)
+		}
+		security.redact = pipeline.redactFields
+	}
+	var audit *auditMonitor
+	if auditEvents {
+		audit = newAuditMonitor(rules.Audit)
+		audit.redact = pipeline.redactFields
+	}
+	if kernel != nil {
+		kernel.redact = pipeline.redactFields
+	}
+
 	return Config{
-		BaseURL:  strings.TrimRight(baseURL, "/"),
-		Token:    token,
-		Interval: time.Duration(intervalSeconds) * time.Second,
-		Timeout:  10 * time.Second,
+		BaseURL:      strings.TrimRight(baseURL, "/"),
+		Token:        token,
+		Interval:     time.Duration(intervalSeconds) * time.Second,
+		Timeout:      10 * time.Second,
+		LogRulesFile: logRulesFile,
+		Pipeline:     pipeline,
+		LogScanner:   scanner,
+		LogSources:   sourceOpts,
+		SyslogUDP:    strings.TrimSpace(firstNonEmpty(*flagSyslogUDP, os.Getenv("SYSLOG_UDP_ADDR"))),
+		SyslogTCP:    strings.TrimSpace(firstNonEmpty(*flagSyslogTCP, os.Getenv("SYSLOG_TCP_ADDR"))),
+		SyslogUnix:   strings.TrimSpace(firstNonEmpty(*flagSyslogUnix, os.Getenv("SYSLOG_UNIX_SOCKET"))),
+		LogUnits:     units,
+
+		SyslogUnixMode: syslogUnixMode,
+		LogBackfillAge: backfillAge,
+		ContainerLogs:  containerLogs,
+		Security:       security,
+		Kernel:         kernel,
+		Audit:          audit,
+		DiskInclude:    diskInclude,
+		DiskExclude:    diskExclude,
+		StateDir:       strings.TrimSpace(firstNonEmpty(*flagStateDir, os.Getenv("STATE_DIR"), defaultStateDir())),
 	}, nil
 }
 
 func collectMetrics(prev NetTotals, hasPrev bool) (MetricPayload, NetTotals, bool, error) {
 	var warnings []string
 
-	cpuPct, err := readCPU()
+	cpuTotal, cpuCores, cpuErr := cpuTimes.read()
+	if cpuErr != nil {
+		warnings = append(warnings, "cpu:"+cpuErr.Error())
+	}
+
+	loadAvg, err := readLoad()
+	if err != nil {
+		warnings = append(warnings, "load:"+err.Error())
+	}
+
+	pressure, err := readPressure()
 	if err != nil {
-		warnings = append(warnings, "cpu:"+err.Error())
+		warnings = append(warnings, "pressure:"+err.Error())
 	}
 
 	memPct, err := readMem()
@@ -559,11 +954,36 @@ func collectMetrics(prev NetTotals, hasPrev bool) (MetricPayload, NetTotals, boo
 		warnings = append(warnings, "mem:"+err.Error())
 	}
 
+	memory, err := readMemoryDetails()
+	if err != nil {
+		warnings = append(warnings, "meminfo:"+err.Error())
+	}
+
+	kernelStat, err := readKernelStat()
+	if err != nil {
+		warnings = append(warnings, "kernel:"+err.Error())
+	}
+
 	diskPct, err := readDisk()
 	if err != nil {
 		warnings = append(warnings, "disk:"+err.Error())
 	}
 
+	mounts, err := filesystems.read()
+	if err != nil {
+		warnings = append(warnings, "filesystems:"+err.Error())
+	}
+
+	netstat, err := readNetStat()
+	if err != nil {
+		warnings = append(warnings, "netstat:"+err.Error())
+	}
+
+	uptime, err := host.Uptime()
+	if err != nil {
+		warnings = append(warnings, "uptime:"+err.Error())
+	}
+
 	netTotals, netOK, netErr := readNetTotals()
 	if netErr != nil {
 		warnings = append(warnings, "net:"+netErr.Error())
@@ -577,12 +997,19 @@ func collectMetrics(prev NetTotals, hasPrev bool) (MetricPayload, NetTotals, boo
 
 	payload := MetricPayload{
 		Timestamp: time.Now().UTC().Format(time.RFC3339Nano),
-		CPU:       cpuPct,
 		Mem:       memPct,
 		Disk:      diskPct,
 		NetIn:     netIn,
 		NetOut:    netOut,
+		Uptime:    uptime,
+	}
+	if cpuErr == nil {
+		payload.CPU = cpuTotal.busy()
+		payload.CPUTimes, payload.CPUCores = &cpuTotal, cpuCores
 	}
+	payload.Load, payload.Pressure, payload.Memory, payload.Kernel = loadAvg, pressure, memory, kernelStat
+	payload.Filesystems, payload.NetStat = mounts, netstat
+	payload.Sensors = readSensors()
 
 	if len(warnings) > 0 {
 		return payload, netTotals, netOK, errors.New(strings.Join(warnings, "; "))
@@ -625,14 +1052,15 @@ func sendMetrics(client *http.Client, cfg Config, payload MetricPayload) error {
 	return nil
 }
 
-func sendNetworkMetrics(client *http.Client, cfg Config, timestamp string, ifaces []NetIfaceMetric) error {
-	if len(ifaces) == 0 {
+func sendNetworkMetrics(client *http.Client, cfg Config, timestamp string, ifaces []NetIfaceMetric, events []LinkEvent) error {
+	if len(ifaces) == 0 && len(events) == 0 {
 		return nil
 	}
 
 	payload := NetIfacePayload{
 		Timestamp:  timestamp,
 		Interfaces: ifaces,
+		LinkEvents: events,
 	}
 	body, err := json.Marshal(payload)
 	if err != nil {
@@ -668,17 +1096,6 @@ func sendNetworkMetrics(client *http.Client, cfg Config, timestamp string, iface
 	return nil
 }
 
-func readCPU() (float64, error) {
-	values, err := cpu.Percent(0, false)
-	if err != nil {
-		return 0, err
-	}
-	if len(values) == 0 {
-		return 0, errors.New("cpu percent empty")
-	}
-	return values[0], nil
-}
-
 func readMem() (float64, error) {
 	stats, err := mem.VirtualMemory()
 	if err != nil {
@@ -711,27 +1128,51 @@ func readNetTotals() (NetTotals, bool, error) {
 	return total, true, nil
 }
 
-func collectIfaceMetrics(prev map[string]gnet.IOCountersStat, hasPrev bool) ([]NetIfaceMetric, map[string]gnet.IOCountersStat, bool, error) {
+// ifaceSample is one reading of the interface counters and, on Linux, their
+// link state.
+type ifaceSample struct {
+	at       time.Time
+	counters map[string]gnet.IOCountersStat
+	links    map[string]ifaceLink
+}
+
+func collectIfaceMetrics(prev ifaceSample, hasPrev bool) ([]NetIfaceMetric, []LinkEvent, ifaceSample, bool, error) {
 	stats, err := gnet.IOCounters(true)
 	if err != nil {
-		return nil, prev, false, err
+		return nil, nil, prev, false, err
 	}
 
-	next := make(map[string]gnet.IOCountersStat, len(stats))
+	next := ifaceSample{
+		at:       time.Now(),
+		counters: make(map[string]gnet.IOCountersStat, len(stats)),
+		links:    make(map[string]ifaceLink, len(stats)),
+	}
 	for _, stat := range stats {
 		if isLoopback(stat.Name) {
 			continue
 		}
-		next[stat.Name] = stat
+		next.counters[stat.Name] = stat
+		if link, ok := readIfaceLink(stat.Name); ok {
+			next.links[stat.Name] = link
+		}
 	}
 
 	if !hasPrev {
-		return nil, next, false, nil
+		return nil, nil, next, false, nil
 	}
 
-	metrics := make([]NetIfaceMetric, 0, len(next))
-	for name, curr := range next {
-		old, ok := prev[name]
+	metrics := ifaceDeltas(prev, next)
+	events := linkEvents(prev.links, next.links, next.at)
+	return metrics, events, next, len(metrics) > 0, nil
+}
+
+// ifaceDeltas converts two samples into per-interface metrics. Interfaces
+// that appeared since prev or whose counters went backwards are skipped.
+func ifaceDeltas(prev, next ifaceSample) []NetIfaceMetric {
+	elapsed := next.at.Sub(prev.at)
+	metrics := make([]NetIfaceMetric, 0, len(next.counters))
+	for name, curr := range next.counters {
+		old, ok := prev.counters[name]
 		if !ok {
 			continue
 		}
@@ -741,21 +1182,45 @@ func collectIfaceMetrics(prev map[string]gnet.IOCountersStat, hasPrev bool) ([]N
 		packetsOut, ok4 := safeDelta(old.PacketsSent, curr.PacketsSent)
 		errorsIn, ok5 := safeDelta(old.Errin, curr.Errin)
 		errorsOut, ok6 := safeDelta(old.Errout, curr.Errout)
-		if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6) {
+		dropsIn, ok7 := safeDelta(old.Dropin, curr.Dropin)
+		dropsOut, ok8 := safeDelta(old.Dropout, curr.Dropout)
+		fifoIn, ok9 := safeDelta(old.Fifoin, curr.Fifoin)
+		fifoOut, ok10 := safeDelta(old.Fifoout, curr.Fifoout)
+		if !(ok1 && ok2 && ok3 && ok4 && ok5 && ok6 && ok7 && ok8 && ok9 && ok10) {
 			continue
 		}
-		metrics = append(metrics, NetIfaceMetric{
-			Iface:      name,
-			BytesIn:    bytesIn,
-			BytesOut:   bytesOut,
-			PacketsIn:  packetsIn,
-			PacketsOut: packetsOut,
-			ErrorsIn:   errorsIn,
-			ErrorsOut:  errorsOut,
-		})
-	}
+		m := NetIfaceMetric{
+			Iface:         name,
+			BytesIn:       bytesIn,
+			BytesOut:      bytesOut,
+			PacketsIn:     packetsIn,
+			PacketsOut:    packetsOut,
+			ErrorsIn:      errorsIn,
+			ErrorsOut:     errorsOut,
+			DropsIn:       dropsIn,
+			DropsOut:      dropsOut,
+			FifoErrorsIn:  fifoIn,
+			FifoErrorsOut: fifoOut,
+		}
 
-	return metrics, next, len(metrics) > 0, nil
+		if link, ok := next.links[name]; ok {
+			if oldLink, ok := prev.links[name]; ok {
+				m.MulticastIn, _ = safeDelta(oldLink.multicast, link.multicast)
+				m.Collisions, _ = safeDelta(oldLink.collisions, link.collisions)
+			}
+			m.Kind = link.kind
+			m.OperState = link.operState
+			m.Carrier = link.carrier
+			m.SpeedMbps = link.speedMbps
+			m.Duplex = link.duplex
+			m.Master = link.master
+			m.Lower = link.lower
+			m.UtilInPercent = linkUtilization(bytesIn, elapsed, link.speedMbps)
+			m.UtilOutPercent = linkUtilization(bytesOut, elapsed, link.speedMbps)
+		}
+		metrics = append(metrics, m)
+	}
+	return metrics
 }
 
 func safeDelta(prev, curr uint64) (int64, bool) {
@@ -777,6 +1242,21 @@ func netDelta(prev, curr NetTotals) (int64, int64) {
 	return int64(curr.In - prev.In), int64(curr.Out - prev.Out)
 }
 
+// addedPaths returns the paths in next that are not in prev.
+func addedPaths(prev, next []string) []string {
+	known := make(map[string]bool, len(prev))
+	for _, p := range prev {
+		known[p] = true
+	}
+	var added []string
+	for _, p := range next {
+		if !known[p] {
+			added = append(added, p)
+		}
+	}
+	return added
+}
+
 func nextSleep(interval time.Duration, failCount int) time.Duration {
 	if failCount <= 0 {
 		return interval
@@ -798,6 +1278,29 @@ func minInt(a, b int) int {
 	return b
 }
 
+// stringList is a flag.Value that accepts repeated and/or comma-separated values.
+type stringList []string
+
+func (l *stringList) String() string {
+	return strings.Join(*l, ",")
+}
+
+func (l *stringList) Set(value string) error {
+	*l = append(*l, splitList(value)...)
+	return nil
+}
+
+// splitList splits a comma-separated value, dropping empty items.
+func splitList(raw string) []string {
+	var out []string
+	for _, item := range strings.Split(raw, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			out = append(out, item)
+		}
+	}
+	return out
+}
+
 func firstNonEmpty(values ...string) string {
 	for _, value := range values {
 		if strings.TrimSpace(value) != "" {
@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// MemoryMetric separates page cache from real memory pressure. Values come
+// from /proc/meminfo and are in bytes unless noted; Linux only.
+type MemoryMetric struct {
+	TotalBytes        uint64 `json:"total_bytes"`
+	UsedBytes         uint64 `json:"used_bytes"` // total - free - buffers - cache, as free(1) reports
+	AvailableBytes    uint64 `json:"available_bytes"`
+	FreeBytes         uint64 `json:"free_bytes"`
+	BuffersBytes      uint64 `json:"buffers_bytes"`
+	CachedBytes       uint64 `json:"cached_bytes"`
+	SlabBytes         uint64 `json:"slab_bytes"`
+	SlabReclaimable   uint64 `json:"slab_reclaimable_bytes"`
+	SharedBytes       uint64 `json:"shared_bytes"`
+	DirtyBytes        uint64 `json:"dirty_bytes"`
+	WritebackBytes    uint64 `json:"writeback_bytes"`
+	HugePagesTotal    uint64 `json:"hugepages_total"` // pages
+	HugePagesFree     uint64 `json:"hugepages_free"`  // pages
+	HugePageSizeBytes uint64 `json:"hugepage_size_bytes"`
+
+	SwapTotalBytes uint64 `json:"swap_total_bytes"`
+	SwapUsedBytes  uint64 `json:"swap_used_bytes"`
+	SwapFreeBytes  uint64 `json:"swap_free_bytes"`
+
+	// Rates since the previous sample from /proc/vmstat; missing on the first one
+	SwapInBytesPerSec  *float64 `json:"swap_in_bytes_per_sec,omitempty"`
+	SwapOutBytesPerSec *float64 `json:"swap_out_bytes_per_sec,omitempty"`
+	MajorFaultsPerSec  *float64 `json:"major_faults_per_sec,omitempty"`
+}
+
+// vmstatSampler keeps the previous /proc/vmstat counters for rate computation.
+type vmstatSampler struct {
+	mu   sync.Mutex
+	prev map[string]uint64
+	at   time.Time
+}
+
+var vmstatRates = &vmstatSampler{}
+
+// readMemoryDetails reads /proc/meminfo and the paging rates since the
+// previous call. It returns nil without error where /proc is missing.
+func readMemoryDetails() (*MemoryMetric, error) {
+	info, err := readProcKeyValues("meminfo")
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	// meminfo sizes are in kB; HugePages_* are page counts
+	kb := func(key string) uint64 { return info[key] * 1024 }
+
+	m := &MemoryMetric{
+		TotalBytes:        kb("MemTotal"),
+		AvailableBytes:    kb("MemAvailable"),
+		FreeBytes:         kb("MemFree"),
+		BuffersBytes:      kb("Buffers"),
+		CachedBytes:       kb("Cached"),
+		SlabBytes:         kb("Slab"),
+		SlabReclaimable:   kb("SReclaimable"),
+		SharedBytes:       kb("Shmem"),
+		DirtyBytes:        kb("Dirty"),
+		WritebackBytes:    kb("Writeback"),
+		HugePagesTotal:    info["HugePages_Total"],
+		HugePagesFree:     info["HugePages_Free"],
+		HugePageSizeBytes: kb("Hugepagesize"),
+		SwapTotalBytes:    kb("SwapTotal"),
+		SwapFreeBytes:     kb("SwapFree"),
+	}
+	if cache := m.FreeBytes + m.BuffersBytes + m.CachedBytes + m.SlabReclaimable; m.TotalBytes > cache {
+		m.UsedBytes = m.TotalBytes - cache
+	}
+	if m.SwapTotalBytes > m.SwapFreeBytes {
+		m.SwapUsedBytes = m.SwapTotalBytes - m.SwapFreeBytes
+	}
+
+	if vmstat, err := readProcKeyValues("vmstat"); err == nil {
+		vmstatRates.apply(m, vmstat, time.Now())
+	}
+	return m, nil
+}
+
+// apply sets the paging rates of m from the counter deltas since the
+// previous call and stores the new counters.
+func (s *vmstatSampler) apply(m *MemoryMetric, vmstat map[string]uint64, now time.Time) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev, at := s.prev, s.at
+	s.prev, s.at = vmstat, now
+
+	elapsed := now.Sub(at).Seconds()
+	if prev == nil || elapsed <= 0 {
+		return
+	}
+	rate := func(key string, scale float64) *float64 {
+		cur, ok1 := vmstat[key]
+		old, ok2 := prev[key]
+		if !ok1 || !ok2 || cur < old {
+			return nil
+		}
+		v := float64(cur-old) * scale / elapsed
+		return &v
+	}
+	page := float64(os.Getpagesize())
+	m.SwapInBytesPerSec = rate("pswpin", page)
+	m.SwapOutBytesPerSec = rate("pswpout", page)
+	m.MajorFaultsPerSec = rate("pgmajfault", 1)
+}
+
+// readProcKeyValues parses "Key: value [kB]" or "key value" lines of a file
+// under procRoot into the first numeric value per key.
+func readProcKeyValues(name string) (map[string]uint64, error) {
+	f, err := os.Open(filepath.Join(procRoot, name))
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	values := make(map[string]uint64)
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if v, err := strconv.ParseUint(fields[1], 10, 64); err == nil {
+			values[strings.TrimSuffix(fields[0], ":")] = v
+		}
+	}
+	return values, scanner.Err()
+}
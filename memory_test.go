@@ -0,0 +1,84 @@
+package main
+
+import (
+	"os"
+	"testing"
+	"time"
+)
+
+const testMeminfo = `MemTotal:        1000000 kB
+MemFree:          200000 kB
+MemAvailable:     600000 kB
+Buffers:           50000 kB
+Cached:           300000 kB
+SwapTotal:        400000 kB
+SwapFree:         100000 kB
+Dirty:              1000 kB
+Writeback:             0 kB
+Shmem:             20000 kB
+Slab:              80000 kB
+SReclaimable:      50000 kB
+HugePages_Total:       4
+HugePages_Free:        2
+Hugepagesize:       2048 kB
+`
+
+func TestReadMemoryDetails(t *testing.T) {
+	withProcRoot(t, map[string]string{
+		"meminfo": testMeminfo,
+		"vmstat":  "pswpin 10\npswpout 20\npgmajfault 100\n",
+	})
+	defer func(s *vmstatSampler) { vmstatRates = s }(vmstatRates)
+	vmstatRates = &vmstatSampler{}
+
+	m, err := readMemoryDetails()
+	if err != nil {
+		t.Fatalf("readMemoryDetails: %v", err)
+	}
+	if m.TotalBytes != 1000000*1024 || m.CachedBytes != 300000*1024 || m.SharedBytes != 20000*1024 {
+		t.Errorf("unexpected sizes: %+v", m)
+	}
+	// 1000000 - 200000 - 50000 - 300000 - 50000
+	if m.UsedBytes != 400000*1024 {
+		t.Errorf("UsedBytes = %d", m.UsedBytes/1024)
+	}
+	if m.SwapUsedBytes != 300000*1024 || m.HugePagesTotal != 4 || m.HugePageSizeBytes != 2048*1024 {
+		t.Errorf("unexpected swap/hugepages: %+v", m)
+	}
+	if m.SwapInBytesPerSec != nil || m.MajorFaultsPerSec != nil {
+		t.Error("rates must be missing on the first sample")
+	}
+}
+
+func TestVmstatSamplerRates(t *testing.T) {
+	s := &vmstatSampler{}
+	start := time.Now()
+	var m MemoryMetric
+	s.apply(&m, map[string]uint64{"pswpin": 10, "pswpout": 20, "pgmajfault": 100}, start)
+	s.apply(&m, map[string]uint64{"pswpin": 30, "pswpout": 20, "pgmajfault": 150}, start.Add(10*time.Second))
+
+	page := float64(os.Getpagesize())
+	if m.SwapInBytesPerSec == nil || *m.SwapInBytesPerSec != 2*page {
+		t.Errorf("swap in rate = %v", m.SwapInBytesPerSec)
+	}
+	if m.SwapOutBytesPerSec == nil || *m.SwapOutBytesPerSec != 0 {
+		t.Errorf("swap out rate = %v", m.SwapOutBytesPerSec)
+	}
+	if m.MajorFaultsPerSec == nil || *m.MajorFaultsPerSec != 5 {
+		t.Errorf("major fault rate = %v", m.MajorFaultsPerSec)
+	}
+
+	// A counter reset yields no rate rather than a huge one
+	m = MemoryMetric{}
+	s.apply(&m, map[string]uint64{"pswpin": 0, "pswpout": 20, "pgmajfault": 150}, start.Add(20*time.Second))
+	if m.SwapInBytesPerSec != nil {
+		t.Errorf("expected no rate after reset, got %v", *m.SwapInBytesPerSec)
+	}
+}
+
+func TestReadMemoryDetails_NoProc(t *testing.T) {
+	withProcRoot(t, nil)
+	if m, err := readMemoryDetails(); m != nil || err != nil {
+		t.Errorf("expected nil without /proc, got %+v, %v", m, err)
+	}
+}
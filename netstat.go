@@ -0,0 +1,256 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// tcpStateNames maps the hex st column of /proc/net/tcp to netstat's names.
+var tcpStateNames = map[string]string{
+	"01": "ESTABLISHED",
+	"02": "SYN_SENT",
+	"03": "SYN_RECV",
+	"04": "FIN_WAIT1",
+	"05": "FIN_WAIT2",
+	"06": "TIME_WAIT",
+	"07": "CLOSE",
+	"08": "CLOSE_WAIT",
+	"09": "LAST_ACK",
+	"0A": "LISTEN",
+	"0B": "CLOSING",
+	"0C": "NEW_SYN_RECV",
+}
+
+// NetStatMetric is socket and protocol state of the host's network
+// namespace, IPv4 and IPv6 combined. Linux only.
+type NetStatMetric struct {
+	TCPStates  map[string]int `json:"tcp_states"` // socket count per state, e.g. ESTABLISHED, TIME_WAIT
+	UDPSockets int            `json:"udp_sockets"`
+
+	// Counters since the previous sample; missing on the first one
+	Counters  *NetStatCounters `json:"counters,omitempty"`
+	Conntrack *ConntrackMetric `json:"conntrack,omitempty"`
+}
+
+// NetStatCounters are /proc/net/snmp, snmp6 and netstat counter deltas.
+type NetStatCounters struct {
+	TCPActiveOpens     int64 `json:"tcp_active_opens"`
+	TCPPassiveOpens    int64 `json:"tcp_passive_opens"`
+	TCPAttemptFails    int64 `json:"tcp_attempt_fails"`
+	TCPEstabResets     int64 `json:"tcp_estab_resets"`
+	TCPOutResets       int64 `json:"tcp_out_resets"`
+	TCPInErrors        int64 `json:"tcp_in_errors"`
+	TCPOutSegments     int64 `json:"tcp_out_segments"`
+	TCPRetransSegments int64 `json:"tcp_retrans_segments"`
+	TCPTimeouts        int64 `json:"tcp_timeouts"`
+	TCPListenOverflows int64 `json:"tcp_listen_overflows"` // accept queue full
+	TCPListenDrops     int64 `json:"tcp_listen_drops"`
+	UDPInDatagrams     int64 `json:"udp_in_datagrams"`
+	UDPOutDatagrams    int64 `json:"udp_out_datagrams"`
+	UDPInErrors        int64 `json:"udp_in_errors"`
+	UDPNoPorts         int64 `json:"udp_no_ports"`
+	UDPRcvbufErrors    int64 `json:"udp_rcvbuf_errors"` // dropped on a full receive buffer
+	UDPSndbufErrors    int64 `json:"udp_sndbuf_errors"`
+}
+
+// ConntrackMetric is netfilter connection tracking table usage. New
+// connections are dropped once Count reaches Max.
+type ConntrackMetric struct {
+	Count       uint64  `json:"count"`
+	Max         uint64  `json:"max"`
+	UsedPercent float64 `json:"used_percent"`
+}
+
+// netstatCounterKeys maps each counter to its "Table.Name" source. UDP
+// counters also add the Udp6 values from /proc/net/snmp6.
+var netstatCounterKeys = []struct {
+	key string
+	dst func(*NetStatCounters) *int64
+}{
+	{"Tcp.ActiveOpens", func(c *NetStatCounters) *int64 { return &c.TCPActiveOpens }},
+	{"Tcp.PassiveOpens", func(c *NetStatCounters) *int64 { return &c.TCPPassiveOpens }},
+	{"Tcp.AttemptFails", func(c *NetStatCounters) *int64 { return &c.TCPAttemptFails }},
+	{"Tcp.EstabResets", func(c *NetStatCounters) *int64 { return &c.TCPEstabResets }},
+	{"Tcp.OutRsts", func(c *NetStatCounters) *int64 { return &c.TCPOutResets }},
+	{"Tcp.InErrs", func(c *NetStatCounters) *int64 { return &c.TCPInErrors }},
+	{"Tcp.OutSegs", func(c *NetStatCounters) *int64 { return &c.TCPOutSegments }},
+	{"Tcp.RetransSegs", func(c *NetStatCounters) *int64 { return &c.TCPRetransSegments }},
+	{"TcpExt.TCPTimeouts", func(c *NetStatCounters) *int64 { return &c.TCPTimeouts }},
+	{"TcpExt.ListenOverflows", func(c *NetStatCounters) *int64 { return &c.TCPListenOverflows }},
+	{"TcpExt.ListenDrops", func(c *NetStatCounters) *int64 { return &c.TCPListenDrops }},
+	{"Udp.InDatagrams", func(c *NetStatCounters) *int64 { return &c.UDPInDatagrams }},
+	{"Udp.OutDatagrams", func(c *NetStatCounters) *int64 { return &c.UDPOutDatagrams }},
+	{"Udp.InErrors", func(c *NetStatCounters) *int64 { return &c.UDPInErrors }},
+	{"Udp.NoPorts", func(c *NetStatCounters) *int64 { return &c.UDPNoPorts }},
+	{"Udp.RcvbufErrors", func(c *NetStatCounters) *int64 { return &c.UDPRcvbufErrors }},
+	{"Udp.SndbufErrors", func(c *NetStatCounters) *int64 { return &c.UDPSndbufErrors }},
+}
+
+// netstatSampler keeps the previous protocol counters for delta computation.
+type netstatSampler struct {
+	mu   sync.Mutex
+	prev map[string]uint64
+}
+
+var netstatCounters = &netstatSampler{}
+
+// readNetStat reads socket states, protocol counters and conntrack usage.
+// It returns nil without error where /proc/net is missing.
+func readNetStat() (*NetStatMetric, error) {
+	states := make(map[string]int)
+	found := false
+	for _, name := range []string{"tcp", "tcp6"} {
+		err := countSockets(name, func(state string) { states[state]++ })
+		if errors.Is(err, os.ErrNotExist) {
+			continue // IPv6 disabled
+		}
+		if err != nil {
+			return nil, fmt.Errorf("net/%s: %w", name, err)
+		}
+		found = true
+	}
+	if !found {
+		return nil, nil
+	}
+	m := &NetStatMetric{TCPStates: states}
+	for _, name := range []string{"udp", "udp6"} {
+		err := countSockets(name, func(string) { m.UDPSockets++ })
+		if err != nil && !errors.Is(err, os.ErrNotExist) {
+			return nil, fmt.Errorf("net/%s: %w", name, err)
+		}
+	}
+
+	counters, err := readNetCounters()
+	if err != nil {
+		return nil, err
+	}
+	m.Counters = netstatCounters.delta(counters)
+	m.Conntrack = readConntrack()
+	return m, nil
+}
+
+// countSockets calls fn with the state name of every socket in /proc/net/<name>.
+func countSockets(name string, fn func(state string)) error {
+	f, err := os.Open(filepath.Join(procRoot, "net", name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Scan() // header
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 4 {
+			continue
+		}
+		state, ok := tcpStateNames[fields[3]]
+		if !ok {
+			state = "UNKNOWN"
+		}
+		fn(state)
+	}
+	return scanner.Err()
+}
+
+// readNetCounters reads /proc/net/snmp and /proc/net/netstat as
+// "Table.Name" keys, with the Udp6 counters of /proc/net/snmp6 added to Udp.
+func readNetCounters() (map[string]uint64, error) {
+	counters := make(map[string]uint64)
+	for _, name := range []string{"snmp", "netstat"} {
+		if err := readProcTables(filepath.Join("net", name), counters); err != nil {
+			return nil, fmt.Errorf("net/%s: %w", name, err)
+		}
+	}
+	if snmp6, err := readProcKeyValues(filepath.Join("net", "snmp6")); err == nil {
+		for key, v := range snmp6 {
+			if name, ok := strings.CutPrefix(key, "Udp6"); ok {
+				counters["Udp."+name] += v
+			}
+		}
+	}
+	return counters, nil
+}
+
+// readProcTables parses the header/value line pairs of /proc/net/snmp and
+// /proc/net/netstat ("Tcp: RtoAlgorithm RtoMin ..." then "Tcp: 1 200 ...")
+// into counters. Negative values (Tcp MaxConn) are skipped.
+func readProcTables(name string, counters map[string]uint64) error {
+	f, err := os.Open(filepath.Join(procRoot, name))
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	scanner := bufio.NewScanner(f)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024) // TcpExt has hundreds of columns
+	var header []string
+	for scanner.Scan() {
+		fields := strings.Fields(scanner.Text())
+		if len(fields) < 2 {
+			continue
+		}
+		if header == nil || header[0] != fields[0] {
+			header = fields
+			continue
+		}
+		table := strings.TrimSuffix(fields[0], ":")
+		for i := 1; i < len(fields) && i < len(header); i++ {
+			if v, err := strconv.ParseUint(fields[i], 10, 64); err == nil {
+				counters[table+"."+header[i]] = v
+			}
+		}
+		header = nil
+	}
+	return scanner.Err()
+}
+
+// delta returns the counters since the previous call and stores the new
+// ones. It returns nil on the first call or when a counter went backwards.
+func (s *netstatSampler) delta(counters map[string]uint64) *NetStatCounters {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	prev := s.prev
+	s.prev = counters
+	if prev == nil {
+		return nil
+	}
+
+	var c NetStatCounters
+	for _, k := range netstatCounterKeys {
+		d, ok := safeDelta(prev[k.key], counters[k.key])
+		if !ok {
+			return nil
+		}
+		*k.dst(&c) = d
+	}
+	return &c
+}
+
+// readConntrack returns nil when the nf_conntrack module is not loaded.
+func readConntrack() *ConntrackMetric {
+	read := func(name string) (uint64, bool) {
+		data, err := os.ReadFile(filepath.Join(procRoot, "sys", "net", "netfilter", name))
+		if err != nil {
+			return 0, false
+		}
+		v, err := strconv.ParseUint(strings.TrimSpace(string(data)), 10, 64)
+		return v, err == nil
+	}
+	count, ok1 := read("nf_conntrack_count")
+	limit, ok2 := read("nf_conntrack_max")
+	if !ok1 || !ok2 {
+		return nil
+	}
+	m := &ConntrackMetric{Count: count, Max: limit}
+	if limit > 0 {
+		m.UsedPercent = float64(count) / float64(limit) * 100
+	}
+	return m
+}
@@ -0,0 +1,98 @@
+package main
+
+import "testing"
+
+const testProcNetTCP = `  sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000:0016 00000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 1 1 0 100 0 0 10 0
+   1: 0100007F:1F90 0100007F:C350 01 00000000:00000000 00:00000000 00000000     0        0 2 1 0 20 4 30 10 -1
+   2: 0100007F:1F90 0100007F:C352 06 00000000:00000000 03:00000F9F 00000000     0        0 0 3 0
+   3: 0100007F:1F90 0100007F:C354 08 00000000:00000000 00:00000000 00000000     0        0 3 1 0 20 4 30 10 -1
+`
+
+const testProcNetTCP6 = `  sl  local_address                         remote_address                        st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode
+   0: 00000000000000000000000000000000:0016 00000000000000000000000000000000:0000 0A 00000000:00000000 00:00000000 00000000     0        0 4 1 0 100 0 0 10 0
+   1: 00000000000000000000000001000000:0016 00000000000000000000000001000000:D2A4 01 00000000:00000000 02:00055D4A 00000000     0        0 5 4 0 20 4 30 10 -1
+`
+
+const testProcNetUDP = `   sl  local_address rem_address   st tx_queue rx_queue tr tm->when retrnsmt   uid  timeout inode ref pointer drops
+  100: 3500007F:0035 00000000:0000 07 00000000:00000000 00:00000000 00000000   101        0 6 2 0 0
+`
+
+func netstatFixture(retrans, overflows, rcvbuf, rcvbuf6 string) map[string]string {
+	return map[string]string{
+		"net/tcp":  testProcNetTCP,
+		"net/tcp6": testProcNetTCP6,
+		"net/udp":  testProcNetUDP,
+		"net/snmp": "Ip: Forwarding DefaultTTL\nIp: 1 64\n" +
+			"Tcp: RtoAlgorithm MaxConn ActiveOpens PassiveOpens AttemptFails EstabResets CurrEstab InSegs OutSegs RetransSegs InErrs OutRsts InCsumErrors\n" +
+			"Tcp: 1 -1 100 200 3 4 5 1000 2000 " + retrans + " 0 7 0\n" +
+			"Udp: InDatagrams NoPorts InErrors OutDatagrams RcvbufErrors SndbufErrors InCsumErrors IgnoredMulti MemErrors\n" +
+			"Udp: 50 1 2 60 " + rcvbuf + " 0 0 0 0\n",
+		"net/snmp6": "Udp6InDatagrams                 \t10\nUdp6RcvbufErrors                \t" + rcvbuf6 + "\n",
+		"net/netstat": "TcpExt: SyncookiesSent ListenOverflows ListenDrops TCPTimeouts\n" +
+			"TcpExt: 0 " + overflows + " " + overflows + " 9\n" +
+			"IpExt: InNoRoutes\nIpExt: 0\n",
+		"sys/net/netfilter/nf_conntrack_count": "65000\n",
+		"sys/net/netfilter/nf_conntrack_max":   "65536\n",
+	}
+}
+
+func TestReadNetStat(t *testing.T) {
+	old := netstatCounters
+	netstatCounters = &netstatSampler{}
+	t.Cleanup(func() { netstatCounters = old })
+
+	withProcRoot(t, netstatFixture("30", "2", "5", "1"))
+	m, err := readNetStat()
+	if err != nil {
+		t.Fatalf("readNetStat: %v", err)
+	}
+	expect := map[string]int{"LISTEN": 2, "ESTABLISHED": 2, "TIME_WAIT": 1, "CLOSE_WAIT": 1}
+	for state, n := range expect {
+		if m.TCPStates[state] != n {
+			t.Errorf("%s = %d, expected %d (all: %v)", state, m.TCPStates[state], n, m.TCPStates)
+		}
+	}
+	if m.UDPSockets != 1 {
+		t.Errorf("expected 1 UDP socket, got %d", m.UDPSockets)
+	}
+	if m.Counters != nil {
+		t.Errorf("expected no counters on the first sample, got %+v", m.Counters)
+	}
+	if c := m.Conntrack; c == nil || c.Count != 65000 || c.Max != 65536 || c.UsedPercent < 99 {
+		t.Errorf("unexpected conntrack: %+v", c)
+	}
+
+	withProcRoot(t, netstatFixture("45", "12", "8", "3"))
+	m, err = readNetStat()
+	if err != nil {
+		t.Fatalf("readNetStat: %v", err)
+	}
+	c := m.Counters
+	if c == nil {
+		t.Fatal("expected counters on the second sample")
+	}
+	if c.TCPRetransSegments != 15 || c.TCPListenOverflows != 10 || c.TCPListenDrops != 10 {
+		t.Errorf("unexpected TCP deltas: %+v", c)
+	}
+	if c.UDPRcvbufErrors != 5 { // 3 IPv4 + 2 IPv6
+		t.Errorf("expected UDP rcvbuf errors from snmp and snmp6, got %d", c.UDPRcvbufErrors)
+	}
+	if c.TCPActiveOpens != 0 || c.TCPTimeouts != 0 {
+		t.Errorf("expected unchanged counters to be zero, got %+v", c)
+	}
+
+	// A counter reset yields no deltas rather than a bogus value
+	withProcRoot(t, netstatFixture("1", "12", "8", "3"))
+	if m, _ = readNetStat(); m.Counters != nil {
+		t.Errorf("expected no counters after a reset, got %+v", m.Counters)
+	}
+}
+
+func TestReadNetStat_Missing(t *testing.T) {
+	withProcRoot(t, map[string]string{})
+	m, err := readNetStat()
+	if m != nil || err != nil {
+		t.Errorf("expected nil without /proc/net, got %+v, %v", m, err)
+	}
+}
@@ -0,0 +1,123 @@
+package main
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+// logPipeline holds the processing stages applied to log entries before they
+// are shipped to the backend. A nil pipeline passes entries through unchanged.
+type logPipeline struct {
+	metrics  *logMetricExtractor
+	filter   *logFilter
+	redactor *redactor
+}
+
+// newLogPipeline compiles the rules file into a ready-to-use pipeline.
+func newLogPipeline(rules LogRules) (*logPipeline, error) {
+	metrics, err := newLogMetricExtractor(rules.Metrics)
+	if err != nil {
+		return nil, err
+	}
+	filter, err := newLogFilter(rules.Filter)
+	if err != nil {
+		return nil, err
+	}
+	red, err := newRedactor(rules.Redact)
+	if err != nil {
+		return nil, err
+	}
+	return &logPipeline{metrics: metrics, filter: filter, redactor: red}, nil
+}
+
+// selectEntries applies the filter stage, then any source-specific filters,
+// and finally the per-source cap, so dropped noise never eats a source's budget.
+func (p *logPipeline) selectEntries(entries []LogEntry, sourceFilters ...*logFilter) []LogEntry {
+	entries = p.filterEntries(entries)
+	for _, f := range sourceFilters {
+		entries = f.filterEntries(entries)
+	}
+	return capPerSource(entries)
+}
+
+// filterEntries feeds every entry to the log-to-metric rules, then applies the
+// filter stage without capping. Metrics see lines the filter later drops.
+func (p *logPipeline) filterEntries(entries []LogEntry) []LogEntry {
+	if p == nil {
+		return entries
+	}
+	p.metrics.observe(entries)
+	return p.filter.filterEntries(entries)
+}
+
+// filterWithoutMetrics applies the filter stage only, for replayed lines that
+// must not be counted again by log-to-metric rules.
+func (p *logPipeline) filterWithoutMetrics(entries []LogEntry) []LogEntry {
+	if p == nil {
+		return entries
+	}
+	return p.filter.filterEntries(entries)
+}
+
+// takeLogMetrics returns the log-derived samples accumulated since the last call.
+func (p *logPipeline) takeLogMetrics() []LogMetricSample {
+	if p == nil {
+		return nil
+	}
+	return p.metrics.take()
+}
+
+// restoreLogMetrics returns samples that failed to send to the pending set.
+func (p *logPipeline) restoreLogMetrics(samples []LogMetricSample) {
+	if p == nil {
+		return
+	}
+	p.metrics.restore(samples)
+}
+
+// prepare runs the outgoing stages on a payload right before it is sent.
+func (p *logPipeline) prepare(payload *LogIngestPayload) {
+	if p == nil {
+		return
+	}
+	payload.Redactions = p.redactor.redactEntries(payload.Entries)
+}
+
+// redactFields redacts free-text fields of non-log payloads (security events).
+func (p *logPipeline) redactFields(fields ...*string) {
+	if p == nil {
+		return
+	}
+	p.redactor.redactFields(fields...)
+}
+
+// redactionTotals returns the cumulative redaction counts since startup.
+func (p *logPipeline) redactionTotals() map[string]int64 {
+	if p == nil {
+		return nil
+	}
+	return p.redactor.snapshot()
+}
+
+// droppedTotals returns cumulative filter drop counts by reason since startup.
+func (p *logPipeline) droppedTotals() map[string]int64 {
+	if p == nil {
+		return nil
+	}
+	return p.filter.droppedTotals()
+}
+
+// formatCounts renders counts as "name=N" pairs sorted by name for logging.
+func formatCounts[T int | int64](counts map[string]T) string {
+	names := make([]string, 0, len(counts))
+	for name := range counts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	parts := make([]string, 0, len(names))
+	for _, name := range names {
+		parts = append(parts, fmt.Sprintf("%s=%d", name, counts[name]))
+	}
+	return strings.Join(parts, " ")
+}
@@ -0,0 +1,227 @@
+package main
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/shirou/gopsutil/v3/host"
+)
+
+// bootIDFile is where the last seen boot ID is kept inside the state directory.
+const bootIDFile = "boot_id"
+
+// rebootJournalTail is how many of the previous boot's last journal entries
+// are searched for a shutdown message.
+const rebootJournalTail = 100
+
+// cleanShutdownMarkers are messages systemd and logind write only while
+// shutting down in an orderly way. "Journal stopped" is the very last one.
+var cleanShutdownMarkers = []string{
+	"Journal stopped",
+	"Reached target Shutdown",
+	"Reached target System Reboot",
+	"Reached target System Power Off",
+	"Reached target System Halt",
+	"Reached target Reboot",
+	"Reached target Power-Off",
+	"Reached target Final Step",
+	"System is rebooting",
+	"System is powering down",
+	"System is halting",
+}
+
+// crashMarkers explain an unexpected reboot when they were logged last.
+var crashMarkers = []string{
+	"Kernel panic",
+	"BUG: ",
+	"watchdog: ",
+	"Out of memory",
+}
+
+// previousBootJournal returns the last journal entries of a boot; a
+// variable for tests.
+var previousBootJournal = readBootJournalTail
+
+// RebootEvent reports that the host booted since the agent last ran.
+type RebootEvent struct {
+	Timestamp      string `json:"timestamp"` // boot time of the current boot
+	BootID         string `json:"boot_id"`
+	PreviousBootID string `json:"previous_boot_id"`
+	// Kind is "clean" when the previous boot logged an orderly shutdown,
+	// "unexpected" when its journal just stops (crash, power loss, hard reset)
+	// and "unknown" when its journal is not available.
+	Kind        string `json:"kind"`
+	LastEntryAt string `json:"last_entry_at,omitempty"` // last journal entry of the previous boot
+	Detail      string `json:"detail,omitempty"`        // the shutdown or crash message found
+}
+
+type RebootEventPayload struct {
+	Events []RebootEvent `json:"events"`
+}
+
+// defaultStateDir is used when --state-dir is not given. Reboot detection
+// relies on Linux boot IDs, so other platforms keep no state by default.
+func defaultStateDir() string {
+	if runtime.GOOS != "linux" {
+		return ""
+	}
+	return "/var/lib/omnipulse-agent"
+}
+
+// sendRebootEventToBackend compares the current boot ID with the one saved
+// in the state directory and reports a reboot when they differ. The new ID
+// is only saved once the event is delivered, so a failed send is retried on
+// the next call.
+func sendRebootEventToBackend(client *http.Client, cfg Config, logger *log.Logger) {
+	if cfg.StateDir == "" {
+		return
+	}
+	bootID, err := readBootID()
+	if err != nil {
+		return // not Linux, or /proc is not mounted
+	}
+
+	statePath := filepath.Join(cfg.StateDir, bootIDFile)
+	data, err := os.ReadFile(statePath)
+	if err != nil && !errors.Is(err, os.ErrNotExist) {
+		logger.Printf("reboot detection: %v", err)
+		return
+	}
+	previous := strings.TrimSpace(string(data))
+	if previous == bootID {
+		return
+	}
+
+	if previous != "" {
+		ev := classifyReboot(previous, bootID)
+		if err := sendRebootEvents(client, cfg, RebootEventPayload{Events: []RebootEvent{ev}}); err != nil {
+			logger.Printf("reboot event ingest failed: %v", err)
+			return
+		}
+		logger.Printf("reboot detected: %s (previous boot %s, last entry %s)", ev.Kind, ev.PreviousBootID, ev.LastEntryAt)
+	}
+
+	if err := os.MkdirAll(cfg.StateDir, 0o755); err != nil {
+		logger.Printf("reboot detection: %v", err)
+		return
+	}
+	if err := os.WriteFile(statePath, []byte(bootID+"\n"), 0o644); err != nil {
+		logger.Printf("reboot detection: %v", err)
+	}
+}
+
+// classifyReboot builds the event for a reboot from the previous boot's journal.
+func classifyReboot(previous, current string) RebootEvent {
+	ev := RebootEvent{
+		Timestamp:      time.Now().UTC().Format(time.RFC3339Nano),
+		BootID:         current,
+		PreviousBootID: previous,
+		Kind:           "unknown",
+	}
+	if boot, err := host.BootTime(); err == nil {
+		ev.Timestamp = time.Unix(int64(boot), 0).UTC().Format(time.RFC3339Nano)
+	}
+
+	entries, err := previousBootJournal(previous)
+	if err != nil || len(entries) == 0 {
+		return ev // no journalctl, or volatile journal storage
+	}
+	ev.LastEntryAt = parseJournalTimestamp(entries[len(entries)-1].RealtimeTimestamp)
+	ev.Kind, ev.Detail = classifyShutdown(entries)
+	return ev
+}
+
+// classifyShutdown looks for an orderly shutdown among the last entries of
+// a boot. Without one the boot ended unexpectedly; the last crash message,
+// if any, is returned as the detail.
+func classifyShutdown(entries []journalctlEntry) (kind, detail string) {
+	for i := len(entries) - 1; i >= 0; i-- {
+		for _, marker := range cleanShutdownMarkers {
+			if strings.Contains(entries[i].Message, marker) {
+				return "clean", entries[i].Message
+			}
+		}
+	}
+	for i := len(entries) - 1; i >= 0; i-- {
+		for _, marker := range crashMarkers {
+			if strings.Contains(entries[i].Message, marker) {
+				return "unexpected", entries[i].Message
+			}
+		}
+	}
+	return "unexpected", ""
+}
+
+// readBootJournalTail returns the last entries journald kept for a boot.
+func readBootJournalTail(bootID string) ([]journalctlEntry, error) {
+	// Boot IDs are accepted without dashes by every journalctl version
+	id := strings.ReplaceAll(bootID, "-", "")
+	cmd := exec.Command("journalctl", "-b", id, "-n", strconv.Itoa(rebootJournalTail), "--output", "json", "--no-pager")
+	out, err := cmd.Output()
+	if err != nil {
+		return nil, fmt.Errorf("journalctl: %w", err)
+	}
+
+	var entries []journalctlEntry
+	scanner := bufio.NewScanner(bytes.NewReader(out))
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024)
+	for scanner.Scan() {
+		var je journalctlEntry
+		if err := json.Unmarshal(scanner.Bytes(), &je); err == nil {
+			entries = append(entries, je)
+		}
+	}
+	return entries, scanner.Err()
+}
+
+// readBootID returns the kernel's random ID for the current boot.
+func readBootID() (string, error) {
+	data, err := os.ReadFile(filepath.Join(procRoot, "sys", "kernel", "random", "boot_id"))
+	if err != nil {
+		return "", err
+	}
+	id := strings.TrimSpace(string(data))
+	if id == "" {
+		return "", errors.New("empty boot_id")
+	}
+	return id, nil
+}
+
+// sendRebootEvents sends reboot events to backend
+func sendRebootEvents(client *http.Client, cfg Config, payload RebootEventPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := cfg.BaseURL + "/api/ingest/server-reboot-events"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return nil
+}
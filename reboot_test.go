@@ -0,0 +1,129 @@
+package main
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestClassifyShutdown(t *testing.T) {
+	msgs := func(messages ...string) []journalctlEntry {
+		entries := make([]journalctlEntry, len(messages))
+		for i, m := range messages {
+			entries[i].Message = m
+		}
+		return entries
+	}
+	tests := []struct {
+		name    string
+		entries []journalctlEntry
+		kind    string
+		detail  string
+	}{
+		{"journal stopped", msgs("Stopping nginx...", "Reached target System Reboot.", "Journal stopped"), "clean", "Journal stopped"},
+		{"logind power off", msgs("System is powering down.", "Stopped target Multi-User System."), "clean", "System is powering down."},
+		{"just stops", msgs("Accepted publickey for root", "Started session 4."), "unexpected", ""},
+		{"panic", msgs("Kernel panic - not syncing: Fatal exception", "Rebooting in 10 seconds.."), "unexpected", "Kernel panic - not syncing: Fatal exception"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			kind, detail := classifyShutdown(tt.entries)
+			if kind != tt.kind || detail != tt.detail {
+				t.Errorf("classifyShutdown = %q, %q; expected %q, %q", kind, detail, tt.kind, tt.detail)
+			}
+		})
+	}
+}
+
+func TestSendRebootEventToBackend(t *testing.T) {
+	var received []RebootEvent
+	status := http.StatusOK
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/api/ingest/server-reboot-events" {
+			t.Errorf("unexpected path %s", r.URL.Path)
+		}
+		if status != http.StatusOK {
+			w.WriteHeader(status)
+			return
+		}
+		var p RebootEventPayload
+		json.NewDecoder(r.Body).Decode(&p)
+		received = append(received, p.Events...)
+	}))
+	defer server.Close()
+
+	defer func(f func(string) ([]journalctlEntry, error)) { previousBootJournal = f }(previousBootJournal)
+	var journalBoot string
+	previousBootJournal = func(bootID string) ([]journalctlEntry, error) {
+		journalBoot = bootID
+		if bootID == "volatile" {
+			return nil, errors.New("no persistent journal")
+		}
+		return []journalctlEntry{
+			{Message: "Reached target Shutdown.", RealtimeTimestamp: "1700000000000000"},
+			{Message: "Journal stopped", RealtimeTimestamp: "1700000001000000"},
+		}, nil
+	}
+
+	stateDir := filepath.Join(t.TempDir(), "state")
+	statePath := filepath.Join(stateDir, bootIDFile)
+	cfg := Config{BaseURL: server.URL, Token: "tok", Timeout: 5 * time.Second, StateDir: stateDir}
+	logger := log.New(io.Discard, "", 0)
+	boot := func(id string) { withProcRoot(t, map[string]string{"sys/kernel/random/boot_id": id + "\n"}) }
+	saved := func() string {
+		data, _ := os.ReadFile(statePath)
+		return strings.TrimSpace(string(data))
+	}
+
+	// First run: remember the boot without reporting it
+	boot("boot-1")
+	sendRebootEventToBackend(server.Client(), cfg, logger)
+	if len(received) != 0 || saved() != "boot-1" {
+		t.Fatalf("expected boot saved without event, got %v and %q", received, saved())
+	}
+
+	// Same boot: nothing to report
+	sendRebootEventToBackend(server.Client(), cfg, logger)
+	if len(received) != 0 {
+		t.Fatalf("unexpected events: %v", received)
+	}
+
+	// Rebooted while the backend is down: retried later
+	boot("boot-2")
+	status = http.StatusServiceUnavailable
+	sendRebootEventToBackend(server.Client(), cfg, logger)
+	if saved() != "boot-1" {
+		t.Fatalf("expected boot ID kept until delivery, got %q", saved())
+	}
+
+	status = http.StatusOK
+	sendRebootEventToBackend(server.Client(), cfg, logger)
+	if len(received) != 1 {
+		t.Fatalf("expected one reboot event, got %v", received)
+	}
+	ev := received[0]
+	if ev.BootID != "boot-2" || ev.PreviousBootID != "boot-1" || journalBoot != "boot-1" {
+		t.Errorf("unexpected boot IDs: %+v (journal read for %q)", ev, journalBoot)
+	}
+	if ev.Kind != "clean" || ev.Detail != "Journal stopped" || ev.LastEntryAt != "2023-11-14T22:13:21Z" {
+		t.Errorf("unexpected classification: %+v", ev)
+	}
+	if saved() != "boot-2" {
+		t.Errorf("expected new boot ID saved, got %q", saved())
+	}
+
+	// Previous boot's journal not kept: still reported
+	os.WriteFile(statePath, []byte("volatile\n"), 0o644)
+	sendRebootEventToBackend(server.Client(), cfg, logger)
+	if len(received) != 2 || received[1].Kind != "unknown" {
+		t.Errorf("expected unknown reboot, got %+v", received)
+	}
+}
@@ -0,0 +1,355 @@
+package main
+
+import (
+	"bufio"
+	"compress/bzip2"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+	"time"
+)
+
+// maxBackfillLines bounds how many lines a single backfill returns per file;
+// the newest lines are kept.
+const maxBackfillLines = 10000
+
+// rotatedFile is a rotated sibling of a live log file (app.log.1, app.log.2.gz,
+// app.log-20260301.gz, ...).
+type rotatedFile struct {
+	path    string
+	info    os.FileInfo
+	modTime time.Time
+}
+
+func (r rotatedFile) compressed() bool {
+	return isCompressedLog(r.path)
+}
+
+func isCompressedLog(path string) bool {
+	ext := filepath.Ext(path)
+	return ext == ".gz" || ext == ".bz2" || ext == ".xz"
+}
+
+// findRotatedSiblings returns the rotated siblings of path modified at or after
+// notBefore, oldest first.
+func findRotatedSiblings(path string, notBefore time.Time) []rotatedFile {
+	dir, base := filepath.Split(path)
+	if dir == "" {
+		dir = "."
+	}
+	pattern := regexp.MustCompile(`^` + regexp.QuoteMeta(base) +
+		`[.-](\d+|\d{4}-?\d{2}-?\d{2}(?:-\d+)?)(?:\.(?:gz|bz2|xz))?$`)
+
+	dirEntries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil
+	}
+
+	var files []rotatedFile
+	for _, e := range dirEntries {
+		if e.IsDir() || !pattern.MatchString(e.Name()) {
+			continue
+		}
+		info, err := e.Info()
+		if err != nil || info.ModTime().Before(notBefore) {
+			continue
+		}
+		files = append(files, rotatedFile{
+			path:    filepath.Join(dir, e.Name()),
+			info:    info,
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(files, func(i, j int) bool {
+		return files[i].modTime.Before(files[j].modTime)
+	})
+	return files
+}
+
+// openLogReader opens a log file, decompressing gzip, bzip2 or xz on the fly.
+// xz has no standard library decoder, so the xz binary is used like the other
+// system tools the agent shells out to.
+func openLogReader(path string) (io.ReadCloser, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch filepath.Ext(path) {
+	case ".gz":
+		gz, err := gzip.NewReader(f)
+		if err != nil {
+			f.Close()
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{gz, closerFunc(func() error { gz.Close(); return f.Close() })}, nil
+	case ".bz2":
+		return struct {
+			io.Reader
+			io.Closer
+		}{bzip2.NewReader(f), f}, nil
+	case ".xz":
+		f.Close()
+		cmd := exec.Command("xz", "-dc", path)
+		out, err := cmd.StdoutPipe()
+		if err != nil {
+			return nil, err
+		}
+		if err := cmd.Start(); err != nil {
+			return nil, err
+		}
+		return struct {
+			io.Reader
+			io.Closer
+		}{out, closerFunc(func() error { out.Close(); return cmd.Wait() })}, nil
+	default:
+		return f, nil
+	}
+}
+
+type closerFunc func() error
+
+func (c closerFunc) Close() error { return c() }
+
+// readFileLines reads the lines of a (possibly compressed) file, skipping the
+// first skip bytes of decompressed content and stopping after limit bytes when
+// limit >= 0. Only the newest maxBackfillLines are kept.
+func readFileLines(path string, skip, limit int64) ([]string, error) {
+	rc, err := openLogReader(path)
+	if err != nil {
+		return nil, err
+	}
+	defer rc.Close()
+
+	var r io.Reader = rc
+	if skip > 0 {
+		if _, err := io.CopyN(io.Discard, r, skip); err != nil {
+			if err == io.EOF {
+				return nil, nil
+			}
+			return nil, err
+		}
+	}
+	if limit >= 0 {
+		r = io.LimitReader(r, limit)
+	}
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 0, 64*1024), 256*1024)
+	for scanner.Scan() {
+		line := strings.TrimRight(scanner.Text(), "\r")
+		if line == "" {
+			continue
+		}
+		lines = append(lines, line)
+		if len(lines) > maxBackfillLines {
+			lines = lines[1:]
+		}
+	}
+	return lines, scanner.Err()
+}
+
+// readRotatedRemainder returns the lines written to a file after cur.offset
+// once it has been rotated away from path. Only uncompressed siblings can be
+// matched by identity; a compressed predecessor yields nothing.
+func readRotatedRemainder(path string, cur *fileCursor) []string {
+	for _, rf := range findRotatedSiblings(path, time.Time{}) {
+		if rf.compressed() || !os.SameFile(rf.info, cur.info) {
+			continue
+		}
+		if rf.info.Size() <= cur.offset {
+			return nil
+		}
+		lines, _ := readFileLines(rf.path, cur.offset, -1)
+		return lines
+	}
+	return nil
+}
+
+// backfillFile replays lines of path between two cursors, following rotation:
+// from the position in from (or, when from is nil or its file can no longer be
+// identified, from every rotated sibling modified within maxAge) up to the
+// position in to (or the live end of file when to is nil). Lines are returned
+// in chronological order. When to is nil the tailer is positioned at the live
+// end of file so regular polling does not resend them.
+func (t *fileTailer) backfillFile(path string, from, to *fileCursor, maxAge time.Duration) ([]string, error) {
+	liveInfo, err := os.Stat(path)
+	if err != nil {
+		return nil, err
+	}
+
+	notBefore := time.Now().Add(-maxAge)
+	if from != nil && from.readAt.After(notBefore) {
+		notBefore = from.readAt
+	}
+	siblings := findRotatedSiblings(path, notBefore)
+
+	type segment struct {
+		path  string
+		info  os.FileInfo
+		start int64
+		end   int64 // -1 = to end of file
+	}
+	segments := make([]segment, 0, len(siblings)+1)
+	for _, rf := range siblings {
+		segments = append(segments, segment{path: rf.path, info: rf.info, end: -1})
+	}
+	segments = append(segments, segment{path: path, info: liveInfo, end: -1})
+
+	// Start where the from cursor left off, if its file is still identifiable
+	if from != nil {
+		for i, seg := range segments {
+			if os.SameFile(seg.info, from.info) {
+				segments = segments[i:]
+				segments[0].start = from.offset
+				break
+			}
+		}
+	}
+
+	// Stop where the to cursor begins
+	if to != nil {
+		for i, seg := range segments {
+			if os.SameFile(seg.info, to.info) {
+				segments = segments[:i+1]
+				segments[i].end = to.offset
+				break
+			}
+		}
+	}
+
+	var lines []string
+	for _, seg := range segments {
+		limit := int64(-1)
+		if seg.end >= 0 {
+			limit = seg.end - seg.start
+			if limit <= 0 {
+				continue
+			}
+		}
+		segLines, err := readFileLines(seg.path, seg.start, limit)
+		if err != nil {
+			continue
+		}
+		lines = append(lines, segLines...)
+	}
+	if len(lines) > maxBackfillLines {
+		lines = lines[len(lines)-maxBackfillLines:]
+	}
+
+	if to == nil {
+		cur := &fileCursor{offset: liveInfo.Size(), info: liveInfo, readAt: time.Now()}
+		if prev := t.cursor(path); prev != nil {
+			cur.rotations = prev.rotations
+		}
+		t.store(path, cur)
+	}
+	return lines, nil
+}
+
+// lineTimestampLayouts are common leading timestamp formats in application logs.
+var lineTimestampLayouts = []struct {
+	re     *regexp.Regexp
+	layout string
+	local  bool
+}{
+	{regexp.MustCompile(`^\d{4}-\d{2}-\d{2}T\d{2}:\d{2}:\d{2}(?:\.\d+)?(?:Z|[+-]\d{2}:\d{2})`), time.RFC3339Nano, false},
+	{regexp.MustCompile(`^\d{4}-\d{2}-\d{2} \d{2}:\d{2}:\d{2}`), "2006-01-02 15:04:05", true},
+	{regexp.MustCompile(`^\d{4}/\d{2}/\d{2} \d{2}:\d{2}:\d{2}`), "2006/01/02 15:04:05", true},
+	{regexp.MustCompile(`\[\d{2}/[A-Z][a-z]{2}/\d{4}:\d{2}:\d{2}:\d{2} [+-]\d{4}\]`), "[02/Jan/2006:15:04:05 -0700]", false},
+}
+
+// parseLineTimestamp extracts a timestamp from the start of a log line (or the
+// bracketed Apache/Nginx access log time). ok is false when none is found.
+func parseLineTimestamp(line string) (time.Time, bool) {
+	for _, l := range lineTimestampLayouts {
+		m := l.re.FindString(line)
+		if m == "" {
+			continue
+		}
+		var ts time.Time
+		var err error
+		if l.local {
+			ts, err = time.ParseInLocation(l.layout, m, time.Local)
+		} else {
+			ts, err = time.Parse(l.layout, m)
+		}
+		if err == nil {
+			return ts, true
+		}
+	}
+	return time.Time{}, false
+}
+
+// backfillFileLogsToBackend replays monitored files through the filter and
+// redaction stages and sends them in chronological batches. from/to bound the
+// replay per path (nil entries fall back to age-based backfill). Backfilled
+// lines skip log-to-metric extraction: they were either counted when first
+// read or are too old to belong to the current interval. It stops at the
+// first path the backend does not accept.
+func backfillFileLogsToBackend(client *http.Client, cfg Config, logger *log.Logger, tails *fileTailer, paths []string, from, to map[string]*fileCursor) error {
+	cutoff := time.Now().Add(-cfg.LogBackfillAge)
+	hostname, _ := os.Hostname()
+
+	for _, path := range paths {
+		// A path first read during the outage has nothing to replay
+		if to != nil && to[path] == nil {
+			continue
+		}
+		lines, err := tails.backfillFile(path, from[path], to[path], cfg.LogBackfillAge)
+		if err != nil || len(lines) == 0 {
+			continue
+		}
+
+		now := time.Now().UTC()
+		entries := make([]LogEntry, 0, len(lines))
+		for _, line := range lines {
+			line = strings.TrimSpace(line)
+			if line == "" {
+				continue
+			}
+			ts := now
+			if parsed, ok := parseLineTimestamp(line); ok {
+				if parsed.Before(cutoff) {
+					continue
+				}
+				ts = parsed.UTC()
+			}
+			entries = append(entries, LogEntry{
+				Timestamp:  ts.Format(time.RFC3339Nano),
+				Level:      detectLogLevel(line),
+				Service:    fileLogService(path),
+				Host:       hostname,
+				Message:    line,
+				Source:     path,
+				Attributes: map[string]string{"backfill": "true"},
+			})
+		}
+
+		entries = cfg.Pipeline.filterWithoutMetrics(entries)
+		if len(entries) == 0 {
+			continue
+		}
+		sent, _, err := shipLogs(client, cfg, entries)
+		if sent > 0 {
+			logger.Printf("backfill sent: %d entries from %s", sent, path)
+		}
+		if err != nil {
+			return fmt.Errorf("%s: %w", path, err)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,184 @@
+package main
+
+import (
+	"compress/gzip"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeGzip(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	gz := gzip.NewWriter(f)
+	if _, err := gz.Write([]byte(content)); err != nil {
+		t.Fatal(err)
+	}
+	if err := gz.Close(); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func setModTime(t *testing.T, path string, mt time.Time) {
+	t.Helper()
+	if err := os.Chtimes(path, mt, mt); err != nil {
+		t.Fatal(err)
+	}
+}
+
+// --- findRotatedSiblings Tests ---
+
+func TestFindRotatedSiblings_OldestFirst(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "live\n")
+	appendFile(t, path+".1", "one\n")
+	writeGzip(t, path+".2.gz", "two\n")
+	appendFile(t, path+"-20260301", "dated\n")
+	appendFile(t, filepath.Join(dir, "app.log.bak"), "ignored\n")
+	appendFile(t, filepath.Join(dir, "other.log.1"), "ignored\n")
+
+	now := time.Now()
+	setModTime(t, path+"-20260301", now.Add(-3*time.Hour))
+	setModTime(t, path+".2.gz", now.Add(-2*time.Hour))
+	setModTime(t, path+".1", now.Add(-1*time.Hour))
+
+	got := findRotatedSiblings(path, time.Time{})
+	if len(got) != 3 {
+		t.Fatalf("expected 3 siblings, got %d: %+v", len(got), got)
+	}
+	want := []string{path + "-20260301", path + ".2.gz", path + ".1"}
+	for i, rf := range got {
+		if rf.path != want[i] {
+			t.Errorf("sibling %d: expected %s, got %s", i, want[i], rf.path)
+		}
+	}
+
+	if recent := findRotatedSiblings(path, now.Add(-90*time.Minute)); len(recent) != 1 {
+		t.Errorf("expected 1 sibling newer than cutoff, got %d", len(recent))
+	}
+}
+
+func TestReadFileLines_Gzip(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log.1.gz")
+	writeGzip(t, path, "one\ntwo\nthree\n")
+
+	lines, err := readFileLines(path, 4, -1)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("expected lines after skip, got %q", lines)
+	}
+}
+
+// --- rotation Tests ---
+
+func TestFileTailer_FollowsRotationRemainder(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\n")
+
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	if _, err := tailer.readNewLines(path, 10); err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	appendFile(t, path, "two\n")
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "three\n")
+
+	lines, err := tailer.readNewLines(path, 10)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("expected remainder of rotated file then new file, got %q", lines)
+	}
+}
+
+func TestFileTailer_BackfillReplaysAcrossRotation(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "sent\n")
+
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	tailer.readNewLines(path, 10)
+	from := tailer.cursor(path)
+
+	// Lines read while the backend was unreachable
+	appendFile(t, path, "lost-1\n")
+	tailer.readNewLines(path, 10)
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "lost-2\n")
+	tailer.readNewLines(path, 10)
+	to := tailer.cursor(path)
+
+	appendFile(t, path, "next-poll\n")
+
+	lines, err := tailer.backfillFile(path, from, to, time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "lost-1" || lines[1] != "lost-2" {
+		t.Errorf("expected exactly the unsent lines, got %q", lines)
+	}
+
+	// The tailer keeps its position so the next poll is unaffected
+	if next, _ := tailer.readNewLines(path, 10); len(next) != 1 || next[0] != "next-poll" {
+		t.Errorf("expected next poll to read only new lines, got %q", next)
+	}
+}
+
+func TestFileTailer_BackfillNewPathByAge(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	writeGzip(t, path+".2.gz", "old\n")
+	appendFile(t, path+".1", "recent-1\n")
+	appendFile(t, path, "recent-2\n")
+	setModTime(t, path+".2.gz", time.Now().Add(-48*time.Hour))
+
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	lines, err := tailer.backfillFile(path, nil, nil, 24*time.Hour)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "recent-1" || lines[1] != "recent-2" {
+		t.Errorf("expected siblings within age then live file, got %q", lines)
+	}
+
+	appendFile(t, path, "after\n")
+	if next, _ := tailer.readNewLines(path, 10); len(next) != 1 || next[0] != "after" {
+		t.Errorf("expected tailer positioned at end after backfill, got %q", next)
+	}
+}
+
+func TestParseLineTimestamp(t *testing.T) {
+	tests := []struct {
+		line string
+		ok   bool
+		want time.Time
+	}{
+		{"2026-03-01T10:00:00Z started", true, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{`10.0.0.1 - - [01/Mar/2026:10:00:00 +0000] "GET / HTTP/1.1" 200`, true, time.Date(2026, 3, 1, 10, 0, 0, 0, time.UTC)},
+		{"2026/03/01 10:00:00 listening", true, time.Date(2026, 3, 1, 10, 0, 0, 0, time.Local)},
+		{"no timestamp here", false, time.Time{}},
+	}
+
+	for _, tt := range tests {
+		got, ok := parseLineTimestamp(tt.line)
+		if ok != tt.ok {
+			t.Errorf("%q: expected ok=%v, got %v", tt.line, tt.ok, ok)
+			continue
+		}
+		if ok && !got.Equal(tt.want) {
+			t.Errorf("%q: expected %v, got %v", tt.line, tt.want, got)
+		}
+	}
+}
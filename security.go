@@ -0,0 +1,320 @@
+package main
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// authLogFiles are read when the journal is not readable (rsyslog-only hosts).
+var authLogFiles = []string{"/var/log/auth.log", "/var/log/secure"}
+
+// maxPendingSecurityEvents bounds events kept for retry while the backend is down.
+const maxPendingSecurityEvents = 2000
+
+// Defaults for brute-force detection when the rules file does not set them.
+const (
+	defaultBruteForceThreshold = 10
+	defaultBruteForceWindow    = 5 * time.Minute
+)
+
+// SecurityEvent is a structured authentication or privilege event.
+type SecurityEvent struct {
+	Timestamp  string `json:"timestamp"`
+	Type       string `json:"type"` // login_success, login_failed, invalid_user, sudo_command, sudo_failed, su, su_failed, brute_force
+	Host       string `json:"host"`
+	Service    string `json:"service"`
+	User       string `json:"user,omitempty"`
+	TargetUser string `json:"target_user,omitempty"`
+	SourceIP   string `json:"source_ip,omitempty"`
+	Port       int    `json:"port,omitempty"`
+	Method     string `json:"method,omitempty"` // password, publickey, ...
+	TTY        string `json:"tty,omitempty"`
+	PWD        string `json:"pwd,omitempty"`
+	Command    string `json:"command,omitempty"`
+	Count      int    `json:"count,omitempty"` // brute_force: failures within the window
+	Message    string `json:"message,omitempty"`
+}
+
+// SecurityEventPayload is sent to POST /api/ingest/server-security-events
+type SecurityEventPayload struct {
+	Events []SecurityEvent `json:"events"`
+}
+
+var (
+	sshAcceptedRe    = regexp.MustCompile(`^Accepted (\S+) for (\S+) from (\S+) port (\d+)`)
+	sshFailedRe      = regexp.MustCompile(`^Failed (\S+) for (?:invalid user )?(\S*) from (\S+) port (\d+)`)
+	sshInvalidUserRe = regexp.MustCompile(`^Invalid user (\S*) from (\S+)(?: port (\d+))?`)
+	sudoCommandRe    = regexp.MustCompile(`^\s*(\S+) : (.*?)TTY=(\S+) ; PWD=(.+?) ; USER=(\S+) ; (?:.*?; )?COMMAND=(.*)$`)
+	suOpenedRe       = regexp.MustCompile(`^pam_unix\(su(?:-l)?:session\): session opened for user ([^\s(]+)(?:\(uid=\d+\))? by ([^\s(]*)`)
+	suFailedRe       = regexp.MustCompile(`^pam_unix\(su(?:-l)?:auth\): authentication failure;.*?ruser=(\S*).*?user=(\S+)`)
+	suLegacyRe       = regexp.MustCompile(`^(FAILED SU|Successful su|\+) \(?to (\S+?)\)? (\S+) on (\S+)`)
+)
+
+// parseSecurityEvent turns an sshd, sudo or su log entry into an event.
+func parseSecurityEvent(e LogEntry) (SecurityEvent, bool) {
+	ev := SecurityEvent{
+		Timestamp: e.Timestamp,
+		Host:      e.Host,
+		Service:   e.Service,
+		Message:   e.Message,
+	}
+	msg := e.Message
+
+	switch e.Service {
+	case "sshd", "sshd-session":
+		if m := sshAcceptedRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.Method, ev.User, ev.SourceIP = "login_success", m[1], m[2], m[3]
+			ev.Port, _ = strconv.Atoi(m[4])
+		} else if m := sshFailedRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.Method, ev.User, ev.SourceIP = "login_failed", m[1], m[2], m[3]
+			ev.Port, _ = strconv.Atoi(m[4])
+		} else if m := sshInvalidUserRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.User, ev.SourceIP = "invalid_user", m[1], m[2]
+			ev.Port, _ = strconv.Atoi(m[3])
+		} else {
+			return ev, false
+		}
+	case "sudo":
+		if m := sudoCommandRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.User, ev.TTY, ev.PWD, ev.TargetUser, ev.Command = "sudo_command", m[1], m[3], m[4], m[5], m[6]
+			if strings.Contains(m[2], "incorrect password attempt") || strings.Contains(m[2], "NOT in sudoers") ||
+				strings.Contains(m[2], "command not allowed") {
+				ev.Type = "sudo_failed"
+			}
+		} else {
+			return ev, false
+		}
+	case "su":
+		if m := suOpenedRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.TargetUser, ev.User = "su", m[1], m[2]
+		} else if m := suFailedRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.User, ev.TargetUser = "su_failed", m[1], m[2]
+		} else if m := suLegacyRe.FindStringSubmatch(msg); m != nil {
+			ev.Type, ev.TargetUser, ev.User, ev.TTY = "su", m[2], m[3], m[4]
+			if m[1] == "FAILED SU" {
+				ev.Type = "su_failed"
+			}
+		} else {
+			return ev, false
+		}
+	default:
+		return ev, false
+	}
+	return ev, true
+}
+
+// securityMonitor extracts events from auth sources, detects brute-force
+// attempts and queues events until the backend accepts them.
+type securityMonitor struct {
+	threshold int
+	window    time.Duration
+
+	mu       sync.Mutex
+	failures map[string][]time.Time // source IP -> failed login times within the window
+	alerted  map[string]time.Time   // source IP -> last brute_force event
+	pending  []SecurityEvent
+	journal  *journalState
+	tails    *fileTailer // separate from the file source so a monitored auth.log is not split between readers
+	backend  string      // "journal" or "files", chosen on the first collect
+	redact   func(fields ...*string)
+}
+
+// newSecurityMonitor validates the security section of the rules file.
+func newSecurityMonitor(cfg SecurityConfig) (*securityMonitor, error) {
+	m := &securityMonitor{
+		threshold: cfg.BruteForceThreshold,
+		window:    defaultBruteForceWindow,
+		failures:  make(map[string][]time.Time),
+		alerted:   make(map[string]time.Time),
+		journal:   &journalState{},
+		tails:     &fileTailer{cursors: make(map[string]*fileCursor)},
+		redact:    func(...*string) {},
+	}
+	if m.threshold <= 0 {
+		m.threshold = defaultBruteForceThreshold
+	}
+	if cfg.BruteForceWindow != "" {
+		d, err := time.ParseDuration(cfg.BruteForceWindow)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("security: invalid brute_force_window %q", cfg.BruteForceWindow)
+		}
+		m.window = d
+	}
+	return m, nil
+}
+
+// observe extracts events from entries and queues them, adding a brute_force
+// event when one source exceeds the failure threshold within the window.
+func (m *securityMonitor) observe(entries []LogEntry) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	for _, e := range entries {
+		ev, ok := parseSecurityEvent(e)
+		if !ok {
+			continue
+		}
+		m.queue(ev)
+		if ev.Type != "login_failed" || ev.SourceIP == "" {
+			continue
+		}
+
+		at, err := time.Parse(time.RFC3339Nano, ev.Timestamp)
+		if err != nil {
+			at = time.Now()
+		}
+		times := append(m.failures[ev.SourceIP], at)
+		cutoff := at.Add(-m.window)
+		for len(times) > 0 && times[0].Before(cutoff) {
+			times = times[1:]
+		}
+		m.failures[ev.SourceIP] = times
+
+		// Alert once per window per source
+		if len(times) > m.threshold && at.Sub(m.alerted[ev.SourceIP]) >= m.window {
+			m.alerted[ev.SourceIP] = at
+			m.queue(SecurityEvent{
+				Timestamp: ev.Timestamp,
+				Type:      "brute_force",
+				Host:      ev.Host,
+				Service:   ev.Service,
+				SourceIP:  ev.SourceIP,
+				User:      ev.User,
+				Count:     len(times),
+				Message:   fmt.Sprintf("%d failed logins from %s within %s", len(times), ev.SourceIP, m.window),
+			})
+		}
+	}
+
+	// Forget idle sources so the maps do not grow with every scanner on the internet
+	now := time.Now()
+	for ip, times := range m.failures {
+		if len(times) == 0 || now.Sub(times[len(times)-1]) > m.window {
+			delete(m.failures, ip)
+		}
+	}
+	for ip, at := range m.alerted {
+		if now.Sub(at) > m.window {
+			delete(m.alerted, ip)
+		}
+	}
+}
+
+// queue redacts and appends an event, dropping the oldest beyond
+// maxPendingSecurityEvents. Callers must hold m.mu.
+func (m *securityMonitor) queue(ev SecurityEvent) {
+	m.redact(&ev.Message, &ev.Command)
+	m.pending = append(m.pending, ev)
+	if len(m.pending) > maxPendingSecurityEvents {
+		m.pending = m.pending[len(m.pending)-maxPendingSecurityEvents:]
+	}
+}
+
+// take returns and clears the queued events.
+func (m *securityMonitor) take() []SecurityEvent {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	events := m.pending
+	m.pending = nil
+	return events
+}
+
+// restore puts events back in front of newer ones after a failed send.
+func (m *securityMonitor) restore(events []SecurityEvent) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.pending = append(events, m.pending...)
+	if len(m.pending) > maxPendingSecurityEvents {
+		m.pending = m.pending[len(m.pending)-maxPendingSecurityEvents:]
+	}
+}
+
+// collect reads new sshd/sudo/su entries from the journal, or from the auth
+// log files when the journal is not readable. The choice is made once: on
+// hosts running both journald and rsyslog every event is in both, and
+// reading both would count it twice.
+func (m *securityMonitor) collect(since time.Duration) []LogEntry {
+	if m.backend == "" {
+		m.backend = "files"
+		if journalReadable() {
+			m.backend = "journal"
+		}
+	}
+	if m.backend == "journal" {
+		entries, _ := readJournal(m.journal, since,
+			"SYSLOG_IDENTIFIER=sshd", "SYSLOG_IDENTIFIER=sshd-session",
+			"SYSLOG_IDENTIFIER=sudo", "SYSLOG_IDENTIFIER=su")
+		return entries
+	}
+
+	var entries []LogEntry
+	now := time.Now()
+	for _, path := range authLogFiles {
+		if _, err := os.Stat(path); err != nil {
+			continue
+		}
+		lines, err := m.tails.readNewLines(path, 0)
+		if err != nil {
+			continue
+		}
+		for _, line := range lines {
+			if e, ok := parseSyslogMessage(line, now); ok {
+				entries = append(entries, e)
+			}
+		}
+	}
+	return entries
+}
+
+// sendSecurityEventsToBackend collects auth events and ships the queue.
+func sendSecurityEventsToBackend(client *http.Client, cfg Config, logger *log.Logger, since time.Duration) {
+	m := cfg.Security
+	m.observe(m.collect(since))
+
+	events := m.take()
+	if len(events) == 0 {
+		return
+	}
+	if err := sendSecurityEvents(client, cfg, SecurityEventPayload{Events: events}); err != nil {
+		m.restore(events)
+		logger.Printf("security events ingest failed: %v", err)
+	} else {
+		logger.Printf("security events sent: %d", len(events))
+	}
+}
+
+// sendSecurityEvents sends security events to backend
+func sendSecurityEvents(client *http.Client, cfg Config, payload SecurityEventPayload) error {
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return err
+	}
+
+	url := cfg.BaseURL + "/api/ingest/server-security-events"
+	req, err := http.NewRequest("POST", url, bytes.NewBuffer(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Agent-Token", cfg.Token)
+
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode >= 400 {
+		return fmt.Errorf("server returned %d", resp.StatusCode)
+	}
+	return nil
+}
@@ -0,0 +1,199 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// hwmonInputRe matches the hwmon attributes collected: temperatures in
+// millidegrees Celsius, fan speeds in RPM and voltages in millivolts.
+var hwmonInputRe = regexp.MustCompile(`^(temp|fan|in)(\d+)_input$`)
+
+// hwmonTypes maps an hwmon attribute prefix to the reported sensor type and
+// the divisor that converts its raw value to °C, RPM or V.
+var hwmonTypes = map[string]struct {
+	name    string
+	divisor float64
+}{
+	"temp": {"temperature", 1000},
+	"fan":  {"fan", 1},
+	"in":   {"voltage", 1000},
+}
+
+// SensorMetric is one hardware sensor reading. Values are in °C for
+// temperatures, RPM for fans and volts for voltages.
+type SensorMetric struct {
+	Chip     string   `json:"chip"`   // hwmon driver (coretemp, nct6775, ...) or "thermal"
+	Device   string   `json:"device"` // hwmon0, thermal_zone0, ...
+	Sensor   string   `json:"sensor"` // temp1, fan2, in0; "temp" for thermal zones
+	Label    string   `json:"label,omitempty"`
+	Type     string   `json:"type"` // temperature, fan or voltage
+	Value    float64  `json:"value"`
+	Low      *float64 `json:"low,omitempty"`  // minimum for fans and voltages
+	High     *float64 `json:"high,omitempty"` // max or "hot" trip point
+	Critical *float64 `json:"critical,omitempty"`
+	Alarm    bool     `json:"alarm,omitempty"` // the chip flagged a threshold breach
+}
+
+// readSensors reads /sys/class/hwmon and /sys/class/thermal. Missing classes
+// and unreadable sensors are skipped, so VMs simply report none.
+func readSensors() []SensorMetric {
+	sensors := readHwmonSensors()
+	return append(sensors, readThermalZones()...)
+}
+
+func readHwmonSensors() []SensorMetric {
+	var sensors []SensorMetric
+	for _, device := range sysClassDevices("hwmon") {
+		dir := filepath.Join(sysRoot, "class", "hwmon", device)
+		// Drivers before Linux 3.x put their attributes under device/
+		if _, err := os.Stat(filepath.Join(dir, "name")); err != nil {
+			dir = filepath.Join(dir, "device")
+		}
+		chip := readSysString(filepath.Join(dir, "name"))
+		if chip == "" {
+			continue
+		}
+		entries, err := os.ReadDir(dir)
+		if err != nil {
+			continue
+		}
+
+		type input struct {
+			prefix string
+			index  int
+		}
+		var inputs []input
+		for _, entry := range entries {
+			if m := hwmonInputRe.FindStringSubmatch(entry.Name()); m != nil {
+				index, _ := strconv.Atoi(m[2])
+				inputs = append(inputs, input{m[1], index})
+			}
+		}
+		sort.Slice(inputs, func(i, j int) bool {
+			if inputs[i].prefix != inputs[j].prefix {
+				return inputs[i].prefix > inputs[j].prefix // temp, in, fan
+			}
+			return inputs[i].index < inputs[j].index
+		})
+
+		for _, in := range inputs {
+			typ := hwmonTypes[in.prefix]
+			sensor := in.prefix + strconv.Itoa(in.index)
+			attr := func(suffix string) *float64 {
+				return readSysScaled(filepath.Join(dir, sensor+"_"+suffix), typ.divisor)
+			}
+			value := attr("input")
+			if value == nil {
+				continue // EIO or ENODATA from a sensor that is not wired up
+			}
+			s := SensorMetric{
+				Chip:     chip,
+				Device:   device,
+				Sensor:   sensor,
+				Label:    readSysString(filepath.Join(dir, sensor+"_label")),
+				Type:     typ.name,
+				Value:    *value,
+				Low:      attr("min"),
+				High:     attr("max"),
+				Critical: attr("crit"),
+			}
+			for _, alarm := range []string{"alarm", "min_alarm", "max_alarm", "crit_alarm"} {
+				if readSysString(filepath.Join(dir, sensor+"_"+alarm)) == "1" {
+					s.Alarm = true
+				}
+			}
+			sensors = append(sensors, s)
+		}
+	}
+	return sensors
+}
+
+func readThermalZones() []SensorMetric {
+	var sensors []SensorMetric
+	for _, device := range sysClassDevices("thermal") {
+		if !strings.HasPrefix(device, "thermal_zone") {
+			continue // cooling_device*
+		}
+		dir := filepath.Join(sysRoot, "class", "thermal", device)
+		value := readSysScaled(filepath.Join(dir, "temp"), 1000)
+		if value == nil {
+			continue
+		}
+		s := SensorMetric{
+			Chip:   "thermal",
+			Device: device,
+			Sensor: "temp",
+			Label:  readSysString(filepath.Join(dir, "type")),
+			Type:   "temperature",
+			Value:  *value,
+		}
+		// Trip points are numbered from 0; the lowest "hot" and "critical" ones count
+		for i := 0; ; i++ {
+			prefix := filepath.Join(dir, "trip_point_"+strconv.Itoa(i))
+			kind := readSysString(prefix + "_type")
+			if kind == "" {
+				break
+			}
+			temp := readSysScaled(prefix+"_temp", 1000)
+			if temp == nil || *temp <= 0 {
+				continue // disabled trip point
+			}
+			switch {
+			case kind == "critical" && (s.Critical == nil || *temp < *s.Critical):
+				s.Critical = temp
+			case kind == "hot" && (s.High == nil || *temp < *s.High):
+				s.High = temp
+			}
+		}
+		sensors = append(sensors, s)
+	}
+	return sensors
+}
+
+// sysClassDevices lists /sys/class/<class> sorted naturally (hwmon2 before hwmon10).
+func sysClassDevices(class string) []string {
+	entries, err := os.ReadDir(filepath.Join(sysRoot, "class", class))
+	if err != nil {
+		return nil
+	}
+	names := make([]string, 0, len(entries))
+	for _, entry := range entries {
+		names = append(names, entry.Name())
+	}
+	sort.Slice(names, func(i, j int) bool {
+		a, b := strings.TrimRight(names[i], "0123456789"), strings.TrimRight(names[j], "0123456789")
+		if a != b || len(names[i]) == len(names[j]) {
+			return names[i] < names[j]
+		}
+		return len(names[i]) < len(names[j])
+	})
+	return names
+}
+
+func readSysString(path string) string {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return ""
+	}
+	return strings.TrimSpace(string(data))
+}
+
+// readSysScaled reads an integer attribute and divides it by divisor, or
+// returns nil when the attribute is missing or unreadable.
+func readSysScaled(path string, divisor float64) *float64 {
+	raw := readSysString(path)
+	if raw == "" {
+		return nil
+	}
+	v, err := strconv.ParseInt(raw, 10, 64)
+	if err != nil {
+		return nil
+	}
+	scaled := float64(v) / divisor
+	return &scaled
+}
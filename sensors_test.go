@@ -0,0 +1,100 @@
+package main
+
+import "testing"
+
+func TestReadSensors(t *testing.T) {
+	withSysRoot(t, map[string]string{
+		"class/hwmon/hwmon0/name":               "coretemp\n",
+		"class/hwmon/hwmon0/temp1_input":        "54000\n",
+		"class/hwmon/hwmon0/temp1_label":        "Package id 0\n",
+		"class/hwmon/hwmon0/temp1_max":          "84000\n",
+		"class/hwmon/hwmon0/temp1_crit":         "100000\n",
+		"class/hwmon/hwmon0/temp1_crit_alarm":   "0\n",
+		"class/hwmon/hwmon0/temp10_input":       "91000\n",
+		"class/hwmon/hwmon0/temp10_crit_alarm":  "1\n",
+		"class/hwmon/hwmon0/temp2_input":        "48500\n",
+		"class/hwmon/hwmon10/name":              "nct6775\n",
+		"class/hwmon/hwmon10/fan1_input":        "1200\n",
+		"class/hwmon/hwmon10/fan1_label":        "CPU Fan\n",
+		"class/hwmon/hwmon10/fan1_min":          "300\n",
+		"class/hwmon/hwmon10/in0_input":         "1184\n",
+		"class/hwmon/hwmon10/in0_min":           "1000\n",
+		"class/hwmon/hwmon10/in0_max":           "1400\n",
+		"class/hwmon/hwmon10/temp3_input":       "",
+		"class/hwmon/hwmon2/device/name":        "legacy\n",
+		"class/hwmon/hwmon2/device/temp1_input": "30000\n",
+
+		"class/thermal/thermal_zone0/type":              "x86_pkg_temp\n",
+		"class/thermal/thermal_zone0/temp":              "55000\n",
+		"class/thermal/thermal_zone0/trip_point_0_type": "passive\n",
+		"class/thermal/thermal_zone0/trip_point_0_temp": "80000\n",
+		"class/thermal/thermal_zone0/trip_point_1_type": "critical\n",
+		"class/thermal/thermal_zone0/trip_point_1_temp": "105000\n",
+		"class/thermal/thermal_zone0/trip_point_2_type": "hot\n",
+		"class/thermal/thermal_zone0/trip_point_2_temp": "95000\n",
+		"class/thermal/thermal_zone1/type":              "acpitz\n", // no readable temp, as on some ACPI firmware
+		"class/thermal/cooling_device0/type":            "Processor\n",
+	})
+	sensors := readSensors()
+	type key struct{ device, sensor string }
+	got := make(map[key]SensorMetric)
+	var order []key
+	for _, s := range sensors {
+		k := key{s.Device, s.Sensor}
+		got[k] = s
+		order = append(order, k)
+	}
+
+	expectOrder := []key{
+		{"hwmon0", "temp1"}, {"hwmon0", "temp2"}, {"hwmon0", "temp10"},
+		{"hwmon2", "temp1"},
+		{"hwmon10", "in0"}, {"hwmon10", "fan1"},
+		{"thermal_zone0", "temp"},
+	}
+	if len(order) != len(expectOrder) {
+		t.Fatalf("expected %d sensors, got %v", len(expectOrder), order)
+	}
+	for i := range expectOrder {
+		if order[i] != expectOrder[i] {
+			t.Errorf("sensor %d = %v, expected %v", i, order[i], expectOrder[i])
+		}
+	}
+
+	pkg := got[key{"hwmon0", "temp1"}]
+	if pkg.Chip != "coretemp" || pkg.Label != "Package id 0" || pkg.Type != "temperature" || pkg.Value != 54 {
+		t.Errorf("unexpected package temperature: %+v", pkg)
+	}
+	if pkg.High == nil || *pkg.High != 84 || pkg.Critical == nil || *pkg.Critical != 100 || pkg.Alarm {
+		t.Errorf("unexpected package thresholds: %+v", pkg)
+	}
+	if core := got[key{"hwmon0", "temp10"}]; !core.Alarm || core.Critical != nil {
+		t.Errorf("expected alarm without threshold, got %+v", core)
+	}
+	if legacy := got[key{"hwmon2", "temp1"}]; legacy.Chip != "legacy" || legacy.Value != 30 {
+		t.Errorf("unexpected legacy hwmon sensor: %+v", legacy)
+	}
+
+	fan := got[key{"hwmon10", "fan1"}]
+	if fan.Type != "fan" || fan.Value != 1200 || fan.Label != "CPU Fan" || fan.Low == nil || *fan.Low != 300 {
+		t.Errorf("unexpected fan: %+v", fan)
+	}
+	volt := got[key{"hwmon10", "in0"}]
+	if volt.Type != "voltage" || volt.Value != 1.184 || *volt.Low != 1 || *volt.High != 1.4 {
+		t.Errorf("unexpected voltage: %+v", volt)
+	}
+
+	zone := got[key{"thermal_zone0", "temp"}]
+	if zone.Chip != "thermal" || zone.Label != "x86_pkg_temp" || zone.Value != 55 {
+		t.Errorf("unexpected thermal zone: %+v", zone)
+	}
+	if zone.Critical == nil || *zone.Critical != 105 || zone.High == nil || *zone.High != 95 {
+		t.Errorf("unexpected trip points: %+v", zone)
+	}
+}
+
+func TestReadSensors_None(t *testing.T) {
+	withSysRoot(t, map[string]string{})
+	if sensors := readSensors(); len(sensors) != 0 {
+		t.Errorf("expected no sensors without hwmon or thermal, got %+v", sensors)
+	}
+}
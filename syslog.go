@@ -0,0 +1,523 @@
+package main
+
+import (
+	"bufio"
+	"errors"
+	"io"
+	"log"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// maxSyslogBuffer bounds how many received messages are held between flushes.
+// When full, the oldest entries are discarded and counted as dropped.
+const maxSyslogBuffer = 5000
+
+// maxSyslogMessage is the largest single message accepted (RFC5425 suggests 8 KiB
+// minimum; we allow more for verbose appliances). Longer messages are truncated.
+const maxSyslogMessage = 64 * 1024
+
+// maxSyslogOctetDigits bounds the length prefix of an octet-counted frame.
+const maxSyslogOctetDigits = 7
+
+// Limits for TCP senders: at most maxSyslogConns connections are served at
+// once, and a connection that sends no frame for syslogReadTimeout is closed.
+const (
+	maxSyslogConns    = 64
+	syslogReadTimeout = 5 * time.Minute
+)
+
+// defaultSyslogUnixMode lets root and the socket's group write to the unix
+// socket, so other local users cannot inject system log lines.
+const defaultSyslogUnixMode os.FileMode = 0o660
+
+// syslogReceiver listens for syslog messages over UDP, TCP and/or a Unix
+// datagram socket and buffers them as LogEntry values until the next flush.
+type syslogReceiver struct {
+	logger *log.Logger
+
+	mu        sync.Mutex
+	buffer    []LogEntry
+	dropped   map[string]int // per source key ("syslog/udp", ...)
+	listeners []io.Closer
+	wg        sync.WaitGroup
+}
+
+// startSyslogReceiver opens every configured listener. It returns nil when no
+// syslog address is configured.
+func startSyslogReceiver(cfg Config, logger *log.Logger) (*syslogReceiver, error) {
+	if cfg.SyslogUDP == "" && cfg.SyslogTCP == "" && cfg.SyslogUnix == "" {
+		return nil, nil
+	}
+
+	r := &syslogReceiver{logger: logger}
+
+	if cfg.SyslogUDP != "" {
+		conn, err := net.ListenPacket("udp", cfg.SyslogUDP)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.track(conn)
+		r.wg.Add(1)
+		go r.servePacket(conn, "udp")
+		logger.Printf("syslog receiver listening on udp %s", conn.LocalAddr())
+	}
+
+	if cfg.SyslogTCP != "" {
+		ln, err := net.Listen("tcp", cfg.SyslogTCP)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		r.track(ln)
+		r.wg.Add(1)
+		go r.serveStream(ln)
+		logger.Printf("syslog receiver listening on tcp %s", ln.Addr())
+	}
+
+	if cfg.SyslogUnix != "" {
+		// Remove a stale socket left by a previous run
+		_ = os.Remove(cfg.SyslogUnix)
+		conn, err := net.ListenPacket("unixgram", cfg.SyslogUnix)
+		if err != nil {
+			r.close()
+			return nil, err
+		}
+		mode := cfg.SyslogUnixMode
+		if mode == 0 {
+			mode = defaultSyslogUnixMode
+		}
+		_ = os.Chmod(cfg.SyslogUnix, mode)
+		r.track(conn)
+		r.wg.Add(1)
+		go r.servePacket(conn, "unix")
+		logger.Printf("syslog receiver listening on unix %s", cfg.SyslogUnix)
+	}
+
+	return r, nil
+}
+
+func (r *syslogReceiver) track(c io.Closer) {
+	r.mu.Lock()
+	r.listeners = append(r.listeners, c)
+	r.mu.Unlock()
+}
+
+// close shuts down every listener and waits for the serving goroutines to exit.
+func (r *syslogReceiver) close() {
+	if r == nil {
+		return
+	}
+	r.mu.Lock()
+	listeners := r.listeners
+	r.listeners = nil
+	r.mu.Unlock()
+
+	for _, l := range listeners {
+		_ = l.Close()
+	}
+	r.wg.Wait()
+}
+
+func (r *syslogReceiver) servePacket(conn net.PacketConn, transport string) {
+	defer r.wg.Done()
+	buf := make([]byte, maxSyslogMessage)
+	for {
+		n, addr, err := conn.ReadFrom(buf)
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logger.Printf("syslog %s read error: %v", transport, err)
+			continue
+		}
+		remote := ""
+		if addr != nil {
+			remote = addr.String()
+		}
+		r.handle(buf[:n], transport, remote)
+	}
+}
+
+func (r *syslogReceiver) serveStream(ln net.Listener) {
+	defer r.wg.Done()
+	slots := make(chan struct{}, maxSyslogConns)
+	for {
+		conn, err := ln.Accept()
+		if err != nil {
+			if errors.Is(err, net.ErrClosed) {
+				return
+			}
+			r.logger.Printf("syslog tcp accept error: %v", err)
+			continue
+		}
+		select {
+		case slots <- struct{}{}:
+		default:
+			r.logger.Printf("syslog tcp %s: refused, %d connections open", conn.RemoteAddr(), maxSyslogConns)
+			conn.Close()
+			continue
+		}
+		r.wg.Add(1)
+		go func() {
+			defer r.wg.Done()
+			defer func() { <-slots }()
+			defer conn.Close()
+			remote := conn.RemoteAddr().String()
+			err := readSyslogFrames(conn, func(frame []byte) {
+				r.handle(frame, "tcp", remote)
+			})
+			if err != nil && !errors.Is(err, net.ErrClosed) {
+				r.logger.Printf("syslog tcp %s: %v", remote, err)
+			}
+		}()
+	}
+}
+
+// readSyslogFrames splits a TCP stream into messages. Both RFC6587 framings are
+// supported: octet-counting ("<len> <msg>") and newline-delimited. Frames
+// longer than maxSyslogMessage are truncated. When rd is a connection, each
+// frame must arrive within syslogReadTimeout.
+func readSyslogFrames(rd io.Reader, fn func([]byte)) error {
+	conn, _ := rd.(interface{ SetReadDeadline(time.Time) error })
+	br := bufio.NewReaderSize(rd, maxSyslogMessage)
+	for {
+		if conn != nil {
+			if err := conn.SetReadDeadline(time.Now().Add(syslogReadTimeout)); err != nil {
+				return err
+			}
+		}
+		if _, err := br.Peek(1); err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+
+		if n, prefix, ok := peekOctetCount(br); ok {
+			br.Discard(prefix)
+			frame := make([]byte, min(n, maxSyslogMessage))
+			if _, err := io.ReadFull(br, frame); err != nil {
+				return err
+			}
+			if _, err := io.CopyN(io.Discard, br, int64(n-len(frame))); err != nil {
+				return err
+			}
+			fn(frame)
+			continue
+		}
+
+		line, err := br.ReadSlice('\n')
+		if len(line) > 0 {
+			fn(line)
+		}
+		// Skip the rest of an oversize line
+		for err == bufio.ErrBufferFull {
+			_, err = br.ReadSlice('\n')
+		}
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+// peekOctetCount looks for the "<len> <" prefix of an octet-counted frame and
+// returns the frame length and the size of the "<len> " prefix. Lines that
+// merely start with a digit are left to newline framing.
+func peekOctetCount(br *bufio.Reader) (n, prefix int, ok bool) {
+	for i := 1; i <= maxSyslogOctetDigits+1; i++ {
+		b, err := br.Peek(i)
+		if err != nil {
+			return 0, 0, false
+		}
+		c := b[i-1]
+		switch {
+		case c >= '0' && c <= '9' && i <= maxSyslogOctetDigits && !(i == 1 && c == '0'):
+			continue
+		case c == ' ' && i > 1:
+			next, err := br.Peek(i + 1)
+			if err != nil || next[i] != '<' {
+				return 0, 0, false
+			}
+			n, _ := strconv.Atoi(string(b[:i-1]))
+			return n, i, true
+		}
+		return 0, 0, false
+	}
+	return 0, 0, false
+}
+
+// handle parses one message and appends it to the buffer.
+func (r *syslogReceiver) handle(data []byte, transport, remote string) {
+	entry, ok := parseSyslogMessage(string(data), time.Now())
+	if !ok {
+		return
+	}
+	// Skip our own messages if the host forwards its syslog back to us
+	if entry.Service == serviceName {
+		return
+	}
+	if entry.Host == "" {
+		entry.Host = remoteHost(remote)
+	}
+	entry.Source = "syslog/" + transport
+	if remote != "" {
+		if entry.Attributes == nil {
+			entry.Attributes = map[string]string{}
+		}
+		entry.Attributes["remote_addr"] = remote
+	}
+
+	r.mu.Lock()
+	if len(r.buffer) >= maxSyslogBuffer {
+		if r.dropped == nil {
+			r.dropped = make(map[string]int)
+		}
+		r.dropped[logSourceKey(r.buffer[0])]++
+		r.buffer = r.buffer[1:]
+	}
+	r.buffer = append(r.buffer, entry)
+	r.mu.Unlock()
+}
+
+// drain returns all buffered entries and how many were dropped per source
+// since the last drain.
+func (r *syslogReceiver) drain() ([]LogEntry, map[string]int) {
+	if r == nil {
+		return nil, nil
+	}
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	entries := r.buffer
+	dropped := r.dropped
+	r.buffer = nil
+	r.dropped = nil
+	return entries, dropped
+}
+
+func remoteHost(remote string) string {
+	if host, _, err := net.SplitHostPort(remote); err == nil {
+		return host
+	}
+	return remote
+}
+
+// parseSyslogMessage parses an RFC5424 or RFC3164 message into a LogEntry.
+// now supplies the year for RFC3164 timestamps and the fallback timestamp.
+func parseSyslogMessage(msg string, now time.Time) (LogEntry, bool) {
+	msg = strings.TrimRight(msg, "\r\n\x00")
+	if msg == "" {
+		return LogEntry{}, false
+	}
+
+	entry := LogEntry{
+		Level:      "info",
+		Service:    "syslog",
+		Attributes: map[string]string{},
+	}
+
+	rest := msg
+	if strings.HasPrefix(rest, "<") {
+		end := strings.IndexByte(rest, '>')
+		if end > 1 && end <= 4 {
+			if pri, err := strconv.Atoi(rest[1:end]); err == nil && pri >= 0 && pri <= 191 {
+				entry.Level = mapJournalPriority(strconv.Itoa(pri % 8))
+				entry.Attributes["facility"] = syslogFacilityName(pri / 8)
+				rest = rest[end+1:]
+			}
+		}
+	}
+
+	if len(rest) >= 2 && rest[0] >= '1' && rest[0] <= '9' && rest[1] == ' ' {
+		parseRFC5424(rest[2:], &entry)
+	} else {
+		parseRFC3164(rest, &entry, now)
+	}
+
+	if entry.Timestamp == "" {
+		entry.Timestamp = now.UTC().Format(time.RFC3339Nano)
+	}
+	if len(entry.Attributes) == 0 {
+		entry.Attributes = nil
+	}
+	return entry, true
+}
+
+// parseRFC5424 parses "TIMESTAMP HOSTNAME APP-NAME PROCID MSGID SD MSG".
+func parseRFC5424(s string, entry *LogEntry) {
+	fields := make([]string, 5)
+	for i := range fields {
+		fields[i], s, _ = strings.Cut(s, " ")
+	}
+
+	if ts, err := time.Parse(time.RFC3339Nano, fields[0]); err == nil {
+		entry.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+	}
+	if fields[1] != "-" {
+		entry.Host = fields[1]
+	}
+	if fields[2] != "-" && fields[2] != "" {
+		entry.Service = fields[2]
+	}
+	if fields[3] != "-" && fields[3] != "" {
+		entry.Attributes["procid"] = fields[3]
+	}
+	if fields[4] != "-" && fields[4] != "" {
+		entry.Attributes["msgid"] = fields[4]
+	}
+
+	if strings.HasPrefix(s, "-") {
+		s = strings.TrimPrefix(s[1:], " ")
+	} else if strings.HasPrefix(s, "[") {
+		s = parseStructuredData(s, entry.Attributes)
+	}
+
+	entry.Message = strings.TrimPrefix(s, "\ufeff") // UTF-8 BOM
+}
+
+// parseStructuredData consumes one or more "[id key="value" ...]" elements,
+// storing params as "sd.<id>.<key>" attributes, and returns the remaining text.
+func parseStructuredData(s string, attrs map[string]string) string {
+	for strings.HasPrefix(s, "[") {
+		i := 1
+		idStart := i
+		for i < len(s) && s[i] != ' ' && s[i] != ']' {
+			i++
+		}
+		id := s[idStart:i]
+		attrs["sd."+id] = ""
+
+		for i < len(s) && s[i] != ']' {
+			for i < len(s) && s[i] == ' ' {
+				i++
+			}
+			keyStart := i
+			for i < len(s) && s[i] != '=' && s[i] != ']' {
+				i++
+			}
+			if i >= len(s) || s[i] == ']' {
+				break
+			}
+			key := s[keyStart:i]
+			i++ // '='
+			if i >= len(s) || s[i] != '"' {
+				break
+			}
+			i++
+			var val strings.Builder
+			for i < len(s) && s[i] != '"' {
+				if s[i] == '\\' && i+1 < len(s) && (s[i+1] == '"' || s[i+1] == '\\' || s[i+1] == ']') {
+					i++
+				}
+				val.WriteByte(s[i])
+				i++
+			}
+			i++ // closing quote
+			delete(attrs, "sd."+id)
+			attrs["sd."+id+"."+key] = val.String()
+		}
+		if i >= len(s) {
+			return ""
+		}
+		s = s[i+1:]
+	}
+	return strings.TrimPrefix(s, " ")
+}
+
+// parseRFC3164 parses "Mmm dd hh:mm:ss HOST TAG[pid]: MSG". The hostname is
+// optional, as many devices omit it when sending to a remote collector. An
+// RFC3339 timestamp in place of the BSD one, as written by rsyslog's
+// high-precision file format (the Debian 12 default), is accepted too.
+func parseRFC3164(s string, entry *LogEntry, now time.Time) {
+	if first, rest, found := strings.Cut(s, " "); found && len(first) > 10 && first[4] == '-' {
+		if ts, err := time.Parse(time.RFC3339Nano, first); err == nil {
+			entry.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+			s = rest
+		}
+	} else if len(s) >= 16 && s[3] == ' ' && s[15] == ' ' {
+		if ts, err := time.ParseInLocation(time.Stamp, s[:15], time.Local); err == nil {
+			ts = ts.AddDate(now.Year(), 0, 0)
+			// A December message received in January belongs to last year
+			if ts.After(now.Add(24 * time.Hour)) {
+				ts = ts.AddDate(-1, 0, 0)
+			}
+			entry.Timestamp = ts.UTC().Format(time.RFC3339Nano)
+			s = s[16:]
+		}
+	}
+
+	head, msg, found := strings.Cut(s, ": ")
+	if !found {
+		entry.Message = s
+		return
+	}
+
+	parts := strings.Fields(head)
+	var tag string
+	switch len(parts) {
+	case 1:
+		tag = parts[0]
+	case 2:
+		entry.Host = parts[0]
+		tag = parts[1]
+	default:
+		entry.Message = s
+		return
+	}
+
+	if idx := strings.IndexByte(tag, '['); idx > 0 && strings.HasSuffix(tag, "]") {
+		entry.Attributes["procid"] = tag[idx+1 : len(tag)-1]
+		tag = tag[:idx]
+	}
+	entry.Service = tag
+	entry.Message = msg
+}
+
+var syslogFacilities = []string{
+	"kern", "user", "mail", "daemon", "auth", "syslog", "lpr", "news",
+	"uucp", "cron", "authpriv", "ftp", "ntp", "security", "console", "solaris-cron",
+	"local0", "local1", "local2", "local3", "local4", "local5", "local6", "local7",
+}
+
+func syslogFacilityName(code int) string {
+	if code >= 0 && code < len(syslogFacilities) {
+		return syslogFacilities[code]
+	}
+	return strconv.Itoa(code)
+}
+
+// syslogReceiverSource hands messages received over the network to the log pipeline.
+type syslogReceiverSource struct {
+	r      *syslogReceiver
+	logger *log.Logger
+}
+
+func newSyslogReceiverSource(cfg Config, logger *log.Logger) (logSource, error) {
+	r, err := startSyslogReceiver(cfg, logger)
+	if err != nil || r == nil {
+		return nil, err
+	}
+	return &syslogReceiverSource{r: r, logger: logger}, nil
+}
+
+func (s *syslogReceiverSource) name() string { return "syslog_receiver" }
+
+// collect drains the messages received since the previous call.
+func (s *syslogReceiverSource) collect(time.Duration) ([]LogEntry, error) {
+	entries, dropped := s.r.drain()
+	for source, n := range dropped {
+		logDrops.add(source, n)
+		s.logger.Printf("syslog receiver buffer full: dropped %d oldest messages from %s", n, source)
+	}
+	return entries, nil
+}
+
+func (s *syslogReceiverSource) close() { s.r.close() }
@@ -0,0 +1,234 @@
+package main
+
+import (
+	"io"
+	"log"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// --- parseSyslogMessage Tests ---
+
+func TestParseSyslogMessage_RFC3164(t *testing.T) {
+	now := time.Date(2026, 3, 10, 12, 0, 0, 0, time.Local)
+	entry, ok := parseSyslogMessage("<34>Mar  9 22:14:15 router1 su[123]: 'su root' failed for lonvick on /dev/pts/8", now)
+	if !ok {
+		t.Fatal("expected message to parse")
+	}
+	if entry.Level != "error" {
+		t.Errorf("expected level error (severity 2), got %q", entry.Level)
+	}
+	if entry.Host != "router1" || entry.Service != "su" {
+		t.Errorf("unexpected host/service: %q/%q", entry.Host, entry.Service)
+	}
+	if entry.Attributes["procid"] != "123" || entry.Attributes["facility"] != "auth" {
+		t.Errorf("unexpected attributes: %v", entry.Attributes)
+	}
+	if entry.Message != "'su root' failed for lonvick on /dev/pts/8" {
+		t.Errorf("unexpected message: %q", entry.Message)
+	}
+	expectTS := time.Date(2026, 3, 9, 22, 14, 15, 0, time.Local).UTC().Format(time.RFC3339Nano)
+	if entry.Timestamp != expectTS {
+		t.Errorf("expected timestamp %s, got %s", expectTS, entry.Timestamp)
+	}
+}
+
+func TestParseSyslogMessage_RFC3164NoHostname(t *testing.T) {
+	now := time.Date(2026, 1, 2, 0, 0, 0, 0, time.Local)
+	entry, ok := parseSyslogMessage("<13>Dec 31 23:59:59 myapp: year rollover", now)
+	if !ok {
+		t.Fatal("expected message to parse")
+	}
+	if entry.Host != "" || entry.Service != "myapp" {
+		t.Errorf("unexpected host/service: %q/%q", entry.Host, entry.Service)
+	}
+	if !strings.HasPrefix(entry.Timestamp, "2025-12-31") && !strings.HasPrefix(entry.Timestamp, "2026-01-01") {
+		t.Errorf("expected previous-year timestamp, got %s", entry.Timestamp)
+	}
+}
+
+func TestParseSyslogMessage_RFC5424(t *testing.T) {
+	msg := `<165>1 2026-03-09T22:14:15.003Z mymachine.example.com evntslog - ID47 [exampleSDID@32473 iut="3" eventSource="App\"lication" eventID="1011"][meta seq="7"] An application event`
+	entry, ok := parseSyslogMessage(msg, time.Now())
+	if !ok {
+		t.Fatal("expected message to parse")
+	}
+	if entry.Level != "info" {
+		t.Errorf("expected level info (notice), got %q", entry.Level)
+	}
+	if entry.Host != "mymachine.example.com" || entry.Service != "evntslog" {
+		t.Errorf("unexpected host/service: %q/%q", entry.Host, entry.Service)
+	}
+	if entry.Timestamp != "2026-03-09T22:14:15.003Z" {
+		t.Errorf("unexpected timestamp: %s", entry.Timestamp)
+	}
+	checks := map[string]string{
+		"facility":                         "local4",
+		"msgid":                            "ID47",
+		"sd.exampleSDID@32473.iut":         "3",
+		"sd.exampleSDID@32473.eventSource": `App"lication`,
+		"sd.meta.seq":                      "7",
+	}
+	for key, want := range checks {
+		if got := entry.Attributes[key]; got != want {
+			t.Errorf("attribute %s = %q, expected %q", key, got, want)
+		}
+	}
+	if _, ok := entry.Attributes["procid"]; ok {
+		t.Error("expected nil procid to be omitted")
+	}
+	if entry.Message != "An application event" {
+		t.Errorf("unexpected message: %q", entry.Message)
+	}
+}
+
+func TestParseSyslogMessage_RFC5424NoStructuredData(t *testing.T) {
+	entry, ok := parseSyslogMessage("<11>1 - - app 42 - - \ufeffdisk full", time.Now())
+	if !ok {
+		t.Fatal("expected message to parse")
+	}
+	if entry.Service != "app" || entry.Attributes["procid"] != "42" {
+		t.Errorf("unexpected service/procid: %q/%v", entry.Service, entry.Attributes)
+	}
+	if entry.Message != "disk full" {
+		t.Errorf("expected BOM stripped, got %q", entry.Message)
+	}
+	if entry.Level != "error" {
+		t.Errorf("expected error level, got %q", entry.Level)
+	}
+}
+
+func TestParseSyslogMessage_Empty(t *testing.T) {
+	if _, ok := parseSyslogMessage("\n", time.Now()); ok {
+		t.Error("expected empty message to be rejected")
+	}
+}
+
+// --- readSyslogFrames Tests ---
+
+func TestReadSyslogFrames(t *testing.T) {
+	stream := "<13>first line\n17 <13>octet counted<13>third\n"
+	var frames []string
+	err := readSyslogFrames(strings.NewReader(stream), func(b []byte) {
+		frames = append(frames, strings.TrimRight(string(b), "\n"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	expect := []string{"<13>first line", "<13>octet counted", "<13>third"}
+	if len(frames) != len(expect) {
+		t.Fatalf("expected %d frames, got %d: %q", len(expect), len(frames), frames)
+	}
+	for i := range expect {
+		if frames[i] != expect[i] {
+			t.Errorf("frame %d = %q, expected %q", i, frames[i], expect[i])
+		}
+	}
+}
+
+func TestReadSyslogFrames_DigitLinesAndOversizeFrames(t *testing.T) {
+	long := strings.Repeat("x", maxSyslogMessage+100)
+	stream := "2024-03-01 app started\n5 apples\n" +
+		long + "\n" +
+		strconv.Itoa(len(long)+4) + " <13>" + long +
+		"<13>after\n"
+	var frames []string
+	err := readSyslogFrames(strings.NewReader(stream), func(b []byte) {
+		frames = append(frames, strings.TrimRight(string(b), "\n"))
+	})
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(frames) != 5 {
+		t.Fatalf("expected 5 frames, got %d", len(frames))
+	}
+	if frames[0] != "2024-03-01 app started" || frames[1] != "5 apples" || frames[4] != "<13>after" {
+		t.Errorf("unexpected frames: %q, %q, %q", frames[0], frames[1], frames[4])
+	}
+	if len(frames[2]) != maxSyslogMessage || len(frames[3]) != maxSyslogMessage || !strings.HasPrefix(frames[3], "<13>x") {
+		t.Errorf("expected oversize frames truncated to %d bytes, got %d and %d", maxSyslogMessage, len(frames[2]), len(frames[3]))
+	}
+}
+
+// --- syslogReceiver Tests ---
+
+func TestSyslogReceiver_UDP(t *testing.T) {
+	cfg := Config{SyslogUDP: "127.0.0.1:0"}
+	r, err := startSyslogReceiver(cfg, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.close()
+
+	addr := r.listeners[0].(net.PacketConn).LocalAddr().String()
+	conn, err := net.Dial("udp", addr)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	if _, err := conn.Write([]byte("<14>Mar  1 10:00:00 edge nginx: upstream timed out")); err != nil {
+		t.Fatal(err)
+	}
+
+	deadline := time.Now().Add(2 * time.Second)
+	var entries []LogEntry
+	for time.Now().Before(deadline) {
+		entries, _ = r.drain()
+		if len(entries) > 0 {
+			break
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+	if len(entries) != 1 {
+		t.Fatalf("expected 1 entry, got %d", len(entries))
+	}
+	if entries[0].Source != "syslog/udp" || entries[0].Service != "nginx" {
+		t.Errorf("unexpected entry: %+v", entries[0])
+	}
+}
+
+func TestSyslogReceiver_UnixSocketMode(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "syslog.sock")
+	r, err := startSyslogReceiver(Config{SyslogUnix: path}, log.New(io.Discard, "", 0))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	defer r.close()
+	info, err := os.Stat(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if mode := info.Mode().Perm(); mode != defaultSyslogUnixMode {
+		t.Errorf("expected socket mode %o, got %o", defaultSyslogUnixMode, mode)
+	}
+}
+
+func TestStartSyslogReceiver_Disabled(t *testing.T) {
+	r, err := startSyslogReceiver(Config{}, log.New(io.Discard, "", 0))
+	if err != nil || r != nil {
+		t.Errorf("expected nil receiver when unconfigured, got %v, %v", r, err)
+	}
+}
+
+func TestSyslogReceiver_CountsDropsPerTransport(t *testing.T) {
+	r := &syslogReceiver{logger: log.New(io.Discard, "", 0)}
+	msg := []byte("<14>Mar  1 10:00:00 edge nginx: upstream timed out")
+	for i := 0; i < maxSyslogBuffer; i++ {
+		r.handle(msg, "tcp", "")
+	}
+	r.handle(msg, "udp", "")
+	r.handle(msg, "udp", "")
+
+	entries, dropped := r.drain()
+	if len(entries) != maxSyslogBuffer || len(dropped) != 1 || dropped["syslog/tcp"] != 2 {
+		t.Errorf("expected the 2 oldest tcp messages dropped, got %d entries and %v", len(entries), dropped)
+	}
+	if _, dropped := r.drain(); dropped != nil {
+		t.Errorf("expected drop counts cleared, got %v", dropped)
+	}
+}
@@ -0,0 +1,187 @@
+package main
+
+import (
+	"bytes"
+	"io"
+	"os"
+	"sync"
+	"time"
+)
+
+// maxTailReadBytes bounds how much of a file is read per poll.
+const maxTailReadBytes = 1 << 20
+
+// fileCursor remembers how far a file has been read.
+type fileCursor struct {
+	offset    int64
+	info      os.FileInfo // identity of the file the offset belongs to
+	readAt    time.Time
+	rotations int // replacements and truncations seen since the first read
+}
+
+// fileTailer reads only the lines appended to files since the previous poll,
+// so each line is shipped (and counted) once.
+type fileTailer struct {
+	mu      sync.Mutex
+	cursors map[string]*fileCursor
+}
+
+// readNewLines returns complete lines appended to path since the last call.
+// On first sight of a file it returns up to initialLines trailing lines.
+// A replaced (rotated) or truncated file is re-read from the start; when the
+// previous file can still be found among its rotated siblings, its unread
+// remainder is returned first.
+func (t *fileTailer) readNewLines(path string, initialLines int) ([]string, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+
+	t.mu.Lock()
+	cur := t.cursors[path]
+	t.mu.Unlock()
+
+	if cur == nil {
+		lines, offset, err := readTrailingLines(f, info.Size(), initialLines)
+		if err != nil {
+			return nil, err
+		}
+		t.store(path, &fileCursor{offset: offset, info: info, readAt: time.Now()})
+		return lines, nil
+	}
+
+	var lines []string
+	offset, rotations := cur.offset, cur.rotations
+	if !os.SameFile(cur.info, info) {
+		lines = readRotatedRemainder(path, cur)
+		offset = 0
+		rotations++
+	} else if info.Size() < offset {
+		offset = 0
+		rotations++
+	}
+
+	newLines, consumed, err := readLinesFrom(f, offset, info.Size())
+	if err != nil {
+		return nil, err
+	}
+	t.store(path, &fileCursor{offset: offset + consumed, info: info, readAt: time.Now(), rotations: rotations})
+	return append(lines, newLines...), nil
+}
+
+// cursor returns a copy of the current cursor for path, or nil if unread.
+func (t *fileTailer) cursor(path string) *fileCursor {
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	cur, ok := t.cursors[path]
+	if !ok {
+		return nil
+	}
+	c := *cur
+	return &c
+}
+
+// snapshot copies the cursors of the given paths so a later replay can
+// re-read everything after this point.
+func (t *fileTailer) snapshot(paths []string) map[string]*fileCursor {
+	out := make(map[string]*fileCursor, len(paths))
+	for _, p := range paths {
+		out[p] = t.cursor(p)
+	}
+	return out
+}
+
+// prune forgets the cursors of files that are no longer in keep (unmonitored
+// paths, removed containers).
+func (t *fileTailer) prune(keep []string) {
+	keepSet := make(map[string]bool, len(keep))
+	for _, k := range keep {
+		keepSet[k] = true
+	}
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for path := range t.cursors {
+		if !keepSet[path] {
+			delete(t.cursors, path)
+		}
+	}
+}
+
+func (t *fileTailer) store(path string, cur *fileCursor) {
+	t.mu.Lock()
+	t.cursors[path] = cur
+	t.mu.Unlock()
+}
+
+// readLinesFrom reads complete lines between offset and size (capped at
+// maxTailReadBytes) and returns them with the number of bytes consumed.
+// A trailing partial line is left for the next poll unless the chunk holds
+// no newline at all.
+func readLinesFrom(r io.ReaderAt, offset, size int64) ([]string, int64, error) {
+	n := size - offset
+	if n <= 0 {
+		return nil, 0, nil
+	}
+	if n > maxTailReadBytes {
+		n = maxTailReadBytes
+	}
+
+	buf := make([]byte, n)
+	read, err := r.ReadAt(buf, offset)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	buf = buf[:read]
+
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	if end == 0 {
+		if int64(len(buf)) < maxTailReadBytes {
+			return nil, 0, nil
+		}
+		end = len(buf)
+	}
+	return splitLines(buf[:end]), int64(end), nil
+}
+
+// readTrailingLines returns the last n complete lines of the file and the
+// offset just past them.
+func readTrailingLines(r io.ReaderAt, size int64, n int) ([]string, int64, error) {
+	start := size - maxTailReadBytes
+	if start < 0 {
+		start = 0
+	}
+	buf := make([]byte, size-start)
+	read, err := r.ReadAt(buf, start)
+	if err != nil && err != io.EOF {
+		return nil, 0, err
+	}
+	buf = buf[:read]
+
+	end := bytes.LastIndexByte(buf, '\n') + 1
+	lines := splitLines(buf[:end])
+	// The first line may be cut by the read window
+	if start > 0 && len(lines) > 0 {
+		lines = lines[1:]
+	}
+	if len(lines) > n {
+		lines = lines[len(lines)-n:]
+	}
+	return lines, start + int64(end), nil
+}
+
+func splitLines(buf []byte) []string {
+	var lines []string
+	for _, line := range bytes.Split(buf, []byte{'\n'}) {
+		line = bytes.TrimRight(line, "\r")
+		if len(line) > 0 {
+			lines = append(lines, string(line))
+		}
+	}
+	return lines
+}
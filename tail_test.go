@@ -0,0 +1,87 @@
+package main
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func appendFile(t *testing.T, path, content string) {
+	t.Helper()
+	f, err := os.OpenFile(path, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if _, err := f.WriteString(content); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestFileTailer_ReadsOnlyNewLines(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "app.log")
+	appendFile(t, path, "one\ntwo\nthree\n")
+
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	lines, err := tailer.readNewLines(path, 2)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(lines) != 2 || lines[0] != "two" || lines[1] != "three" {
+		t.Errorf("expected last 2 lines on first read, got %q", lines)
+	}
+
+	if lines, _ := tailer.readNewLines(path, 2); len(lines) != 0 {
+		t.Errorf("expected no lines without new writes, got %q", lines)
+	}
+
+	appendFile(t, path, "four\nfive-partial")
+	lines, _ = tailer.readNewLines(path, 2)
+	if len(lines) != 1 || lines[0] != "four" {
+		t.Errorf("expected only the complete new line, got %q", lines)
+	}
+
+	appendFile(t, path, "\n")
+	lines, _ = tailer.readNewLines(path, 2)
+	if len(lines) != 1 || lines[0] != "five-partial" {
+		t.Errorf("expected partial line once completed, got %q", lines)
+	}
+}
+
+func TestFileTailer_RotationAndTruncation(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "app.log")
+	appendFile(t, path, "old-1\nold-2\n")
+
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	if _, err := tailer.readNewLines(path, 10); err != nil {
+		t.Fatal(err)
+	}
+
+	// Rotate: move aside and recreate
+	if err := os.Rename(path, path+".1"); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "new-1\n")
+	lines, _ := tailer.readNewLines(path, 10)
+	if len(lines) != 1 || lines[0] != "new-1" {
+		t.Errorf("expected new file read from start, got %q", lines)
+	}
+
+	// Truncate in place (copytruncate)
+	if err := os.Truncate(path, 0); err != nil {
+		t.Fatal(err)
+	}
+	appendFile(t, path, "x\n")
+	lines, _ = tailer.readNewLines(path, 10)
+	if len(lines) != 1 || lines[0] != "x" {
+		t.Errorf("expected truncated file read from start, got %q", lines)
+	}
+}
+
+func TestFileTailer_MissingFile(t *testing.T) {
+	tailer := &fileTailer{cursors: make(map[string]*fileCursor)}
+	if _, err := tailer.readNewLines(filepath.Join(t.TempDir(), "nope.log"), 10); err == nil {
+		t.Error("expected error for missing file")
+	}
+}
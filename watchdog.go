@@ -7,6 +7,7 @@ import (
 	"fmt"
 	"log"
 	"net/http"
+	"slices"
 	"sort"
 	"sync"
 	"time"
@@ -21,6 +22,9 @@ type WatchdogEntry struct {
 	RestartCount int     `json:"restart_count"`
 	LastSeenAt   string  `json:"last_seen_at"`
 	PIDs         []int32 `json:"pids"`
+	// Reason explains a crash or restart when known, e.g. "oom_killed".
+	Reason  string       `json:"reason,omitempty"`
+	OOMKill *KernelEvent `json:"oom_kill,omitempty"`
 }
 
 // WatchdogPayload is sent to the backend.
@@ -76,13 +80,17 @@ func collectWatchdog() ([]WatchdogEntry, error) {
 		pids, exists := current[name]
 		if !exists {
 			// Process crashed — was running before but gone now
-			entries = append(entries, WatchdogEntry{
+			entry := WatchdogEntry{
 				Name:         name,
 				Status:       "crashed",
 				RestartCount: 0,
 				LastSeenAt:   prev.LastSeenAt.Format(time.RFC3339),
 				PIDs:         nil,
-			})
+			}
+			if kill := oomKills.match(name, prev.PIDs); kill != nil {
+				entry.Reason, entry.OOMKill = "oom_killed", kill
+			}
+			entries = append(entries, entry)
 		} else {
 			// Check if PIDs changed (restart detection)
 			status := "running"
@@ -91,13 +99,20 @@ func collectWatchdog() ([]WatchdogEntry, error) {
 				status = "restarted"
 				restartCount = 1
 			}
-			entries = append(entries, WatchdogEntry{
+			entry := WatchdogEntry{
 				Name:         name,
 				Status:       status,
 				RestartCount: restartCount,
 				LastSeenAt:   now.Format(time.RFC3339),
 				PIDs:         pids,
-			})
+			}
+			// Only a PID that went away can have been OOM killed
+			if gone := missingPIDs(prev.PIDs, pids); status == "restarted" && len(gone) > 0 {
+				if kill := oomKills.match("", gone); kill != nil {
+					entry.Reason, entry.OOMKill = "oom_killed", kill
+				}
+			}
+			entries = append(entries, entry)
 		}
 	}
 
@@ -136,6 +151,17 @@ func collectWatchdog() ([]WatchdogEntry, error) {
 	return entries, nil
 }
 
+// missingPIDs returns the PIDs in prev that are not in cur.
+func missingPIDs(prev, cur []int32) []int32 {
+	var gone []int32
+	for _, pid := range prev {
+		if !slices.Contains(cur, pid) {
+			gone = append(gone, pid)
+		}
+	}
+	return gone
+}
+
 // samePIDs checks if two PID slices contain the same PIDs.
 func samePIDs(a, b []int32) bool {
 	if len(a) != len(b) {